@@ -0,0 +1,275 @@
+package glitch
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// AnimationFrame is a single frame of an AnimatedSprite
+type AnimationFrame struct {
+	Sprite   *Sprite
+	Duration time.Duration // How long the frame is shown for, at a speed of 1
+}
+
+// AnimationMode is what an AnimatedSprite does when it reaches the end of its frames
+type AnimationMode uint8
+
+const (
+	AnimationModeLoop     AnimationMode = iota // Starts again from the first frame
+	AnimationModePingPong                      // Plays the frames backwards, then forwards again
+	AnimationModeOnce                          // Stops on the last frame
+)
+
+// AnimatedSprite plays an ordered list of frames, drawing whichever frame is current.
+// Call Update every tick to advance the animation.
+type AnimatedSprite struct {
+	frames  []AnimationFrame
+	mode    AnimationMode
+	speed   float64
+	repeat  int // The number of passes to play before stopping, 0 to play forever
+	playing bool
+	done    bool // Set when the animation stops by itself at its end
+
+	current int
+	elapsed time.Duration // The time spent on the current frame
+	step    int           // 1 when playing forwards, -1 when ping ponging backwards
+	passes  int           // The number of completed passes
+
+	events   map[int][]func()
+	onFinish []func()
+}
+
+// NewAnimatedSprite creates a playing animation of frames. Every frame must have a duration.
+func NewAnimatedSprite(frames []AnimationFrame, mode AnimationMode) (*AnimatedSprite, error) {
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("animated sprite: the animation must have at least one frame")
+	}
+	for i, f := range frames {
+		if f.Duration <= 0 {
+			return nil, fmt.Errorf("animated sprite: frame %d must have a positive duration", i)
+		}
+	}
+
+	return &AnimatedSprite{
+		frames:  frames,
+		mode:    mode,
+		speed:   1,
+		playing: true,
+		step:    1,
+		events:  make(map[int][]func()),
+	}, nil
+}
+
+// NewAnimatedSpriteTag creates a playing animation of the frames of an animation tag of sheet,
+// with the tag's direction and repeat count. The sheet must have frame durations (like the
+// sheets exported by Aseprite).
+func NewAnimatedSpriteTag(sheet *Spritesheet, tag string) (*AnimatedSprite, error) {
+	t, ok := sheet.Tag(tag)
+	if !ok {
+		return nil, fmt.Errorf("animated sprite: missing tag %s", tag)
+	}
+
+	sheetFrames, _ := sheet.TagFrames(tag)
+	frames := make([]AnimationFrame, len(sheetFrames))
+	for i, f := range sheetFrames {
+		if f.Duration <= 0 {
+			return nil, fmt.Errorf("animated sprite: tag %s: frame %s doesn't have a duration", tag, f.Name)
+		}
+		frames[i] = AnimationFrame{f.Sprite, f.Duration}
+	}
+
+	mode := AnimationModeLoop
+	switch t.Direction {
+	case AnimationReverse:
+		slices.Reverse(frames)
+	case AnimationPingPong:
+		mode = AnimationModePingPong
+	case AnimationPingPongReverse:
+		slices.Reverse(frames)
+		mode = AnimationModePingPong
+	}
+
+	anim, err := NewAnimatedSprite(frames, mode)
+	if err != nil {
+		return nil, err
+	}
+	anim.repeat = t.Repeat
+	return anim, nil
+}
+
+// Update advances the animation by dt, scaled by the playback speed. Frame events are called
+// for every frame that is entered, even if dt skips past several of them.
+func (a *AnimatedSprite) Update(dt time.Duration) {
+	if !a.playing {
+		return
+	}
+
+	a.elapsed += time.Duration(float64(dt) * a.speed)
+	for a.playing && a.elapsed >= a.frames[a.current].Duration {
+		a.elapsed -= a.frames[a.current].Duration
+		a.advance()
+	}
+}
+
+// Moves to the next frame, handling the end of a pass
+func (a *AnimatedSprite) advance() {
+	next := a.current + a.step
+	if next >= 0 && next < len(a.frames) {
+		a.setFrame(next)
+		return
+	}
+
+	switch a.mode {
+	case AnimationModeOnce:
+		a.finish()
+		return
+	case AnimationModePingPong:
+		if a.step < 0 {
+			a.passes++
+			if a.repeat > 0 && a.passes >= a.repeat {
+				a.finish()
+				return
+			}
+		}
+		a.step = -a.step
+		next = min(max(a.current+a.step, 0), len(a.frames)-1)
+	default:
+		a.passes++
+		if a.repeat > 0 && a.passes >= a.repeat {
+			a.finish()
+			return
+		}
+		next = 0
+	}
+	a.setFrame(next)
+}
+
+func (a *AnimatedSprite) finish() {
+	a.playing = false
+	a.done = true
+	a.elapsed = 0
+	for _, fn := range a.onFinish {
+		fn()
+	}
+}
+
+func (a *AnimatedSprite) setFrame(frame int) {
+	a.current = frame
+	for _, fn := range a.events[frame] {
+		fn()
+	}
+}
+
+// OnFrame adds a callback that is called every time the animation enters frame
+func (a *AnimatedSprite) OnFrame(frame int, fn func()) {
+	a.events[frame] = append(a.events[frame], fn)
+}
+
+// OnFinish adds a callback that is called when the animation stops by itself, at the end of an
+// AnimationModeOnce animation or after the last repeat
+func (a *AnimatedSprite) OnFinish(fn func()) {
+	a.onFinish = append(a.onFinish, fn)
+}
+
+// Play resumes the animation. If the animation had finished then it restarts from the first frame.
+func (a *AnimatedSprite) Play() {
+	if a.playing {
+		return
+	}
+	if a.done {
+		a.Reset()
+	}
+	a.playing = true
+}
+
+// Pause stops the animation on the current frame
+func (a *AnimatedSprite) Pause() {
+	a.playing = false
+}
+
+// Stop pauses the animation and rewinds it to the first frame
+func (a *AnimatedSprite) Stop() {
+	a.playing = false
+	a.Reset()
+}
+
+// Reset rewinds the animation to the first frame, without changing whether it is playing
+func (a *AnimatedSprite) Reset() {
+	a.elapsed = 0
+	a.step = 1
+	a.passes = 0
+	a.done = false
+	a.setFrame(0)
+}
+
+// Playing returns true if the animation is playing
+func (a *AnimatedSprite) Playing() bool {
+	return a.playing
+}
+
+// SetSpeed sets the playback speed, where 1 is the speed of the frame durations
+func (a *AnimatedSprite) SetSpeed(speed float64) {
+	a.speed = max(speed, 0)
+}
+
+func (a *AnimatedSprite) Speed() float64 {
+	return a.speed
+}
+
+func (a *AnimatedSprite) SetMode(mode AnimationMode) {
+	a.mode = mode
+}
+
+func (a *AnimatedSprite) Mode() AnimationMode {
+	return a.mode
+}
+
+// SetRepeat sets the number of times that a looping or ping ponging animation plays before it
+// stops, or 0 to play forever. A ping pong counts as one pass once it is back at the first frame.
+func (a *AnimatedSprite) SetRepeat(repeat int) {
+	a.repeat = repeat
+}
+
+// SetFrame jumps to frame, calling its frame events
+func (a *AnimatedSprite) SetFrame(frame int) {
+	if frame < 0 || frame >= len(a.frames) {
+		panic(fmt.Sprintf("AnimatedSprite.SetFrame: frame %d is out of range [0, %d)", frame, len(a.frames)))
+	}
+	a.elapsed = 0
+	a.setFrame(frame)
+}
+
+// Frame returns the index of the current frame
+func (a *AnimatedSprite) Frame() int {
+	return a.current
+}
+
+// Frames returns every frame of the animation
+func (a *AnimatedSprite) Frames() []AnimationFrame {
+	return a.frames
+}
+
+// Sprite returns the sprite of the current frame
+func (a *AnimatedSprite) Sprite() *Sprite {
+	return a.frames[a.current].Sprite
+}
+
+func (a *AnimatedSprite) Draw(target BatchTarget, matrix Mat4) {
+	a.Sprite().Draw(target, matrix)
+}
+func (a *AnimatedSprite) DrawColorMask(target BatchTarget, matrix Mat4, mask RGBA) {
+	a.Sprite().DrawColorMask(target, matrix, mask)
+}
+
+func (a *AnimatedSprite) RectDraw(target BatchTarget, bounds Rect) {
+	a.Sprite().RectDraw(target, bounds)
+}
+func (a *AnimatedSprite) RectDrawColorMask(target BatchTarget, bounds Rect, mask RGBA) {
+	a.Sprite().RectDrawColorMask(target, bounds, mask)
+}
+
+// Bounds returns the bounds of the current frame
+func (a *AnimatedSprite) Bounds() Rect {
+	return a.Sprite().Bounds()
+}
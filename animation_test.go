@@ -0,0 +1,160 @@
+package glitch_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/unitoftime/glitch"
+)
+
+// Returns n frames that are each shown for 10ms. The frames don't need sprites to be updated.
+func animationFrames(n int) []glitch.AnimationFrame {
+	frames := make([]glitch.AnimationFrame, n)
+	for i := range frames {
+		frames[i].Duration = 10 * time.Millisecond
+	}
+	return frames
+}
+
+func TestAnimatedSpritePlayback(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   glitch.AnimationMode
+		repeat int
+		speed  float64
+		want   []int // The frame after each 10ms update
+		finish bool  // Set if the animation stops by itself by the last update
+	}{
+		{"loop", glitch.AnimationModeLoop, 0, 1, []int{1, 2, 0, 1, 2, 0, 1}, false},
+		{"loop repeat", glitch.AnimationModeLoop, 2, 1, []int{1, 2, 0, 1, 2, 2, 2}, true},
+		{"ping pong", glitch.AnimationModePingPong, 0, 1, []int{1, 2, 1, 0, 1, 2, 1}, false},
+		{"ping pong repeat", glitch.AnimationModePingPong, 1, 1, []int{1, 2, 1, 0, 0, 0, 0}, true},
+		{"once", glitch.AnimationModeOnce, 0, 1, []int{1, 2, 2, 2}, true},
+		{"once ignores repeat", glitch.AnimationModeOnce, 3, 1, []int{1, 2, 2}, true},
+		{"double speed", glitch.AnimationModeLoop, 0, 2, []int{2, 1, 0, 2}, false},
+		{"half speed", glitch.AnimationModeLoop, 0, 0.5, []int{0, 1, 1, 2, 2, 0}, false},
+		{"stopped", glitch.AnimationModeLoop, 0, 0, []int{0, 0}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			anim, err := glitch.NewAnimatedSprite(animationFrames(3), test.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			anim.SetRepeat(test.repeat)
+			anim.SetSpeed(test.speed)
+			finished := 0
+			anim.OnFinish(func() { finished++ })
+
+			got := make([]int, len(test.want))
+			for i := range got {
+				anim.Update(10 * time.Millisecond)
+				got[i] = anim.Frame()
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got frames %v, want %v", got, test.want)
+			}
+
+			wantFinished := 0
+			if test.finish {
+				wantFinished = 1
+			}
+			if finished != wantFinished || anim.Playing() == test.finish {
+				t.Errorf("finished %d times and playing is %v, want to finish %d times", finished, anim.Playing(), wantFinished)
+			}
+		})
+	}
+}
+
+func TestAnimatedSpriteEvents(t *testing.T) {
+	anim, err := glitch.NewAnimatedSprite(animationFrames(3), glitch.AnimationModeLoop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entered []int
+	for i := range 3 {
+		anim.OnFrame(i, func() { entered = append(entered, i) })
+	}
+
+	// A long update enters every frame that it skips past
+	anim.Update(45 * time.Millisecond)
+	if want := []int{1, 2, 0, 1}; !slices.Equal(entered, want) {
+		t.Errorf("entered frames %v, want %v", entered, want)
+	}
+	if anim.Frame() != 1 {
+		t.Errorf("got frame %d, want 1", anim.Frame())
+	}
+
+	// The time left over on the frame carries over into the next update
+	entered = nil
+	anim.Update(5 * time.Millisecond)
+	if want := []int{2}; !slices.Equal(entered, want) {
+		t.Errorf("entered frames %v, want %v", entered, want)
+	}
+
+	// Jumping to a frame enters it, and pausing stops the frame events
+	entered = nil
+	anim.SetFrame(0)
+	anim.Pause()
+	anim.Update(time.Second)
+	if want := []int{0}; !slices.Equal(entered, want) || anim.Frame() != 0 {
+		t.Errorf("entered frames %v and ended on frame %d, want %v and frame 0", entered, anim.Frame(), want)
+	}
+}
+
+func TestAnimatedSpriteRestart(t *testing.T) {
+	anim, err := glitch.NewAnimatedSprite(animationFrames(2), glitch.AnimationModeOnce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	finished := 0
+	anim.OnFinish(func() { finished++ })
+	anim.Update(time.Second)
+	if anim.Playing() || anim.Frame() != 1 || finished != 1 {
+		t.Fatalf("got frame %d and playing %v after the end", anim.Frame(), anim.Playing())
+	}
+
+	// Playing a finished animation starts it again from the beginning
+	anim.Play()
+	if !anim.Playing() || anim.Frame() != 0 {
+		t.Errorf("got frame %d and playing %v after Play", anim.Frame(), anim.Playing())
+	}
+	anim.Update(time.Second)
+	if finished != 2 {
+		t.Errorf("finished %d times, want 2", finished)
+	}
+
+	// Stopping rewinds without playing
+	anim.Stop()
+	anim.Update(time.Second)
+	if anim.Playing() || anim.Frame() != 0 || finished != 2 {
+		t.Errorf("got frame %d and playing %v after Stop", anim.Frame(), anim.Playing())
+	}
+}
+
+func TestNewAnimatedSpriteErrors(t *testing.T) {
+	frames := animationFrames(3)
+	frames[1].Duration = 0
+	negative := animationFrames(1)
+	negative[0].Duration = -time.Millisecond
+
+	tests := []struct {
+		name   string
+		frames []glitch.AnimationFrame
+		want   string
+	}{
+		{"no frames", nil, "at least one frame"},
+		{"zero duration", frames, "frame 1 must have a positive duration"},
+		{"negative duration", negative, "frame 0 must have a positive duration"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := glitch.NewAnimatedSprite(test.frames, glitch.AnimationModeLoop)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one containing %q", err, test.want)
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ type DrawBatch struct {
 	draws     ds.MiniSlice[[2]meshDraw, meshDraw]
 	boundsSet bool
 	bounds    Box
+	clips     []clipRect // The clip stack, see PushClip
 }
 
 func NewDrawBatch() DrawBatch {
@@ -35,7 +36,30 @@ func NewDrawBatch() DrawBatch {
 // 	}
 // }
 
+// PushClip clips everything that is added to the batch until the matching PopClip to rect.
+// The rect is in the batch's local space, and is moved along with the batch's geometry when
+// the batch is drawn.
+func (b *DrawBatch) PushClip(rect Rect) {
+	clip := clipRect{}
+	if len(b.clips) > 0 {
+		clip = b.clips[len(b.clips)-1]
+	}
+	b.clips = append(b.clips, clip.intersect(rect))
+}
+
+// PopClip removes the clip that was added by the last PushClip
+func (b *DrawBatch) PopClip() {
+	if len(b.clips) == 0 {
+		return
+	}
+	b.clips = b.clips[:len(b.clips)-1]
+}
+
 func (b *DrawBatch) Add(filler GeometryFiller, matrix glMat4, mask RGBA, material Material) {
+	if len(b.clips) > 0 {
+		material.clip = b.clips[len(b.clips)-1]
+	}
+
 	// b.draws = append(b.draws, meshDraw{
 	b.draws.Append(meshDraw{
 		filler:   filler,
@@ -67,18 +91,22 @@ func (b *DrawBatch) Clear() {
 func (b *DrawBatch) Draw(target BatchTarget, matrix Mat4) {
 	for _, draw := range b.draws.All() {
 		mat := glm4(matrix)
+		material := draw.material
+		material.clip = material.clip.transformed(mat)
 		mat.Mul(&draw.matrix)
-		target.Add(draw.filler, mat, draw.mask, draw.material)
+		target.Add(draw.filler, mat, draw.mask, material)
 	}
 }
 
 func (b *DrawBatch) DrawColorMask(target BatchTarget, matrix Mat4, color RGBA) {
 	for _, draw := range b.draws.All() {
 		mat := glm4(matrix)
+		material := draw.material
+		material.clip = material.clip.transformed(mat)
 		mat.Mul(&draw.matrix)
 
 		mask := draw.mask.Mult(color)
-		target.Add(draw.filler, mat, mask, draw.material)
+		target.Add(draw.filler, mat, mask, material)
 	}
 }
 
@@ -1,26 +1,141 @@
 package glitch
 
-import "github.com/unitoftime/glitch/internal/gl"
+import (
+	"math"
+
+	"github.com/unitoftime/glitch/internal/gl"
+)
 
 // Note: These are all packed into uint8s to reduce size of the Material object
+// Note: The shaders output premultiplied colors, so the blend modes expect premultiplied sources
+// (except for BlendModeStraightAlpha)
 type BlendMode uint8
 
 const (
 	BlendModeNone BlendMode = iota
 	BlendModeNormal
 	BlendModeMultiply
+	BlendModeAdditive      // Adds the source onto the destination
+	BlendModeScreen        // Inverse of multiply, brightens the destination
+	BlendModeSubtract      // Subtracts the source from the destination, keeping the destination alpha
+	BlendModeMin           // Keeps the smaller of source and destination, per channel
+	BlendModeMax           // Keeps the larger of source and destination, per channel
+	BlendModeStraightAlpha // Normal blending for sources that aren't premultiplied
+)
+
+// BlendFactor is what the source or destination is multiplied by before they are combined
+type BlendFactor uint8
+
+const (
+	BlendFactorZero BlendFactor = iota
+	BlendFactorOne
+	BlendFactorSrcColor
+	BlendFactorOneMinusSrcColor
+	BlendFactorDstColor
+	BlendFactorOneMinusDstColor
+	BlendFactorSrcAlpha
+	BlendFactorOneMinusSrcAlpha
+	BlendFactorDstAlpha
+	BlendFactorOneMinusDstAlpha
+)
+
+var blendFactorLut = []gl.Enum{
+	BlendFactorZero:             gl.ZERO,
+	BlendFactorOne:              gl.ONE,
+	BlendFactorSrcColor:         gl.SRC_COLOR,
+	BlendFactorOneMinusSrcColor: gl.ONE_MINUS_SRC_COLOR,
+	BlendFactorDstColor:         gl.DST_COLOR,
+	BlendFactorOneMinusDstColor: gl.ONE_MINUS_DST_COLOR,
+	BlendFactorSrcAlpha:         gl.SRC_ALPHA,
+	BlendFactorOneMinusSrcAlpha: gl.ONE_MINUS_SRC_ALPHA,
+	BlendFactorDstAlpha:         gl.DST_ALPHA,
+	BlendFactorOneMinusDstAlpha: gl.ONE_MINUS_DST_ALPHA,
+}
+
+// BlendEquation is how the scaled source and destination are combined
+type BlendEquation uint8
+
+const (
+	BlendEquationAdd             BlendEquation = iota // src + dst
+	BlendEquationSubtract                             // src - dst
+	BlendEquationReverseSubtract                      // dst - src
+	BlendEquationMin                                  // min(src, dst), the factors are ignored
+	BlendEquationMax                                  // max(src, dst), the factors are ignored
 )
 
+var blendEquationLut = []gl.Enum{
+	BlendEquationAdd:             gl.FUNC_ADD,
+	BlendEquationSubtract:        gl.FUNC_SUBTRACT,
+	BlendEquationReverseSubtract: gl.FUNC_REVERSE_SUBTRACT,
+	BlendEquationMin:             gl.MIN,
+	BlendEquationMax:             gl.MAX,
+}
+
+// BlendFunc describes a custom blend mode, with separate factors and equations for the color
+// and alpha channels. See NewBlendMode
+type BlendFunc struct {
+	SrcRGB, DstRGB             BlendFactor
+	SrcAlpha, DstAlpha         BlendFactor
+	EquationRGB, EquationAlpha BlendEquation
+}
+
 type blendModeData struct {
-	src, dst gl.Enum
+	srcRGB, dstRGB     gl.Enum
+	srcAlpha, dstAlpha gl.Enum
+	eqRGB, eqAlpha     gl.Enum
+}
+
+// Sets the same factors for the color and alpha channels, combined with FUNC_ADD
+func blendModeFactors(src, dst gl.Enum) blendModeData {
+	return blendModeData{src, dst, src, dst, gl.FUNC_ADD, gl.FUNC_ADD}
 }
 
 var blendModeLut []blendModeData = []blendModeData{
+	BlendModeNone: {},
 	// Note: This is what I used before premult: gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA
-	BlendModeNormal: {gl.ONE, gl.ONE_MINUS_SRC_ALPHA},
+	BlendModeNormal: blendModeFactors(gl.ONE, gl.ONE_MINUS_SRC_ALPHA),
 	// BlendModeNormal: {gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA},
 	// BlendModeNormal: {gl.SRC_ALPHA, gl.ONE},
-	BlendModeMultiply: {gl.DST_COLOR, gl.ZERO},
+	BlendModeMultiply: blendModeFactors(gl.DST_COLOR, gl.ZERO),
+	BlendModeAdditive: blendModeFactors(gl.ONE, gl.ONE),
+	BlendModeScreen: {
+		gl.ONE, gl.ONE_MINUS_SRC_COLOR,
+		gl.ONE, gl.ONE_MINUS_SRC_ALPHA,
+		gl.FUNC_ADD, gl.FUNC_ADD,
+	},
+	BlendModeSubtract: {
+		gl.ONE, gl.ONE,
+		gl.ZERO, gl.ONE,
+		gl.FUNC_REVERSE_SUBTRACT, gl.FUNC_ADD,
+	},
+	BlendModeMin: {gl.ONE, gl.ONE, gl.ONE, gl.ONE, gl.MIN, gl.MIN},
+	BlendModeMax: {gl.ONE, gl.ONE, gl.ONE, gl.ONE, gl.MAX, gl.MAX},
+	BlendModeStraightAlpha: {
+		gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA,
+		gl.ONE, gl.ONE_MINUS_SRC_ALPHA,
+		gl.FUNC_ADD, gl.FUNC_ADD,
+	},
+}
+
+// NewBlendMode returns a blend mode that blends with fn. Blend modes are stored in a lookup
+// table so that materials stay small, so calling this again with the same fn returns the same
+// mode. Create custom modes once (eg at startup) rather than every frame.
+func NewBlendMode(fn BlendFunc) BlendMode {
+	data := blendModeData{
+		blendFactorLut[fn.SrcRGB], blendFactorLut[fn.DstRGB],
+		blendFactorLut[fn.SrcAlpha], blendFactorLut[fn.DstAlpha],
+		blendEquationLut[fn.EquationRGB], blendEquationLut[fn.EquationAlpha],
+	}
+	for i := BlendModeNormal; int(i) < len(blendModeLut); i++ {
+		if blendModeLut[i] == data {
+			return i
+		}
+	}
+	if len(blendModeLut) > math.MaxUint8 {
+		panic("NewBlendMode: too many blend modes")
+	}
+	blendModeLut = append(blendModeLut, data)
+	return BlendMode(len(blendModeLut) - 1)
 }
 
 type DepthMode uint8
@@ -57,6 +172,107 @@ var cullModeLut []cullModeData = []cullModeData{
 	CullModeNormal: {gl.BACK, gl.CCW},
 }
 
+// StencilMode controls how a material tests against and writes to the stencil buffer.
+// The zero value (StencilModeNone) disables the stencil test.
+// Note: Unlike the other modes this isn't packed into a lut, because the ref and masks are
+// usually different per effect (eg one ref value per portal)
+type StencilMode struct {
+	Func      StencilFunc // How Ref is compared against the stored value
+	Ref       uint8
+	ReadMask  uint8 // Applied to both Ref and the stored value before comparing
+	WriteMask uint8 // The bits of the stored value that the ops can change
+
+	Fail      StencilOp // Op for fragments that fail the stencil test
+	DepthFail StencilOp // Op for fragments that pass the stencil test but fail the depth test
+	Pass      StencilOp // Op for fragments that pass both tests
+
+	SkipColor bool // If set, only the stencil buffer is written (eg for invisible masks)
+}
+
+var StencilModeNone = StencilMode{}
+
+// StencilModeWrite writes ref into the stencil buffer wherever the material draws, without
+// drawing any color. Draw a sprite with this to use its shape as a mask.
+// Note: Transparent pixels of sprites are discarded, so they don't write to the mask
+func StencilModeWrite(ref uint8) StencilMode {
+	return StencilMode{
+		Func:      StencilFuncAlways,
+		Ref:       ref,
+		ReadMask:  0xFF,
+		WriteMask: 0xFF,
+		Pass:      StencilOpReplace,
+		SkipColor: true,
+	}
+}
+
+// StencilModeInside only draws where the stencil buffer equals ref (ie inside the mask)
+func StencilModeInside(ref uint8) StencilMode {
+	return StencilMode{
+		Func:     StencilFuncEqual,
+		Ref:      ref,
+		ReadMask: 0xFF,
+	}
+}
+
+// StencilModeOutside only draws where the stencil buffer doesn't equal ref (ie outside the mask)
+func StencilModeOutside(ref uint8) StencilMode {
+	return StencilMode{
+		Func:     StencilFuncNotEqual,
+		Ref:      ref,
+		ReadMask: 0xFF,
+	}
+}
+
+type StencilFunc uint8
+
+const (
+	StencilFuncNone StencilFunc = iota // The stencil test is disabled
+	StencilFuncNever
+	StencilFuncLess
+	StencilFuncLequal
+	StencilFuncGreater
+	StencilFuncGequal
+	StencilFuncEqual
+	StencilFuncNotEqual
+	StencilFuncAlways
+)
+
+var stencilFuncLut = []gl.Enum{
+	StencilFuncNone:     gl.ALWAYS,
+	StencilFuncNever:    gl.NEVER,
+	StencilFuncLess:     gl.LESS,
+	StencilFuncLequal:   gl.LEQUAL,
+	StencilFuncGreater:  gl.GREATER,
+	StencilFuncGequal:   gl.GEQUAL,
+	StencilFuncEqual:    gl.EQUAL,
+	StencilFuncNotEqual: gl.NOTEQUAL,
+	StencilFuncAlways:   gl.ALWAYS,
+}
+
+type StencilOp uint8
+
+const (
+	StencilOpKeep     StencilOp = iota // Keep the stored value
+	StencilOpZero                      // Set the stored value to 0
+	StencilOpReplace                   // Set the stored value to Ref
+	StencilOpIncr                      // Increment the stored value, clamped to 255
+	StencilOpDecr                      // Decrement the stored value, clamped to 0
+	StencilOpInvert                    // Bitwise invert the stored value
+	StencilOpIncrWrap                  // Increment the stored value, wrapping 255 to 0
+	StencilOpDecrWrap                  // Decrement the stored value, wrapping 0 to 255
+)
+
+var stencilOpLut = []gl.Enum{
+	StencilOpKeep:     gl.KEEP,
+	StencilOpZero:     gl.ZERO,
+	StencilOpReplace:  gl.REPLACE,
+	StencilOpIncr:     gl.INCR,
+	StencilOpDecr:     gl.DECR,
+	StencilOpInvert:   gl.INVERT,
+	StencilOpIncrWrap: gl.INCR_WRAP,
+	StencilOpDecrWrap: gl.DECR_WRAP,
+}
+
 // // https://registry.khronos.org/OpenGL-Refpages/gl4/html/glBlendFunc.xhtml
 // type BlendMode struct {
 // 	src, dst gl.Enum
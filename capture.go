@@ -0,0 +1,768 @@
+package glitch
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/unitoftime/glitch/internal/gl"
+	"github.com/unitoftime/glitch/internal/mainthread"
+	"github.com/unitoftime/glitch/shaders"
+)
+
+// Capture is a recording of every draw command that was submitted to the global batcher
+// between BeginCapture and EndCapture. It can be saved to a file, loaded back and replayed
+// with a Replayer.
+// Shaders, textures, uniforms and targets are stored once and referenced by index from the
+// commands, an index of -1 means the command didn't have one.
+type Capture struct {
+	Targets  []CapturedTarget
+	Shaders  []CapturedShader
+	Textures []CapturedTexture
+	Uniforms []CapturedUniforms
+	Commands []CapturedCommand
+}
+
+type CapturedTarget struct {
+	Kind          string // "window", "frame" or "other"
+	Width, Height int
+}
+
+type CapturedShader struct {
+	VertexSource   string
+	FragmentSource string
+	VertexFormat   shaders.VertexFormat
+	UniformFormat  shaders.UniformFormat
+}
+
+// The texture is snapshotted the first time a command uses it. Pix is stored in opengl row
+// order (bottom to top) with premultiplied colors, in the layout of the format's pixel type,
+// ready to be uploaded again. Depth textures can't be read back, so their Pix is empty.
+type CapturedTexture struct {
+	Width, Height int
+	Config        TextureConfig
+	Format        TextureFormat
+	Pix           []byte
+}
+
+// A texture bound to one of the material's slots, see Material.SetTextureSlot
+type CapturedTextureSlot struct {
+	Slot    int
+	Sampler string
+	Texture int
+}
+
+type CapturedUniforms map[string]CapturedUniform
+
+type CapturedUniform struct {
+	Type   shaders.AttrType
+	Values []float32
+}
+
+// The geometry of a command, in the local space of the command (ie before Matrix is applied).
+// Programmatic geometry (like quads and text) is expanded into its vertices.
+// Note: Buffered and instanced meshes don't keep their vertices on the CPU, so they are
+// recorded with the "buffered" or "instanced" kind and no vertices, and are skipped on replay.
+type CapturedGeometry struct {
+	Kind      string // "mesh", "quad", "text", "buffered", "instanced" or "unknown"
+	Positions [][3]float32
+	Normals   [][3]float32
+	Colors    [][4]float32
+	TexCoords [][2]float32
+	Indices   []uint32
+}
+
+type CapturedCommand struct {
+	Target   int
+	Geometry CapturedGeometry
+	Matrix   [16]float32
+	Mask     RGBA
+	Shader   int
+	Textures []CapturedTextureSlot // Only the slots that have a texture
+	Uniforms int
+	Blend    BlendMode
+	Depth    DepthMode
+	Cull     CullMode
+	Stencil  StencilMode
+	Clipped  bool
+	Clip     Rect // The clip rect in camera space, only used if Clipped is set
+
+	// The camera that was set when the command was added
+	Projection, View [16]float32
+}
+
+var capture struct {
+	current  *Capture
+	targets  map[Target]int
+	shaders  map[*Shader]int
+	textures map[*Texture]int
+	uniforms map[*Uniforms]int
+}
+
+// BeginCapture starts recording every draw command. Call EndCapture to stop recording and get
+// the capture. Usually you would begin right after Window.Update and end right before the next.
+// Note: The first use of each texture reads it back from the GPU, so a captured frame is slower
+// and may be batched differently than a normal frame.
+func BeginCapture() {
+	capture.current = &Capture{}
+	capture.targets = make(map[Target]int)
+	capture.shaders = make(map[*Shader]int)
+	capture.textures = make(map[*Texture]int)
+	capture.uniforms = make(map[*Uniforms]int)
+}
+
+// EndCapture stops recording and returns everything that was recorded since BeginCapture.
+// Returns nil if there wasn't a capture in progress.
+func EndCapture() *Capture {
+	c := capture.current
+	capture.current = nil
+	capture.targets = nil
+	capture.shaders = nil
+	capture.textures = nil
+	capture.uniforms = nil
+	return c
+}
+
+// Save writes the capture to a json file
+func (c *Capture) Save(path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadCapture reads a capture that was written with Capture.Save
+func LoadCapture(path string) (*Capture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Capture{}
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Records a command that was added to the global batcher
+func captureCommand(filler GeometryFiller, mat glMat4, mask RGBA, material Material) {
+	c := capture.current
+	c.Commands = append(c.Commands, CapturedCommand{
+		Target:     captureTarget(global.target),
+		Geometry:   captureGeometry(filler),
+		Matrix:     mat,
+		Mask:       mask,
+		Shader:     captureShader(material.shader),
+		Textures:   captureTextureSlots(material),
+		Uniforms:   captureUniforms(material.uniforms),
+		Blend:      material.blend,
+		Depth:      material.depth,
+		Cull:       material.cull,
+		Stencil:    material.stencil,
+		Clipped:    material.clip.enabled,
+		Clip:       material.clip.rect,
+		Projection: global.camera.Projection,
+		View:       global.camera.View,
+	})
+}
+
+func captureTarget(target Target) int {
+	if target == nil {
+		return -1
+	}
+	if idx, ok := capture.targets[target]; ok {
+		return idx
+	}
+
+	t := CapturedTarget{Kind: "other"}
+	switch tt := target.(type) {
+	case *Window:
+		t = CapturedTarget{"window", tt.width, tt.height}
+	case *Frame:
+		t = CapturedTarget{"frame", tt.textures[0].width, tt.textures[0].height}
+	}
+
+	idx := len(capture.current.Targets)
+	capture.current.Targets = append(capture.current.Targets, t)
+	capture.targets[target] = idx
+	return idx
+}
+
+func captureShader(shader *Shader) int {
+	if shader == nil {
+		return -1
+	}
+	if idx, ok := capture.shaders[shader]; ok {
+		return idx
+	}
+
+	idx := len(capture.current.Shaders)
+	capture.current.Shaders = append(capture.current.Shaders, CapturedShader{
+		VertexSource:   shader.vertexSource,
+		FragmentSource: shader.fragmentSource,
+		VertexFormat:   append(slices.Clone(shader.attrFmt), shader.instanceFmt...),
+		UniformFormat:  shader.uniformFmt,
+	})
+	capture.shaders[shader] = idx
+	return idx
+}
+
+func captureTextureSlots(material Material) []CapturedTextureSlot {
+	var slots []CapturedTextureSlot
+	for slot, t := range material.textures {
+		if t.texture == nil {
+			continue
+		}
+		slots = append(slots, CapturedTextureSlot{slot, t.sampler, captureTexture(t.texture)})
+	}
+	return slots
+}
+
+func captureTexture(texture *Texture) int {
+	if texture == nil {
+		return -1
+	}
+	if idx, ok := capture.textures[texture]; ok {
+		return idx
+	}
+
+	// The texture might be a frame that still has draws waiting in the batch
+	global.flush()
+
+	var pix []byte
+	f := textureFormatLut[texture.format]
+	if !texture.format.depth() && texture.width*texture.height > 0 {
+		// Color attachments are always read back as RGBA, then packed down to the format's channels
+		rgba := make([]byte, 4*f.size*texture.width*texture.height)
+		mainthread.Call(func() {
+			fbo := gl.CreateFramebuffer()
+			gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
+			gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, texture.texture, 0)
+			gl.ReadPixels(rgba, 0, 0, texture.width, texture.height, gl.RGBA, f.ty)
+			gl.BindFramebuffer(gl.FRAMEBUFFER, state.fbo)
+			gl.DeleteFramebuffer(fbo)
+		})
+		pix = packChannels(rgba, f.channels, f.size)
+	}
+
+	idx := len(capture.current.Textures)
+	capture.current.Textures = append(capture.current.Textures, CapturedTexture{
+		Width:  texture.width,
+		Height: texture.height,
+		Config: texture.config,
+		Format: texture.format,
+		Pix:    pix,
+	})
+	capture.textures[texture] = idx
+	return idx
+}
+
+// Packs RGBA pixels with size byte channels down to their first channels channels
+func packChannels(rgba []byte, channels, size int) []byte {
+	if channels == 4 {
+		return rgba
+	}
+	stride := 4 * size
+	pix := make([]byte, 0, len(rgba)/stride*channels*size)
+	for i := 0; i < len(rgba); i += stride {
+		pix = append(pix, rgba[i:i+channels*size]...)
+	}
+	return pix
+}
+
+// Uniforms are modified in place, so a new entry is only recorded if the values have changed
+// since the last time this uniforms pointer was recorded.
+func captureUniforms(uniforms *Uniforms) int {
+	if uniforms == nil {
+		return -1
+	}
+
+	u := make(CapturedUniforms, len(uniforms.set))
+	for name, val := range uniforms.set {
+		u[name] = captureUniform(val)
+	}
+
+	idx, ok := capture.uniforms[uniforms]
+	if ok && maps.EqualFunc(capture.current.Uniforms[idx], u, equalUniform) {
+		return idx
+	}
+
+	idx = len(capture.current.Uniforms)
+	capture.current.Uniforms = append(capture.current.Uniforms, u)
+	capture.uniforms[uniforms] = idx
+	return idx
+}
+
+func captureUniform(value any) CapturedUniform {
+	switch val := value.(type) {
+	case float32:
+		return CapturedUniform{shaders.AttrFloat, []float32{val}}
+	case float64:
+		return CapturedUniform{shaders.AttrFloat, []float32{float32(val)}}
+	case Vec2:
+		v := glv2(val)
+		return CapturedUniform{shaders.AttrVec2, v[:]}
+	case Vec3:
+		v := glv3(val)
+		return CapturedUniform{shaders.AttrVec3, v[:]}
+	case Vec4:
+		v := glv4(val)
+		return CapturedUniform{shaders.AttrVec4, v[:]}
+	case RGBA:
+		v := glc4(val)
+		return CapturedUniform{shaders.AttrVec4, v[:]}
+	case glMat4:
+		return CapturedUniform{shaders.AttrMat4, slices.Clone(val[:])}
+	case *glMat4:
+		return CapturedUniform{shaders.AttrMat4, slices.Clone(val[:])}
+	case Mat4:
+		m := glm4(val)
+		return CapturedUniform{shaders.AttrMat4, m[:]}
+	case *Mat4:
+		m := glm4(*val)
+		return CapturedUniform{shaders.AttrMat4, m[:]}
+	default:
+		panic(fmt.Sprintf("capture uniform: invalid uniform type: %T", value))
+	}
+}
+
+func equalUniform(a, b CapturedUniform) bool {
+	return a.Type == b.Type && slices.Equal(a.Values, b.Values)
+}
+
+func captureGeometry(filler GeometryFiller) CapturedGeometry {
+	switch filler.fillType {
+	case fillTypeMesh:
+		if buffer := filler.mesh.buffer; buffer != nil {
+			if buffer.instances != nil {
+				return CapturedGeometry{Kind: "instanced"}
+			}
+			return CapturedGeometry{Kind: "buffered"}
+		}
+		return captureMesh("mesh", filler.mesh)
+	case fillTypeProgrammatic:
+		switch prog := filler.prog.(type) {
+		case Quad:
+			return captureQuad(prog)
+		case textDraw:
+			// Generates the text mesh the same way that textDraw.Fill does
+			tt := prog.atlas.tmpText
+			tt.SetScale(prog.scale)
+			tt.Set(prog.text)
+			tt.currentString = prog.text
+			tt.Clear()
+			tt.bounds = tt.AppendStringVerts(tt.currentString, false)
+			return captureMesh("text", tt.mesh)
+		}
+	}
+	return CapturedGeometry{Kind: "unknown"}
+}
+
+func captureMesh(kind string, mesh *Mesh) CapturedGeometry {
+	g := CapturedGeometry{
+		Kind:      kind,
+		Positions: make([][3]float32, len(mesh.positions)),
+		Normals:   make([][3]float32, len(mesh.normals)),
+		Colors:    make([][4]float32, len(mesh.colors)),
+		TexCoords: make([][2]float32, len(mesh.texCoords)),
+		Indices:   slices.Clone(mesh.indices),
+	}
+	for i := range mesh.positions {
+		g.Positions[i] = mesh.positions[i]
+	}
+	for i := range mesh.normals {
+		g.Normals[i] = mesh.normals[i]
+	}
+	for i := range mesh.colors {
+		g.Colors[i] = mesh.colors[i]
+	}
+	for i := range mesh.texCoords {
+		g.TexCoords[i] = mesh.texCoords[i]
+	}
+	return g
+}
+
+// Matches the vertices generated by Quad.Fill
+func captureQuad(s Quad) CapturedGeometry {
+	bounds := s.Bounds()
+	min := glv3(bounds.Min)
+	max := glv3(bounds.Max)
+
+	var uv [4][2]float32
+	texture := s.material.textures[0].texture
+	if texture != nil {
+		uMin := float32(s.Frame.Min.X / float64(texture.width))
+		vMin := float32(s.Frame.Min.Y / float64(texture.height))
+		uMax := float32(s.Frame.Max.X / float64(texture.width))
+		vMax := float32(s.Frame.Max.Y / float64(texture.height))
+		uv = [4][2]float32{{uMax, vMin}, {uMax, vMax}, {uMin, vMax}, {uMin, vMin}}
+	}
+
+	white := [4]float32{1, 1, 1, 1}
+	return CapturedGeometry{
+		Kind: "quad",
+		Positions: [][3]float32{
+			{max[0], max[1], min[2]},
+			{max[0], min[1], min[2]},
+			{min[0], min[1], min[2]},
+			{min[0], max[1], min[2]},
+		},
+		Colors:    [][4]float32{white, white, white, white},
+		TexCoords: uv[:],
+		Indices:   slices.Clone(quadIndices),
+	}
+}
+
+//--------------------------------------------------------------------------------
+
+// Replayer re-issues the commands of a capture. The shaders and textures of the capture are
+// recreated, so a capture that was loaded from a file can be replayed in another program.
+// Note: Every command is added to the target that is passed in, regardless of which target
+// it was captured from. Replay onto a Frame or Window rather than a Sorter, because the sorter
+// would reorder the commands.
+type Replayer struct {
+	capture  *Capture
+	shaders  []*Shader
+	textures []*Texture
+	uniforms []*Uniforms
+	meshes   []*Mesh // Nil for commands that can't be replayed
+	next     int
+}
+
+// NewReplayer recreates the shaders, textures and meshes of the capture. An error is returned
+// if the capture is malformed (eg an index that is out of range), so that a capture loaded
+// from a file can't crash the replay. Call Delete once you're done replaying.
+func NewReplayer(c *Capture) (*Replayer, error) {
+	err := c.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Replayer{
+		capture:  c,
+		shaders:  make([]*Shader, len(c.Shaders)),
+		textures: make([]*Texture, len(c.Textures)),
+		uniforms: make([]*Uniforms, len(c.Uniforms)),
+		meshes:   make([]*Mesh, len(c.Commands)),
+	}
+
+	for i, s := range c.Shaders {
+		shader, err := NewShaderExt(s.VertexSource, s.FragmentSource, s.VertexFormat, s.UniformFormat)
+		if err != nil {
+			r.Delete()
+			return nil, fmt.Errorf("replay shader %d: %w", i, err)
+		}
+		r.shaders[i] = shader
+	}
+
+	for i, t := range c.Textures {
+		texture := &Texture{
+			width:  t.Width,
+			height: t.Height,
+			config: t.Config,
+			format: t.Format,
+		}
+		texture.initialize(t.Pix)
+		r.textures[i] = texture
+	}
+
+	for i, u := range c.Uniforms {
+		uniforms := &Uniforms{}
+		for name, val := range u {
+			value, _ := replayUniform(val) // Checked by validate
+			uniforms.SetUniform(name, value)
+		}
+		r.uniforms[i] = uniforms
+	}
+
+	for i, cmd := range c.Commands {
+		r.meshes[i] = replayMesh(cmd.Geometry)
+	}
+
+	return r, nil
+}
+
+// Checks every index and count that the replayer relies on
+func (c *Capture) validate() error {
+	for i, t := range c.Textures {
+		if int(t.Format) >= len(textureFormatLut) {
+			return fmt.Errorf("replay texture %d: unknown format %d", i, t.Format)
+		}
+		if t.Width <= 0 || t.Height <= 0 {
+			return fmt.Errorf("replay texture %d: invalid size %dx%d", i, t.Width, t.Height)
+		}
+		f := textureFormatLut[t.Format]
+		size := f.channels * f.size * t.Width * t.Height
+		if t.Format.depth() {
+			size = 0
+		}
+		if len(t.Pix) != size {
+			return fmt.Errorf("replay texture %d: wrong number of pixels", i)
+		}
+	}
+
+	for i, u := range c.Uniforms {
+		for name, val := range u {
+			_, err := replayUniform(val)
+			if err != nil {
+				return fmt.Errorf("replay uniforms %d: %s: %w", i, name, err)
+			}
+		}
+	}
+
+	inRange := func(idx, n int) bool {
+		return idx >= -1 && idx < n
+	}
+	for i, cmd := range c.Commands {
+		if !inRange(cmd.Target, len(c.Targets)) {
+			return fmt.Errorf("replay command %d: target %d out of range", i, cmd.Target)
+		}
+		if !inRange(cmd.Shader, len(c.Shaders)) {
+			return fmt.Errorf("replay command %d: shader %d out of range", i, cmd.Shader)
+		}
+		if !inRange(cmd.Uniforms, len(c.Uniforms)) {
+			return fmt.Errorf("replay command %d: uniforms %d out of range", i, cmd.Uniforms)
+		}
+		for _, t := range cmd.Textures {
+			if t.Slot < 0 || t.Slot >= MaxTextureSlots {
+				return fmt.Errorf("replay command %d: texture slot %d out of range", i, t.Slot)
+			}
+			if t.Texture < 0 || t.Texture >= len(c.Textures) {
+				return fmt.Errorf("replay command %d: texture %d out of range", i, t.Texture)
+			}
+		}
+
+		g := cmd.Geometry
+		numVerts := len(g.Positions)
+		if len(g.Normals) != 0 && len(g.Normals) != numVerts {
+			return fmt.Errorf("replay command %d: %d normals for %d positions", i, len(g.Normals), numVerts)
+		}
+		if len(g.Colors) != 0 && len(g.Colors) != numVerts {
+			return fmt.Errorf("replay command %d: %d colors for %d positions", i, len(g.Colors), numVerts)
+		}
+		if len(g.TexCoords) != 0 && len(g.TexCoords) != numVerts {
+			return fmt.Errorf("replay command %d: %d tex coords for %d positions", i, len(g.TexCoords), numVerts)
+		}
+		for _, idx := range g.Indices {
+			if int(idx) >= numVerts {
+				return fmt.Errorf("replay command %d: index %d out of range (%d positions)", i, idx, numVerts)
+			}
+		}
+	}
+	return nil
+}
+
+// Delete frees the shaders and textures that were recreated for the replay. The replayer
+// can't be used afterwards.
+func (r *Replayer) Delete() {
+	for _, shader := range r.shaders {
+		if shader != nil {
+			shader.Delete()
+		}
+	}
+	for _, texture := range r.textures {
+		if texture != nil {
+			texture.Delete()
+		}
+	}
+}
+
+// Len returns the number of commands in the capture
+func (r *Replayer) Len() int {
+	return len(r.capture.Commands)
+}
+
+// Next returns the index of the command that the next call to Step will issue
+func (r *Replayer) Next() int {
+	return r.next
+}
+
+// Reset rewinds the replayer back to the first command
+func (r *Replayer) Reset() {
+	r.next = 0
+}
+
+// Step issues the next command to the target. Returns false once every command has been issued.
+func (r *Replayer) Step(target BatchTarget) bool {
+	if r.next >= len(r.capture.Commands) {
+		return false
+	}
+	idx := r.next
+	r.next++
+
+	cmd := r.capture.Commands[idx]
+	mesh := r.meshes[idx]
+	if mesh == nil {
+		return true
+	}
+
+	SetCameraMaterial(CameraMaterial{
+		Projection: cmd.Projection,
+		View:       cmd.View,
+	})
+	target.Add(mesh.g(), cmd.Matrix, cmd.Mask, r.material(cmd))
+	return true
+}
+
+// Replay issues every command of the capture to the target, from the first command
+func (r *Replayer) Replay(target BatchTarget) {
+	r.Reset()
+	for r.Step(target) {
+	}
+}
+
+func (r *Replayer) material(cmd CapturedCommand) Material {
+	m := Material{
+		blend:   cmd.Blend,
+		depth:   cmd.Depth,
+		cull:    cmd.Cull,
+		stencil: cmd.Stencil,
+		clip:    clipRect{cmd.Clip, cmd.Clipped},
+	}
+	if cmd.Shader >= 0 {
+		m.shader = r.shaders[cmd.Shader]
+	}
+	for _, t := range cmd.Textures {
+		m.SetTextureSlot(t.Slot, t.Sampler, r.textures[t.Texture])
+	}
+	if cmd.Uniforms >= 0 {
+		m.uniforms = r.uniforms[cmd.Uniforms]
+	}
+	return m
+}
+
+// Dump describes command i and the state that it changes compared to the command before it
+func (r *Replayer) Dump(i int) string {
+	cmds := r.capture.Commands
+	if i < 0 || i >= len(cmds) {
+		return fmt.Sprintf("command %d: out of range (%d commands)\n", i, len(cmds))
+	}
+
+	cmd := cmds[i]
+	prev := CapturedCommand{Target: -1, Shader: -1, Uniforms: -1}
+	first := i == 0
+	if !first {
+		prev = cmds[i-1]
+	}
+
+	var b strings.Builder
+	geom := cmd.Geometry
+	fmt.Fprintf(&b, "command %d: %s geometry, %d verts, %d indices", i, geom.Kind, len(geom.Positions), len(geom.Indices))
+	if r.meshes[i] == nil {
+		b.WriteString(" (not replayed)")
+	}
+	b.WriteString("\n")
+
+	if first || cmd.Target != prev.Target {
+		desc := "none"
+		if cmd.Target >= 0 {
+			t := r.capture.Targets[cmd.Target]
+			desc = fmt.Sprintf("%s %dx%d", t.Kind, t.Width, t.Height)
+		}
+		fmt.Fprintf(&b, "  target:   %d -> %d (%s)\n", prev.Target, cmd.Target, desc)
+	}
+	if first || cmd.Projection != prev.Projection || cmd.View != prev.View {
+		fmt.Fprintf(&b, "  camera:   projection %v view %v\n", cmd.Projection, cmd.View)
+	}
+	if first || cmd.Shader != prev.Shader {
+		fmt.Fprintf(&b, "  shader:   %d -> %d\n", prev.Shader, cmd.Shader)
+	}
+	if first || !slices.Equal(cmd.Textures, prev.Textures) {
+		b.WriteString("  textures:")
+		if len(cmd.Textures) == 0 {
+			b.WriteString(" none")
+		}
+		for _, slot := range cmd.Textures {
+			t := r.capture.Textures[slot.Texture]
+			fmt.Fprintf(&b, " [%d] %d (%dx%d format=%d config=%+v", slot.Slot, slot.Texture, t.Width, t.Height, t.Format, t.Config)
+			if slot.Sampler != "" {
+				fmt.Fprintf(&b, " sampler=%s", slot.Sampler)
+			}
+			b.WriteString(")")
+		}
+		b.WriteString("\n")
+	}
+	if first || cmd.Uniforms != prev.Uniforms {
+		fmt.Fprintf(&b, "  uniforms: %d -> %d", prev.Uniforms, cmd.Uniforms)
+		if cmd.Uniforms >= 0 {
+			u := r.capture.Uniforms[cmd.Uniforms]
+			for _, name := range slices.Sorted(maps.Keys(u)) {
+				fmt.Fprintf(&b, " %s=%v", name, u[name].Values)
+			}
+		}
+		b.WriteString("\n")
+	}
+	if first || cmd.Blend != prev.Blend {
+		fmt.Fprintf(&b, "  blend:    %d -> %d\n", prev.Blend, cmd.Blend)
+	}
+	if first || cmd.Depth != prev.Depth {
+		fmt.Fprintf(&b, "  depth:    %d -> %d\n", prev.Depth, cmd.Depth)
+	}
+	if first || cmd.Cull != prev.Cull {
+		fmt.Fprintf(&b, "  cull:     %d -> %d\n", prev.Cull, cmd.Cull)
+	}
+	if first || cmd.Stencil != prev.Stencil {
+		fmt.Fprintf(&b, "  stencil:  %+v\n", cmd.Stencil)
+	}
+	if first || cmd.Clipped != prev.Clipped || cmd.Clip != prev.Clip {
+		if cmd.Clipped {
+			fmt.Fprintf(&b, "  clip:     %v\n", cmd.Clip)
+		} else {
+			b.WriteString("  clip:     none\n")
+		}
+	}
+	fmt.Fprintf(&b, "  matrix:   %v\n", cmd.Matrix)
+	fmt.Fprintf(&b, "  mask:     %v\n", cmd.Mask)
+	return b.String()
+}
+
+func replayUniform(u CapturedUniform) (any, error) {
+	v := u.Values
+	switch {
+	case u.Type == shaders.AttrFloat && len(v) == 1:
+		return v[0], nil
+	case u.Type == shaders.AttrVec2 && len(v) == 2:
+		return Vec2{float64(v[0]), float64(v[1])}, nil
+	case u.Type == shaders.AttrVec3 && len(v) == 3:
+		return Vec3{float64(v[0]), float64(v[1]), float64(v[2])}, nil
+	case u.Type == shaders.AttrVec4 && len(v) == 4:
+		return Vec4{float64(v[0]), float64(v[1]), float64(v[2]), float64(v[3])}, nil
+	case u.Type == shaders.AttrMat4 && len(v) == 16:
+		return glMat4(v), nil
+	default:
+		return nil, fmt.Errorf("invalid uniform: %+v", u)
+	}
+}
+
+func replayMesh(g CapturedGeometry) *Mesh {
+	if len(g.Positions) == 0 || len(g.Indices) == 0 {
+		return nil
+	}
+
+	mesh := &Mesh{
+		positions: make([]glVec3, len(g.Positions)),
+		normals:   make([]glVec3, len(g.Normals)),
+		colors:    make([]glVec4, len(g.Colors)),
+		texCoords: make([]glVec2, len(g.TexCoords)),
+		indices:   slices.Clone(g.Indices),
+	}
+	for i := range g.Positions {
+		mesh.positions[i] = g.Positions[i]
+	}
+	for i := range g.Normals {
+		mesh.normals[i] = g.Normals[i]
+	}
+	for i := range g.Colors {
+		mesh.colors[i] = g.Colors[i]
+	}
+	for i := range g.TexCoords {
+		mesh.texCoords[i] = g.TexCoords[i]
+	}
+	mesh.bounds = positionBounds(mesh.positions)
+	return mesh
+}
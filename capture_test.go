@@ -0,0 +1,139 @@
+//go:build headless
+
+package glitch_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/unitoftime/flow/glm"
+	"github.com/unitoftime/glitch"
+	"github.com/unitoftime/glitch/shaders"
+)
+
+func TestReplayerRejectsMalformedCaptures(t *testing.T) {
+	triangle := glitch.CapturedGeometry{
+		Kind:      "mesh",
+		Positions: [][3]float32{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}},
+		Indices:   []uint32{0, 1, 2},
+	}
+	command := func(edit func(*glitch.CapturedCommand)) *glitch.Capture {
+		cmd := glitch.CapturedCommand{Target: -1, Shader: -1, Uniforms: -1, Geometry: triangle}
+		edit(&cmd)
+		return &glitch.Capture{Commands: []glitch.CapturedCommand{cmd}}
+	}
+
+	tests := []struct {
+		name    string
+		capture *glitch.Capture
+		want    string
+	}{
+		{"target", command(func(c *glitch.CapturedCommand) { c.Target = 0 }), "target 0 out of range"},
+		{"shader", command(func(c *glitch.CapturedCommand) { c.Shader = 2 }), "shader 2 out of range"},
+		{"uniforms", command(func(c *glitch.CapturedCommand) { c.Uniforms = -2 }), "uniforms -2 out of range"},
+		{"texture", command(func(c *glitch.CapturedCommand) {
+			c.Textures = []glitch.CapturedTextureSlot{{Slot: 0, Texture: 0}}
+		}), "texture 0 out of range"},
+		{"texture slot", command(func(c *glitch.CapturedCommand) {
+			c.Textures = []glitch.CapturedTextureSlot{{Slot: glitch.MaxTextureSlots}}
+		}), "texture slot 8 out of range"},
+		{"index", command(func(c *glitch.CapturedCommand) { c.Geometry.Indices = []uint32{0, 1, 3} }), "index 3 out of range"},
+		{"colors", command(func(c *glitch.CapturedCommand) { c.Geometry.Colors = [][4]float32{{1, 1, 1, 1}} }), "1 colors for 3 positions"},
+		{"normals", command(func(c *glitch.CapturedCommand) { c.Geometry.Normals = [][3]float32{{0, 0, 1}} }), "1 normals for 3 positions"},
+		{"tex coords", command(func(c *glitch.CapturedCommand) { c.Geometry.TexCoords = [][2]float32{{0, 0}} }), "1 tex coords for 3 positions"},
+		{"uniform", &glitch.Capture{Uniforms: []glitch.CapturedUniforms{
+			{"color": {Type: shaders.AttrVec4, Values: []float32{1, 1}}},
+		}}, "color: invalid uniform"},
+		{"pixels", &glitch.Capture{Textures: []glitch.CapturedTexture{
+			{Width: 2, Height: 2, Format: glitch.TextureFormatRGBA8, Pix: make([]byte, 4)},
+		}}, "wrong number of pixels"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := glitch.NewReplayer(test.capture)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestReplayerDeletesResources(t *testing.T) {
+	glitch.SetLeakTracking(true)
+	defer glitch.SetLeakTracking(false)
+
+	sprite := glitch.CapturedShader{
+		VertexSource:   shaders.SpriteShader.VertexShader,
+		FragmentSource: shaders.SpriteShader.FragmentShader,
+		VertexFormat:   shaders.SpriteShader.VertexFormat,
+		UniformFormat:  shaders.SpriteShader.UniformFormat,
+	}
+	broken := sprite
+	broken.VertexFormat = shaders.VertexFormat{shaders.VertexAttribute("missingIn", shaders.AttrVec3, shaders.PositionXYZ)}
+
+	// The first shader is created before the second one fails
+	_, err := glitch.NewReplayer(&glitch.Capture{Shaders: []glitch.CapturedShader{sprite, broken}})
+	if err == nil {
+		t.Fatal("replaying a capture with a broken shader didn't return an error")
+	}
+	if n := liveResources("shader"); n != 0 {
+		t.Errorf("got %d live shaders after failing to replay, want 0", n)
+	}
+
+	replayer, err := glitch.NewReplayer(&glitch.Capture{
+		Shaders:  []glitch.CapturedShader{sprite},
+		Textures: []glitch.CapturedTexture{{Width: 1, Height: 1, Pix: make([]byte, 4)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if liveResources("shader") != 1 || liveResources("texture") != 1 {
+		t.Fatalf("got %d live shaders and %d live textures, want 1 and 1", liveResources("shader"), liveResources("texture"))
+	}
+	replayer.Delete()
+	if liveResources("shader") != 0 || liveResources("texture") != 0 {
+		t.Errorf("got %d live shaders and %d live textures after deleting, want none", liveResources("shader"), liveResources("texture"))
+	}
+}
+
+func TestReplayedMeshBounds(t *testing.T) {
+	glitch.SetCulling(true)
+	defer glitch.SetCulling(false)
+
+	camera := glitch.NewCameraOrtho()
+	camera.SetOrtho2D(glm.R(0, 0, 16, 16))
+	camera.SetView2D(0, 0, 1, 1)
+	var projection, view [16]float32
+	for i := range 16 {
+		projection[i] = float32(camera.Projection[i])
+		view[i] = float32(camera.View[i])
+	}
+
+	// The mesh is outside of the camera, but the camera can see the origin
+	replayer, err := glitch.NewReplayer(&glitch.Capture{
+		Commands: []glitch.CapturedCommand{{
+			Target: -1, Shader: -1, Uniforms: -1,
+			Geometry: glitch.CapturedGeometry{
+				Kind:      "mesh",
+				Positions: [][3]float32{{100, 100, 0}, {101, 100, 0}, {100, 101, 0}},
+				Indices:   []uint32{0, 1, 2},
+			},
+			Matrix:     [16]float32{0: 1, 5: 1, 10: 1, 15: 1},
+			Mask:       glitch.White,
+			Projection: projection,
+			View:       view,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replayer.Delete()
+
+	frame := glitch.NewFrame(glm.R(0, 0, 16, 16), false)
+	defer frame.Delete()
+	glitch.EndStatsFrame()
+	replayer.Replay(frame)
+	if n := glitch.CurrentStats().Culled; n != 1 {
+		t.Errorf("culled %d replayed meshes, want 1", n)
+	}
+}
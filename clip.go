@@ -0,0 +1,58 @@
+package glitch
+
+import "math"
+
+// A clip rect in camera space (ie the same space as the geometry, before the camera is
+// applied). Everything outside of the rect is clipped with the scissor test.
+type clipRect struct {
+	rect    Rect
+	enabled bool // If false then nothing is clipped
+}
+
+// Returns the part of c that is also inside of rect
+func (c clipRect) intersect(rect Rect) clipRect {
+	if !c.enabled {
+		return clipRect{rect.Norm(), true}
+	}
+	r := c.rect
+	r.Min.X = max(r.Min.X, min(rect.Min.X, rect.Max.X))
+	r.Min.Y = max(r.Min.Y, min(rect.Min.Y, rect.Max.Y))
+	r.Max.X = max(r.Min.X, min(r.Max.X, max(rect.Min.X, rect.Max.X)))
+	r.Max.Y = max(r.Min.Y, min(r.Max.Y, max(rect.Min.Y, rect.Max.Y)))
+	return clipRect{r, true}
+}
+
+// Moves the clip rect by the matrix. Rotations give the bounding rect of the rotated clip
+func (c clipRect) transformed(m glMat4) clipRect {
+	if !c.enabled {
+		return c
+	}
+	a := m.Apply(glVec3{float32(c.rect.Min.X), float32(c.rect.Min.Y), 0})
+	b := m.Apply(glVec3{float32(c.rect.Max.X), float32(c.rect.Min.Y), 0})
+	d := m.Apply(glVec3{float32(c.rect.Min.X), float32(c.rect.Max.Y), 0})
+	e := m.Apply(glVec3{float32(c.rect.Max.X), float32(c.rect.Max.Y), 0})
+	c.rect = Rect{
+		Min: Vec2{float64(min(a[0], b[0], d[0], e[0])), float64(min(a[1], b[1], d[1], e[1]))},
+		Max: Vec2{float64(max(a[0], b[0], d[0], e[0])), float64(max(a[1], b[1], d[1], e[1]))},
+	}
+	return c
+}
+
+// Projects the clip rect through the camera onto a framebuffer of size bounds, returning the
+// scissor box (x, y, w, h) in pixels.
+func (c clipRect) scissorBox(camera CameraMaterial, bounds Rect) [4]int32 {
+	mvp := camera.Projection
+	mvp.Mul(&camera.View)
+
+	a := mvp.Apply(glVec3{float32(c.rect.Min.X), float32(c.rect.Min.Y), 0})
+	b := mvp.Apply(glVec3{float32(c.rect.Max.X), float32(c.rect.Max.Y), 0})
+
+	// Normalized device coordinates to pixels, the viewport always covers the whole framebuffer
+	w, h := bounds.W(), bounds.H()
+	x0 := math.Round(float64(min(a[0], b[0])+1) / 2 * w)
+	y0 := math.Round(float64(min(a[1], b[1])+1) / 2 * h)
+	x1 := math.Round(float64(max(a[0], b[0])+1) / 2 * w)
+	y1 := math.Round(float64(max(a[1], b[1])+1) / 2 * h)
+
+	return [4]int32{int32(x0), int32(y0), int32(x1 - x0), int32(y1 - y0)}
+}
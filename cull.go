@@ -0,0 +1,61 @@
+package glitch
+
+// Returns false if bounds, transformed by mat, is entirely outside of the view volume of camera.
+// Bounds that are empty are treated as unknown, so they are always visible.
+func (c CameraMaterial) visible(bounds Box, mat glMat4) bool {
+	if bounds == (Box{}) {
+		return true
+	}
+
+	mvp := c.Projection
+	mvp.Mul(&c.View)
+	mvp.Mul(&mat)
+
+	lo, hi := glv3(bounds.Min), glv3(bounds.Max)
+	corners := [8]glVec3{
+		{lo[0], lo[1], lo[2]},
+		{hi[0], lo[1], lo[2]},
+		{lo[0], hi[1], lo[2]},
+		{hi[0], hi[1], lo[2]},
+		{lo[0], lo[1], hi[2]},
+		{hi[0], lo[1], hi[2]},
+		{lo[0], hi[1], hi[2]},
+		{hi[0], hi[1], hi[2]},
+	}
+
+	// One bit per clip plane (-x, +x, -y, +y, -z, +z) that every corner is outside of.
+	// The box can only be culled if all of its corners are outside of the same plane.
+	outside := uint8(0b111111)
+	for _, v := range corners {
+		x := mvp[i4_0_0]*v[0] + mvp[i4_1_0]*v[1] + mvp[i4_2_0]*v[2] + mvp[i4_3_0]
+		y := mvp[i4_0_1]*v[0] + mvp[i4_1_1]*v[1] + mvp[i4_2_1]*v[2] + mvp[i4_3_1]
+		z := mvp[i4_0_2]*v[0] + mvp[i4_1_2]*v[1] + mvp[i4_2_2]*v[2] + mvp[i4_3_2]
+		w := mvp[i4_0_3]*v[0] + mvp[i4_1_3]*v[1] + mvp[i4_2_3]*v[2] + mvp[i4_3_3]
+
+		var planes uint8
+		if x < -w {
+			planes |= 1 << 0
+		}
+		if x > w {
+			planes |= 1 << 1
+		}
+		if y < -w {
+			planes |= 1 << 2
+		}
+		if y > w {
+			planes |= 1 << 3
+		}
+		if z < -w {
+			planes |= 1 << 4
+		}
+		if z > w {
+			planes |= 1 << 5
+		}
+
+		outside &= planes
+		if outside == 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -52,12 +52,13 @@ func GetDefaultSpriteShader() *Shader {
 	if err != nil {
 		panic(err)
 	}
+	untrackResource(defaultSpriteShader.leakID) // Owned by glitch
 	return defaultSpriteShader
 }
 
 func DefaultMaterial(texture *Texture) Material {
 	material := NewMaterial(GetDefaultSpriteShader())
-	material.texture = texture
+	material.SetTexture(texture)
 	return material
 }
 
@@ -77,13 +78,14 @@ func GetDefaultMsdfShader() *Shader {
 	if err != nil {
 		panic(err)
 	}
+	untrackResource(defaultMsdfShader.leakID) // Owned by glitch
 	return defaultMsdfShader
 }
 
 func DefaultMsdfMaterial(texture *Texture) Material {
 	material := NewMaterial(GetDefaultMsdfShader())
 	material.SetBlendMode(BlendModeNormal)
-	material.texture = texture
+	material.SetTexture(texture)
 	material.
 		SetUniform("u_threshold", 0.5).
 		SetUniform("u_outline_width_relative", 0.1).
@@ -91,3 +93,29 @@ func DefaultMsdfMaterial(texture *Texture) Material {
 		SetUniform("u_outline_color", RGBA{0, 0, 0, 1})
 	return material
 }
+
+var defaultSpriteInstancedShader *Shader // Can set this to whatever you want
+
+func SetDefaultSpriteInstancedShader(shader *Shader) {
+	defaultSpriteInstancedShader = shader
+}
+
+func GetDefaultSpriteInstancedShader() *Shader {
+	if defaultSpriteInstancedShader != nil {
+		return defaultSpriteInstancedShader
+	}
+
+	var err error
+	defaultSpriteInstancedShader, err = NewShader(shaders.SpriteInstancedShader)
+	if err != nil {
+		panic(err)
+	}
+	untrackResource(defaultSpriteInstancedShader.leakID) // Owned by glitch
+	return defaultSpriteInstancedShader
+}
+
+func DefaultInstancedMaterial(texture *Texture) Material {
+	material := NewMaterial(GetDefaultSpriteInstancedShader())
+	material.SetTexture(texture)
+	return material
+}
@@ -19,6 +19,7 @@ import (
 
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to `file`")
 var memprofile = flag.String("memprofile", "", "write memory profile to `file`")
+var instanced = flag.Bool("instanced", false, "draw the gophers with a single instanced draw call")
 
 func main() {
 	flag.Parse()
@@ -73,9 +74,16 @@ func runGame() {
 	}
 	texture := glitch.NewTexture(manImage, false)
 	manSprite := glitch.NewSprite(texture, texture.Bounds())
+	manInstanced, err := glitch.NewInstancedSprite(texture, texture.Bounds())
+	if err != nil {
+		panic(err)
+	}
 
 	// length := 25_000 // With no sort
 	length := 6_250 // With SoftwareSort
+	if *instanced {
+		length = 100_000
+	}
 	man := make([]Man, length)
 	for i := range man {
 		man[i] = NewMan()
@@ -138,9 +146,14 @@ func runGame() {
 		glitch.Clear(win, glitch.RGBA{R: 0.1, G: 0.2, B: 0.3, A: 1.0})
 
 		// geom.Clear()
+		manInstanced.Clear()
 		for i := range man {
 			mat = glitch.Mat4Ident
 			mat.Scale(0.25, 0.25, 1.0).Translate(man[i].position.X, man[i].position.Y, 0)
+			if *instanced {
+				manInstanced.Add(mat, man[i].color)
+				continue
+			}
 			manSprite.DrawColorMask(sorter, mat, man[i].color)
 			// geom.DrawRect(pass, geomRect, mat, man[i].color)
 			// geomMesh.DrawColorMask(pass, mat, man[i].color)
@@ -161,6 +174,9 @@ func runGame() {
 			// fmt.Printf("%+v\n", metrics)
 		}
 
+		if *instanced {
+			manInstanced.Draw(win, glitch.Mat4Ident)
+		}
 		sorter.Draw(win)
 		text.DrawColorMask(win, glitch.Mat4Ident, glitch.White)
 
@@ -322,7 +322,7 @@ func runGame() {
 			scrollbarBounds := panelBounds.CutRight(50)
 			scrollTotal := 10
 			drawTotal := 5
-			ui.Scrollbar(&scrollIdx, scrollTotal-drawTotal, scrollbarBounds, panelBounds)
+			ui.BeginScrollbar(&scrollIdx, scrollTotal-drawTotal, scrollbarBounds, panelBounds)
 
 			list := ui.VList2(panelBounds.Unpad(glm.R(5, 5, 5, 5)), 100)
 			for i := scrollIdx; i < scrollIdx+drawTotal; i++ {
@@ -331,6 +331,7 @@ func runGame() {
 					fmt.Println("Click:", str)
 				}
 			}
+			ui.EndScroll()
 		case "text":
 			ui.Panel2("##panel", panelBounds)
 			wrappedText := "Unfinished: Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua. Ut enim ad minim veniam, quis nostrud exercitation ullamco laboris nisi ut aliquip ex ea commodo consequat. Duis aute irure dolor in reprehenderit in voluptate velit esse cillum dolore eu fugiat nulla pariatur. Excepteur sint occaecat cupidatat non proident, sunt in culpa qui officia deserunt mollit anim id est laborum."
@@ -1,6 +1,7 @@
 package glitch
 
 import (
+	"fmt"
 	"runtime"
 
 	"github.com/unitoftime/flow/glm"
@@ -10,42 +11,127 @@ import (
 
 type Frame struct {
 	fbo      gl.Framebuffer
-	tex      *Texture
-	depth    gl.Texture
+	textures []*Texture // One per color attachment
+	depth    gl.Texture // Zero if the frame doesn't have a depth attachment
 	mesh     *Mesh
 	material Material
 	bounds   Rect
+
+	// Multisampled frames draw into renderbuffers, which are resolved into the textures
+	samples  int
+	msFbo    gl.Framebuffer
+	msColors []gl.Renderbuffer
+	msDepth  gl.Renderbuffer
+	dirty    bool // Set if the frame may have been drawn to since it was last resolved
+
+	deleted  bool
+	leakID   uint64   // The id in the leak registry, see SetLeakTracking
+	targetID TargetID // The id that the frame's draw calls are counted under, see FrameStats
+}
+
+// FrameConfig configures the attachments that a Frame is created with
+type FrameConfig struct {
+	Smooth bool // Indicates the color attachments should be sampled with linear filtering
+
+	// The format of each color attachment. Attachment i is written by fragment shader output
+	// location i. Defaults to a single TextureFormatRGBA8 attachment. WebGL1 only supports
+	// the default, NewFrameConfig returns an error for the other formats there
+	Colors []TextureFormat
+
+	NoDepth bool // Indicates the frame shouldn't have a depth and stencil attachment
+
+	// The number of samples per pixel for multisample antialiasing. Values above 1 draw into
+	// multisampled renderbuffers, which are resolved into the textures (see Frame.Resolve)
+	Samples int
 }
 
-// Type? Color, depth, stencil?
 func NewFrame(bounds Rect, smooth bool) *Frame {
+	return newFrameConfig(bounds, FrameConfig{
+		Smooth: smooth,
+	})
+}
+
+// NewFrameConfig creates a frame with the attachments of config. It returns an error if one of
+// the color formats is a depth format, or isn't supported by the context.
+func NewFrameConfig(bounds Rect, config FrameConfig) (*Frame, error) {
+	err := config.validate()
+	if err != nil {
+		return nil, fmt.Errorf("new frame: %w", err)
+	}
+	return newFrameConfig(bounds, config), nil
+}
+
+// Returns an error if the frame can't be created with the config
+func (c FrameConfig) validate() error {
+	for _, format := range c.Colors {
+		if format.depth() {
+			return fmt.Errorf("color attachments can't use a depth format")
+		}
+		err := format.supported()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Creates the frame of a config that has already been validated
+func newFrameConfig(bounds Rect, config FrameConfig) *Frame {
+	colors := config.Colors
+	if len(colors) == 0 {
+		colors = []TextureFormat{TextureFormatRGBA8}
+	}
+
 	var frame = &Frame{
-		bounds: bounds,
+		bounds:   bounds,
+		samples:  config.Samples,
+		targetID: nextTargetID(),
 	}
 
-	// Create texture
-	frame.tex = NewEmptyTexture(int(bounds.W()), int(bounds.H()), smooth)
+	// Create textures
+	width, height := int(bounds.W()), int(bounds.H())
+	frame.textures = make([]*Texture, len(colors))
+	for i, format := range colors {
+		frame.textures[i] = newEmptyTexture(width, height, smoothTextureConfig(config.Smooth), format)
+	}
 
 	// Create mesh (in case we want to draw the fbo to another target)
 	frame.mesh = NewQuadMesh(bounds, glm.R(0, 1, 1, 0))
 	frame.material = NewMaterial(GetDefaultSpriteShader())
-	frame.material.texture = frame.tex
+	frame.material.SetTexture(frame.textures[0])
 
 	mainthread.Call(func() {
 		frame.fbo = gl.CreateFramebuffer()
 		gl.BindFramebuffer(gl.FRAMEBUFFER, frame.fbo)
-		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, frame.tex.texture, 0)
+
+		drawBuffers := make([]gl.Enum, len(frame.textures))
+		for i, tex := range frame.textures {
+			drawBuffers[i] = gl.Enum(gl.COLOR_ATTACHMENT0 + i)
+			gl.FramebufferTexture2D(gl.FRAMEBUFFER, drawBuffers[i], gl.TEXTURE_2D, tex.texture, 0)
+		}
+		if len(drawBuffers) > 1 {
+			gl.DrawBuffers(drawBuffers)
+		}
 
 		// https://webgl2fundamentals.org/webgl/lessons/webgl-render-to-texture.html
 		// TODO - maybe centralize this into texture creation api
-		// TODO - make fbo depth attachment optional
-		frame.depth = gl.CreateTexture()
-		gl.BindTexture(gl.TEXTURE_2D, frame.depth)
-		// gl.TexImage2DFull(gl.TEXTURE_2D, 0, gl.DEPTH24_STENCIL8, frame.tex.width, frame.tex.height, gl.DEPTH_STENCIL, gl.UNSIGNED_INT_24_8, nil)
-		gl.TexImage2DFull(gl.TEXTURE_2D, 0, gl.DEPTH_COMPONENT24, frame.tex.width, frame.tex.height, gl.DEPTH_COMPONENT, gl.UNSIGNED_INT, nil)
-		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.TEXTURE_2D, frame.depth, 0)
+		if !config.NoDepth && frame.samples <= 1 {
+			frame.depth = gl.CreateTexture()
+			gl.BindTexture(gl.TEXTURE_2D, frame.depth)
+			// gl.TexImage2DFull(gl.TEXTURE_2D, 0, gl.DEPTH_COMPONENT24, width, height, gl.DEPTH_COMPONENT, gl.UNSIGNED_INT, nil)
+			gl.TexImage2DFull(gl.TEXTURE_2D, 0, gl.DEPTH24_STENCIL8, width, height, gl.DEPTH_STENCIL, gl.UNSIGNED_INT_24_8, nil)
+			gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.DEPTH_STENCIL_ATTACHMENT, gl.TEXTURE_2D, frame.depth, 0)
+			state.mainthreadRestoreTexture()
+		}
+
+		if frame.samples > 1 {
+			frame.mainthreadCreateMultisample(colors, !config.NoDepth)
+		}
+
+		gl.BindFramebuffer(gl.FRAMEBUFFER, state.fbo)
 	})
 
+	frame.leakID = trackResource("frame")
 	runtime.SetFinalizer(frame, (*Frame).delete)
 	return frame
 }
@@ -54,14 +140,109 @@ func (f *Frame) Bounds() Rect {
 	return f.bounds
 }
 
-func (f *Frame) Texture() *Texture {
-	return f.tex
+// Creates the multisampled framebuffer that the frame draws into
+func (f *Frame) mainthreadCreateMultisample(colors []TextureFormat, depth bool) {
+	width, height := int(f.bounds.W()), int(f.bounds.H())
+
+	f.msFbo = gl.CreateFramebuffer()
+	f.msDepth = gl.NoRenderbuffer
+	gl.BindFramebuffer(gl.FRAMEBUFFER, f.msFbo)
+
+	drawBuffers := make([]gl.Enum, len(colors))
+	f.msColors = make([]gl.Renderbuffer, len(colors))
+	for i, format := range colors {
+		drawBuffers[i] = gl.Enum(gl.COLOR_ATTACHMENT0 + i)
+		f.msColors[i] = gl.CreateRenderbuffer()
+		gl.BindRenderbuffer(gl.RENDERBUFFER, f.msColors[i])
+		gl.RenderbufferStorageMultisample(gl.RENDERBUFFER, f.samples, textureFormatLut[format].sizedFormat, width, height)
+		gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, drawBuffers[i], gl.RENDERBUFFER, f.msColors[i])
+	}
+	if len(drawBuffers) > 1 {
+		gl.DrawBuffers(drawBuffers)
+	}
+
+	if depth {
+		f.msDepth = gl.CreateRenderbuffer()
+		gl.BindRenderbuffer(gl.RENDERBUFFER, f.msDepth)
+		gl.RenderbufferStorageMultisample(gl.RENDERBUFFER, f.samples, gl.DEPTH24_STENCIL8, width, height)
+		gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_STENCIL_ATTACHMENT, gl.RENDERBUFFER, f.msDepth)
+	}
+	gl.BindRenderbuffer(gl.RENDERBUFFER, gl.NoRenderbuffer)
+}
+
+// Texture returns the texture of color attachment i, which can be used in a Material.
+// Note: Call Resolve before sampling the texture of a multisampled frame
+func (f *Frame) Texture(i int) *Texture {
+	return f.textures[i]
+}
+
+// Samples returns the number of samples per pixel, or 0 if the frame isn't multisampled
+func (f *Frame) Samples() int {
+	if f.samples <= 1 {
+		return 0
+	}
+	return f.samples
+}
+
+// Resolve copies the multisampled attachments into the frame's textures. It does nothing if the
+// frame isn't multisampled or hasn't been drawn to since the last resolve.
+// Drawing the frame and reading it back with Image resolve automatically, so this only needs to
+// be called before sampling the textures in another Material.
+func (f *Frame) Resolve() {
+	if f.samples <= 1 {
+		return
+	}
+	if !f.dirty && global.target != Target(f) {
+		return // Skip: Nothing new has been drawn
+	}
+
+	global.flush()
+	f.dirty = false
+	mainthread.Call(f.mainthreadResolve)
+}
+
+func (f *Frame) mainthreadResolve() {
+	// Note: Blits are clipped by the scissor test
+	if state.scissorEnabled {
+		gl.Disable(gl.SCISSOR_TEST)
+	}
+
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, f.msFbo)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, f.fbo)
+
+	width, height := int32(f.bounds.W()), int32(f.bounds.H())
+	drawBuffers := make([]gl.Enum, len(f.textures))
+	for i := range f.textures {
+		// Blits write to every draw buffer, so only enable the one being resolved
+		attachment := gl.Enum(gl.COLOR_ATTACHMENT0 + i)
+		gl.ReadBuffer(attachment)
+		if len(drawBuffers) > 1 {
+			for n := range drawBuffers {
+				drawBuffers[n] = gl.NONE
+			}
+			drawBuffers[i] = attachment
+			gl.DrawBuffers(drawBuffers)
+		}
+		gl.BlitFramebuffer(0, 0, width, height, 0, 0, width, height, gl.COLOR_BUFFER_BIT, gl.NEAREST)
+	}
+	gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, state.fbo)
+	if state.scissorEnabled {
+		gl.Enable(gl.SCISSOR_TEST)
+	}
+}
+
+// NumTextures returns the number of color attachments
+func (f *Frame) NumTextures() int {
+	return len(f.textures)
 }
 
 func (f *Frame) Draw(target BatchTarget, matrix Mat4) {
 	f.DrawColorMask(target, matrix, RGBA{1.0, 1.0, 1.0, 1.0})
 }
 func (f *Frame) DrawColorMask(target BatchTarget, matrix Mat4, mask RGBA) {
+	f.Resolve()
 	// pass.SetTexture(0, s.texture)
 	target.Add(f.mesh.g(), glm4(matrix), mask, f.material)
 }
@@ -79,13 +260,59 @@ func (f *Frame) RectDrawColorMask(target BatchTarget, bounds Rect, mask RGBA) {
 	f.DrawColorMask(target, matrix, mask)
 }
 
+// Delete frees the frame and its textures on the GPU. The deletion is queued onto the
+// mainthread, after anything that has already been drawn into the frame. The frame and its
+// textures can't be used afterwards.
+func (f *Frame) Delete() {
+	if f.deleted {
+		return
+	}
+	runtime.SetFinalizer(f, nil)
+
+	global.flush() // Draw anything that is still batched into the frame
+	if global.target == f {
+		global.target = nil
+	}
+	if state.fbo.Equal(f.fbo) || (f.samples > 1 && state.fbo.Equal(f.msFbo)) {
+		state.fbo = gl.NoFramebuffer // GL binds the default framebuffer when the bound one is deleted
+	}
+	f.delete()
+
+	for _, t := range f.textures {
+		t.Delete()
+	}
+}
+
 func (f *Frame) delete() {
+	if f.deleted {
+		return
+	}
+	f.deleted = true
+	untrackResource(f.leakID)
+
 	mainthread.CallNonBlock(func() {
 		gl.DeleteFramebuffer(f.fbo)
+		if f.depth.Valid() {
+			gl.DeleteTexture(f.depth)
+		}
+		if f.samples > 1 {
+			gl.DeleteFramebuffer(f.msFbo)
+			for _, rb := range f.msColors {
+				gl.DeleteRenderbuffer(rb)
+			}
+			if f.msDepth.Valid() {
+				gl.DeleteRenderbuffer(f.msDepth)
+			}
+		}
 	})
 }
 
 func (f *Frame) Bind() {
+	if f.samples > 1 {
+		f.dirty = true
+		state.bindFramebuffer(f.msFbo, f.bounds)
+		return
+	}
 	state.bindFramebuffer(f.fbo, f.bounds)
 }
 
@@ -158,6 +158,7 @@ func (g *GeomDraw) FillRect2(mesh *Mesh, rect Rect, mat glMat4) {
 			glVec3{float32(min[0]), float32(min[1]), float32(min[2])},
 			glVec3{float32(min[0]), float32(max[1]), float32(min[2])},
 		)
+		mesh.bounds = mesh.bounds.Union(positionBounds(mesh.positions[len(mesh.positions)-4:]))
 	}
 
 	{
@@ -212,6 +213,7 @@ func (g *GeomDraw) FillRect(rect Rect) *Mesh {
 		colors:    colors,
 		texCoords: texCoords,
 		indices:   inds,
+		bounds:    rect.ToBox(),
 	}
 }
 
@@ -437,7 +439,7 @@ func (g *GeomDraw) Line(mesh *Mesh, a, b Vec3, lastAngle, nextAngle float64, wid
 	mesh.colors = append(mesh.colors, colors...)
 	mesh.texCoords = append(mesh.texCoords, texCoords...)
 
-	// mesh.bounds = mesh.bounds.Union(bounds.ToBox()) // TODO - add back
+	mesh.bounds = mesh.bounds.Union(positionBounds(positions))
 }
 
 // Point generation functions:
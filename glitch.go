@@ -2,6 +2,7 @@ package glitch
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/unitoftime/glitch/internal/glfw"
 	"github.com/unitoftime/glitch/internal/mainthread"
@@ -11,6 +12,8 @@ func Run(function func()) {
 	f := func() {
 		function()
 
+		ReportLeaks(os.Stderr) // Only reports anything if leak tracking is enabled
+
 		// Perform any cleanup operations
 		mainthread.Call(func() {
 			glfw.Terminate()
@@ -79,12 +82,14 @@ func (u *Uniforms) Copy() *Uniforms {
 // Uniform: uniform slot lut ID 256 maximum
 type Material struct {
 	shader   *Shader
-	texture  *Texture
+	textures [MaxTextureSlots]textureSlot
 	uniforms *Uniforms // TODO: Generic binder (eg old Material interface)?
 
-	blend BlendMode
-	depth DepthMode
-	cull  CullMode
+	blend   BlendMode
+	depth   DepthMode
+	cull    CullMode
+	stencil StencilMode
+	clip    clipRect // Set by Sorter.PushClip and DrawBatch.PushClip
 }
 
 func NewMaterial(shader *Shader) Material {
@@ -122,8 +127,37 @@ func (m *Material) SetUniform(name string, val any) *Material {
 	return m
 }
 
-func (m *Material) SetTexture( /* slot int, */ texture *Texture) {
-	m.texture = texture
+// MaxTextureSlots is the number of textures that a material can bind at once
+const MaxTextureSlots = 8
+
+// A texture bound to a texture unit, and the sampler uniform that reads from that unit
+type textureSlot struct {
+	texture *Texture
+	sampler string // If empty then no sampler uniform is set (ie it keeps reading from unit 0)
+}
+
+// SetTexture sets the texture in slot 0, which the default shaders sample from
+func (m *Material) SetTexture(texture *Texture) {
+	m.textures[0].texture = texture
+}
+
+// SetTextureSlot binds texture to the texture unit of slot, and points the shader's sampler
+// uniform at that unit. For example a diffuse map in slot 0 and a normal map in slot 1.
+// Set texture to nil to clear the slot.
+func (m *Material) SetTextureSlot(slot int, sampler string, texture *Texture) *Material {
+	if slot < 0 || slot >= MaxTextureSlots {
+		panic(fmt.Sprintf("SetTextureSlot: slot %d out of range, there are %d slots", slot, MaxTextureSlots))
+	}
+	if texture == nil {
+		sampler = ""
+	}
+	m.textures[slot] = textureSlot{texture, sampler}
+	return m
+}
+
+// Texture returns the texture in slot, or nil if the slot is empty
+func (m *Material) Texture(slot int) *Texture {
+	return m.textures[slot].texture
 }
 
 func (m *Material) SetCullMode(cullMode CullMode) *Material {
@@ -141,20 +175,33 @@ func (m *Material) SetBlendMode(blendMode BlendMode) *Material {
 	return m
 }
 
+// SetStencilMode sets how the material uses the stencil buffer.
+// Note: Masks only work if they are drawn before the things they mask, so draw them in an
+// earlier batch (eg a sorter layer that is drawn first)
+func (m *Material) SetStencilMode(stencilMode StencilMode) *Material {
+	m.stencil = stencilMode
+	return m
+}
+
 func (m Material) Bind() {
 	setShader(m.shader)
 	// m.shader.Use()
 
-	if m.texture != nil {
-		// texSlot := 0
-		// m.texture.bind(texSlot)
-
-		state.bindTexture(m.texture)
+	for slot, t := range m.textures {
+		if t.texture == nil {
+			continue
+		}
+		state.bindTexture(slot, t.texture)
+		if t.sampler != "" {
+			m.shader.setSampler(t.sampler, slot)
+		}
 	}
 
 	state.setBlendMode(m.blend)
 	state.setDepthMode(m.depth)
 	state.setCullMode(m.cull)
+	state.setStencilMode(m.stencil)
+	state.setClip(m.clip)
 
 	// // Bind Depthmode
 	// if m.depth == DepthModeNone {
@@ -213,6 +260,8 @@ type Metrics struct {
 	vertsTotal int // The total number of vertices drawn
 	vertsAvg   int // The average number of vertices drawn per drawCall
 
+	culled int // The number of commands that were skipped because they were outside of the camera
+
 	// Note: Disabled because this didn't really give me any insight
 	// vertsMin int
 	// vertsMax int
@@ -225,6 +274,12 @@ func GetMetrics() Metrics {
 	return metric
 }
 
+// Culled returns the number of draw commands that were skipped by the Sorter or the global
+// batcher because they were outside of the camera's view
+func (m Metrics) Culled() int {
+	return m.culled
+}
+
 // --------------------------------------------------------------------------------
 type CameraMaterial struct {
 	Projection, View glMat4
@@ -246,12 +301,15 @@ type globalBatcher struct {
 	lastBuffer *VertexBuffer
 	target     Target
 	blend      BlendMode
+	cull       bool
 
 	material Material
 
 	shaderCache map[*Shader]struct{}
 
-	metric Metrics
+	metric  Metrics
+	stats   FrameStats   // The stats of the current frame
+	history *RenderStats // The recorded frames, nil if stats aren't enabled
 }
 
 func Clear(target Target, color RGBA) {
@@ -280,15 +338,19 @@ func SetCameraMaterial(camMaterial CameraMaterial) {
 		return
 	}
 
-	if global.shader != nil {
-		global.flush() // TODO: You technically only need to do this if it will change the uniform
-		global.camera = camMaterial
+	if global.shader == nil {
+		global.camera = camMaterial // Uploaded when the first shader is set
+		return
+	}
 
-		global.shader.setUniformMat4("projection", global.camera.Projection)
-		global.shader.setUniformMat4("view", global.camera.View)
+	global.flush() // TODO: You technically only need to do this if it will change the uniform
+	global.camera = camMaterial
 
-		global.metric.setCamera++
-	}
+	global.shader.setUniformMat4("projection", global.camera.Projection)
+	global.shader.setUniformMat4("view", global.camera.View)
+	state.updateScissor() // The clip rect is in camera space
+
+	global.metric.setCamera++
 }
 
 func SetCamera(camera *CameraOrtho) {
@@ -299,6 +361,13 @@ func SetCamera(camera *CameraOrtho) {
 	SetCameraMaterial(camMaterial)
 }
 
+// SetCulling enables or disables culling of the geometry that is drawn directly to a target.
+// When enabled, commands whose bounds are entirely outside of the current camera's view are
+// skipped before they are filled. The skipped commands are counted in Metrics.
+func SetCulling(enable bool) {
+	global.cull = enable
+}
+
 func setTarget(target Target) {
 	if global.target == target {
 		return
@@ -325,6 +394,7 @@ func setShader(shader *Shader) {
 
 	global.shaderCache[shader] = struct{}{}
 	global.metric.setShader++
+	global.stats.ShaderSwitches++
 }
 
 func (g *globalBatcher) Add(filler GeometryFiller, mat glMat4, mask RGBA, material Material) {
@@ -332,8 +402,18 @@ func (g *globalBatcher) Add(filler GeometryFiller, mat glMat4, mask RGBA, materi
 		return
 	} // Skip nil meshes
 
+	if g.cull && !g.camera.visible(filler.Bounds(), mat) {
+		global.metric.culled++
+		global.stats.Culled++
+		return
+	}
+
 	global.metric.add++
 
+	if capture.current != nil {
+		captureCommand(filler, mat, mask, material)
+	}
+
 	// 1. If you switch materials, then draw the last one
 	if material != g.material {
 		// fmt.Printf("setmaterial (old -> new):\n%+v\n%+v\n", g.material, material)
@@ -347,13 +427,18 @@ func (g *globalBatcher) Add(filler GeometryFiller, mat glMat4, mask RGBA, materi
 
 	buffer := filler.GetBuffer()
 	if buffer != nil {
+		if buffer.instances != nil {
+			g.shader.setInstanceMask(mask)
+		}
 		global.drawCall(buffer, mat)
 		return
 	}
 
 	// Note: Captured in shader.pool
 	// 1. If you fill up then draw the last one
+	start := g.statsTime()
 	vertexBuffer := filler.Fill(global.shader.pool, mat, mask)
+	g.statsSince(&g.stats.FillTime, start)
 
 	// If vertexBuffer has changed then we want to draw the last one
 	if global.lastBuffer != nil && vertexBuffer != global.lastBuffer {
@@ -365,6 +450,7 @@ func (g *globalBatcher) Add(filler GeometryFiller, mat glMat4, mask RGBA, materi
 
 func (g *globalBatcher) finish() {
 	g.flush()
+	g.stats.BuffersInUse = max(g.stats.BuffersInUse, g.poolBuffers(false))
 	for shader := range g.shaderCache {
 		shader.pool.Clear()
 	}
@@ -397,9 +483,17 @@ func (g *globalBatcher) drawCall(buffer *VertexBuffer, mat glMat4) {
 		panic("Error setting model uniform - all shaders must have 'model' uniform")
 	}
 
+	start := g.statsTime()
 	buffer.Draw()
+	g.statsSince(&g.stats.SubmitTime, start)
 	g.metric.draw++
 
+	g.stats.DrawCalls++
+	if g.stats.DrawCallsPerTarget == nil {
+		g.stats.DrawCallsPerTarget = make(map[TargetID]int)
+	}
+	g.stats.DrawCallsPerTarget[TargetIDOf(g.target)]++
+
 	vertCount := int(buffer.numVerts)
 	g.metric.vertsTotal += vertCount
 	g.metric.vertsAvg = g.metric.vertsTotal / g.metric.draw
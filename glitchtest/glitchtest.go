@@ -0,0 +1,227 @@
+// Package glitchtest renders scenes into a glitch.Frame and compares them against golden
+// PNG images checked in next to the tests.
+//
+// Tests need a GL context, run them against the software rasterizer with:
+//
+//	go test -tags headless ./...
+//
+// And regenerate the golden images after an intended change by setting GLITCHTEST_UPDATE:
+//
+//	GLITCHTEST_UPDATE=1 go test -tags headless ./...
+//
+// A minimal test looks like:
+//
+//	func TestMain(m *testing.M) {
+//		glitchtest.Main(m)
+//	}
+//
+//	func TestSprite(t *testing.T) {
+//		scene := glitchtest.NewScene(64, 64)
+//		sprite.RectDraw(scene.Sorter, glm.R(16, 16, 48, 48))
+//		glitchtest.AssertGolden(t, scene.Render(), "sprite", 0)
+//	}
+package glitchtest
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/unitoftime/flow/glm"
+	"github.com/unitoftime/glitch"
+)
+
+// UpdateEnv is the environment variable that makes AssertGolden overwrite the golden images
+// with the rendered output, when it is set to anything other than "" or "0". It's an environment
+// variable rather than a flag so that it can be set for every package in ./... at once.
+const UpdateEnv = "GLITCHTEST_UPDATE"
+
+func updating() bool {
+	v := os.Getenv(UpdateEnv)
+	return v != "" && v != "0"
+}
+
+// GoldenDir is the directory that golden images are read from and written to
+var GoldenDir = "testdata"
+
+// Main runs the tests on the glitch mainthread. Call it from TestMain.
+func Main(m *testing.M) {
+	code := 0
+	glitch.Run(func() {
+		code = m.Run()
+	})
+	os.Exit(code)
+}
+
+// Scene is an offscreen target with everything needed to draw into it
+type Scene struct {
+	Frame  *glitch.Frame
+	Sorter *glitch.Sorter
+	Camera *glitch.CameraOrtho
+	Shader *glitch.Shader // The default sprite shader
+	Clear  glitch.RGBA    // The color the frame is cleared to before each render
+}
+
+// NewScene creates a width x height scene with a 2D camera where one unit is one pixel,
+// with the origin in the bottom left.
+func NewScene(width, height int) *Scene {
+	bounds := glm.R(0, 0, float64(width), float64(height))
+
+	camera := glitch.NewCameraOrtho()
+	camera.SetOrtho2D(bounds)
+	camera.SetView2D(0, 0, 1, 1)
+
+	return &Scene{
+		Frame:  glitch.NewFrame(bounds, false),
+		Sorter: glitch.NewSorter(),
+		Camera: camera,
+		Shader: glitch.GetDefaultSpriteShader(),
+		Clear:  glitch.RGBA{0, 0, 0, 0},
+	}
+}
+
+// Bounds returns the bounds of the scene's frame
+func (s *Scene) Bounds() glm.Rect {
+	return s.Frame.Bounds()
+}
+
+// Render draws everything that was added to the sorter into the frame and reads it back
+func (s *Scene) Render() *image.RGBA {
+	glitch.Clear(s.Frame, s.Clear)
+	glitch.SetCamera(s.Camera)
+	s.Sorter.Draw(s.Frame)
+	return s.Frame.Image()
+}
+
+// AssertGolden compares img against the golden image GoldenDir/name.png. Every channel of
+// every pixel may differ by at most tolerance. On failure the rendered image and a diff image
+// are written next to the golden image, as name.got.png and name.diff.png.
+//
+// If UpdateEnv is set then the golden image is overwritten instead.
+func AssertGolden(t testing.TB, img *image.RGBA, name string, tolerance uint8) {
+	t.Helper()
+
+	path := filepath.Join(GoldenDir, name+".png")
+	if updating() {
+		err := writePNG(path, img)
+		if err != nil {
+			t.Fatalf("glitchtest: updating golden image: %v", err)
+		}
+		return
+	}
+
+	want, err := readPNG(path)
+	if err != nil {
+		t.Fatalf("glitchtest: reading golden image (set %s=1 to create it): %v", UpdateEnv, err)
+	}
+
+	diff, mismatched := Compare(img, want, tolerance)
+	if mismatched == 0 {
+		return
+	}
+
+	gotPath := filepath.Join(GoldenDir, name+".got.png")
+	diffPath := filepath.Join(GoldenDir, name+".diff.png")
+	if err := writePNG(gotPath, img); err != nil {
+		t.Errorf("glitchtest: writing rendered image: %v", err)
+	}
+	if err := writePNG(diffPath, diff); err != nil {
+		t.Errorf("glitchtest: writing diff image: %v", err)
+	}
+	t.Errorf("glitchtest: %s: %d pixels differ by more than %d (see %s)", name, mismatched, tolerance, diffPath)
+}
+
+// Compare compares got against want, channel by channel. It returns the number of pixels that
+// differ by more than tolerance in any channel, along with a diff image where those pixels
+// are red and matching pixels are a faded grayscale version of want.
+// Both images are expected to hold straight alpha colors (like the images from Frame.Image).
+// If the sizes differ then every pixel is counted as mismatched.
+func Compare(got, want *image.RGBA, tolerance uint8) (*image.RGBA, int) {
+	bounds := want.Bounds()
+	diff := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	if got.Bounds().Size() != bounds.Size() {
+		draw.Draw(diff, diff.Bounds(), &image.Uniform{color.RGBA{255, 0, 0, 255}}, image.Point{}, draw.Src)
+		return diff, max(bounds.Dx()*bounds.Dy(), got.Bounds().Dx()*got.Bounds().Dy(), 1)
+	}
+
+	mismatched := 0
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			g := got.Pix[got.PixOffset(got.Bounds().Min.X+x, got.Bounds().Min.Y+y):]
+			w := want.Pix[want.PixOffset(bounds.Min.X+x, bounds.Min.Y+y):]
+
+			match := true
+			for c := 0; c < 4; c++ {
+				if absDiff(g[c], w[c]) > tolerance {
+					match = false
+					break
+				}
+			}
+
+			if !match {
+				mismatched++
+				diff.SetRGBA(x, y, color.RGBA{255, 0, 0, 255})
+				continue
+			}
+			lum := uint8((uint32(w[0])*299 + uint32(w[1])*587 + uint32(w[2])*114) / 1000)
+			lum = 192 + lum/4
+			diff.SetRGBA(x, y, color.RGBA{lum, lum, lum, 255})
+		}
+	}
+	return diff, mismatched
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// The images hold straight alpha colors, so they are stored as NRGBA to round trip exactly
+func writePNG(path string, img *image.RGBA) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	nrgba := &image.NRGBA{
+		Pix:    img.Pix,
+		Stride: img.Stride,
+		Rect:   img.Rect,
+	}
+	return png.Encode(file, nrgba)
+}
+
+func readPNG(path string) (*image.RGBA, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, err := png.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	bounds := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+	return &image.RGBA{
+		Pix:    nrgba.Pix,
+		Stride: nrgba.Stride,
+		Rect:   nrgba.Rect,
+	}, nil
+}
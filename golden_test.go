@@ -0,0 +1,114 @@
+//go:build headless
+
+package glitch_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/unitoftime/flow/glm"
+	"github.com/unitoftime/glitch"
+	"github.com/unitoftime/glitch/glitchtest"
+)
+
+// Returns a 16x16 texture with a one pixel white border around four colored quadrants
+func quadrantTexture(t *testing.T) *glitch.Texture {
+	t.Helper()
+	quadrants := [4]color.RGBA{
+		{255, 0, 0, 255},
+		{0, 255, 0, 255},
+		{0, 0, 255, 255},
+		{255, 255, 0, 128},
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := range 16 {
+		for x := range 16 {
+			c := quadrants[(y/8)*2+x/8]
+			if x == 0 || y == 0 || x == 15 || y == 15 {
+				c = color.RGBA{255, 255, 255, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	texture := glitch.NewTexture(img, false)
+	t.Cleanup(texture.Delete)
+	return texture
+}
+
+func TestGoldenSprite(t *testing.T) {
+	texture := quadrantTexture(t)
+	scene := glitchtest.NewScene(64, 64)
+	scene.Clear = glitch.RGBA{0.1, 0.1, 0.1, 1}
+
+	sprite := glitch.NewSprite(texture, texture.Bounds())
+	sprite.Material().SetBlendMode(glitch.BlendModeNormal)
+	sprite.RectDraw(scene.Sorter, glm.R(4, 4, 28, 28))
+
+	// A quarter of the texture, tinted
+	corner := glitch.NewSprite(texture, glm.R(0, 0, 8, 8))
+	corner.RectDrawColorMask(scene.Sorter, glm.R(36, 4, 60, 28), glitch.RGBA{1, 0.5, 0.5, 1})
+
+	// Rotated by 45 degrees around its center
+	matrix := glitch.Mat4Ident
+	matrix.Scale(1.5, 1.5, 1).Rotate(math.Pi/4, glitch.Vec3{0, 0, 1}).Translate(32, 46, 0)
+	sprite.Draw(scene.Sorter, matrix)
+
+	glitchtest.AssertGolden(t, scene.Render(), "sprite", 1)
+}
+
+func TestGoldenNinePanelSprite(t *testing.T) {
+	texture := quadrantTexture(t)
+	scene := glitchtest.NewScene(64, 48)
+	scene.Clear = glitch.RGBA{0.1, 0.1, 0.1, 1}
+
+	// The borders keep their size while the center stretches
+	panel := glitch.NewNinePanelSprite(texture, texture.Bounds(), glm.R(4, 4, 4, 4))
+	panel.RectDraw(scene.Sorter, glm.R(2, 2, 62, 22))
+	panel.RectDrawColorMask(scene.Sorter, glm.R(2, 26, 30, 46), glitch.RGBA{0.5, 0.5, 1, 1})
+
+	glitchtest.AssertGolden(t, scene.Render(), "nine_panel_sprite", 1)
+}
+
+func TestGoldenText(t *testing.T) {
+	atlas, err := glitch.BasicFontAtlas()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scene := glitchtest.NewScene(96, 48)
+	scene.Clear = glitch.RGBA{0.1, 0.1, 0.1, 1}
+
+	text := atlas.Text("Glitch", 1)
+	matrix := glitch.Mat4Ident
+	matrix.Translate(4, 28, 0)
+	text.Draw(scene.Sorter, matrix)
+
+	text = atlas.Text("Text 123", 1)
+	matrix = glitch.Mat4Ident
+	matrix.Translate(4, 8, 0)
+	text.DrawColorMask(scene.Sorter, matrix, glitch.RGBA{1, 0.5, 0, 1})
+
+	glitchtest.AssertGolden(t, scene.Render(), "text", 1)
+}
+
+func TestGoldenGeomDraw(t *testing.T) {
+	scene := glitchtest.NewScene(64, 64)
+	scene.Clear = glitch.RGBA{0.1, 0.1, 0.1, 1}
+
+	geom := glitch.NewGeomDraw()
+	mesh := glitch.NewMesh()
+
+	geom.SetColor(glitch.RGBA{1, 0, 0, 1})
+	geom.Rectangle2(mesh, glm.R(4, 4, 28, 28), 0)
+	geom.SetColor(glitch.RGBA{0, 1, 0, 1})
+	geom.Rectangle2(mesh, glm.R(36, 4, 60, 28), 2)
+	geom.SetColor(glitch.RGBA{0, 0.5, 1, 1})
+	geom.Circle(mesh, glitch.Vec3{16, 46, 0}, 12, 3)
+	geom.SetColor(glitch.RGBA{1, 1, 0, 1})
+	geom.LineStrip(mesh, []glitch.Vec3{{36, 36, 0}, {48, 60, 0}, {60, 36, 0}}, 2)
+
+	mesh.Draw(scene.Sorter, glitch.Mat4Ident)
+
+	glitchtest.AssertGolden(t, scene.Render(), "geom_draw", 1)
+}
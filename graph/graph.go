@@ -67,10 +67,24 @@ func (g *Graph) Line(series []glitch.Vec2) {
 		maxRange = math.Max(maxRange, float64(p.Y))
 	}
 
-	g.axes = glm.R(minDomain, minRange, maxDomain, maxRange)
+	g.LineAxes(series, glm.R(minDomain, minRange, maxDomain, maxRange))
+}
+
+// LineAxes draws series scaled so that axes fills the bounds of the graph, instead of scaling
+// to fit the series. Useful for keeping several graphs on the same scale.
+func (g *Graph) LineAxes(series []glitch.Vec2, axes glitch.Rect) {
+	g.axes = axes
+	minDomain, minRange := axes.Min.X, axes.Min.Y
+	maxDomain, maxRange := axes.Max.X, axes.Max.Y
 
 	dx := g.bounds.W() / (maxDomain - minDomain)
 	dy := g.bounds.H() / (maxRange - minRange)
+	if maxDomain == minDomain {
+		dx = 0
+	}
+	if maxRange == minRange {
+		dy = 0
+	}
 	// fmt.Println(dx, dy, rect.H(), maxDomain, minDomain, minRange, maxRange)
 	g.points = g.points[:0]
 	for _, p := range series {
@@ -0,0 +1,98 @@
+package graph
+
+import (
+	"github.com/unitoftime/flow/glm"
+	"github.com/unitoftime/glitch"
+)
+
+// StatsSeries is one value of the frame stats that is plotted by a StatsOverlay
+type StatsSeries struct {
+	Name  string
+	Color glitch.RGBA
+	Value func(glitch.FrameStats) float64
+}
+
+// DefaultStatsSeries are the series that a new StatsOverlay plots, top to bottom
+var DefaultStatsSeries = []StatsSeries{
+	{"draw calls", glitch.RGBA{1, 1, 1, 1}, func(s glitch.FrameStats) float64 {
+		return float64(s.DrawCalls)
+	}},
+	{"state switches", glitch.RGBA{1, 1, 0, 1}, func(s glitch.FrameStats) float64 {
+		return float64(s.ShaderSwitches + s.TextureSwitches + s.BlendSwitches + s.FramebufferSwitches)
+	}},
+	{"vertices uploaded", glitch.RGBA{0, 1, 1, 1}, func(s glitch.FrameStats) float64 {
+		return float64(s.VerticesUploaded)
+	}},
+	{"buffers in use", glitch.RGBA{1, 0, 1, 1}, func(s glitch.FrameStats) float64 {
+		return float64(s.BuffersInUse)
+	}},
+	{"sort ms", glitch.RGBA{0, 1, 0, 1}, func(s glitch.FrameStats) float64 {
+		return s.SortTime.Seconds() * 1000
+	}},
+	{"fill ms", glitch.RGBA{1, 0.5, 0, 1}, func(s glitch.FrameStats) float64 {
+		return s.FillTime.Seconds() * 1000
+	}},
+	{"submit ms", glitch.RGBA{1, 0, 0, 1}, func(s glitch.FrameStats) float64 {
+		return s.SubmitTime.Seconds() * 1000
+	}},
+}
+
+// StatsOverlay draws the history of the render stats as a column of line graphs, one per
+// series. Enable the stats with glitch.EnableStats, then draw the overlay every frame:
+//
+//	overlay.Draw(win, glitch.Stats())
+type StatsOverlay struct {
+	Series []StatsSeries
+
+	bounds glitch.Rect
+	graphs []*Graph
+	values []glitch.Vec2
+}
+
+func NewStatsOverlay(bounds glitch.Rect) *StatsOverlay {
+	return &StatsOverlay{
+		Series: DefaultStatsSeries,
+		bounds: bounds,
+	}
+}
+
+func (o *StatsOverlay) Bounds() glitch.Rect {
+	return o.bounds
+}
+
+func (o *StatsOverlay) SetBounds(bounds glitch.Rect) {
+	o.bounds = bounds
+}
+
+// Draw plots stats into the bounds of the overlay. Each series gets its own row, scaled from
+// 0 to the peak of the series, with the newest frame on the right.
+func (o *StatsOverlay) Draw(target glitch.BatchTarget, stats *glitch.RenderStats) {
+	if stats == nil || stats.Len() < 2 || len(o.Series) == 0 {
+		return
+	}
+
+	for len(o.graphs) < len(o.Series) {
+		o.graphs = append(o.graphs, NewGraph(o.bounds))
+	}
+
+	rowHeight := o.bounds.H() / float64(len(o.Series))
+	pad := rowHeight * 0.1
+	offset := stats.Cap() - stats.Len() // Keeps the newest frame on the right edge
+	for i, series := range o.Series {
+		o.values = o.values[:0]
+		peak := 0.0
+		for f := 0; f < stats.Len(); f++ {
+			v := series.Value(stats.Frame(f))
+			peak = max(peak, v)
+			o.values = append(o.values, glitch.Vec2{float64(offset + f), v})
+		}
+
+		top := o.bounds.Max.Y - float64(i)*rowHeight
+		g := o.graphs[i]
+		g.SetBounds(glm.R(o.bounds.Min.X, top-rowHeight+pad, o.bounds.Max.X, top-pad))
+		g.Clear()
+		g.Axes()
+		g.LineAxes(o.values, glm.R(0, 0, float64(stats.Cap()-1), peak))
+		g.DrawColorMask(target, glitch.Mat4Ident, series.Color)
+	}
+}
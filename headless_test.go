@@ -0,0 +1,282 @@
+//go:build headless
+
+package glitch_test
+
+import (
+	"image"
+	"image/color"
+	"slices"
+	"testing"
+
+	"github.com/unitoftime/flow/glm"
+	"github.com/unitoftime/glitch"
+	"github.com/unitoftime/glitch/glitchtest"
+	"github.com/unitoftime/glitch/shaders"
+)
+
+func TestMain(m *testing.M) {
+	glitchtest.Main(m)
+}
+
+// Returns the pixel at x, y where y counts up from the bottom of the image, like the scene's
+// camera does
+func pixelAt(img *image.RGBA, x, y int) color.RGBA {
+	return img.RGBAAt(x, img.Bounds().Dy()-1-y)
+}
+
+func assertPixel(t *testing.T, img *image.RGBA, x, y int, want color.RGBA) {
+	t.Helper()
+	got := pixelAt(img, x, y)
+	diff := func(a, b uint8) bool {
+		return max(a, b)-min(a, b) > 1
+	}
+	if diff(got.R, want.R) || diff(got.G, want.G) || diff(got.B, want.B) || diff(got.A, want.A) {
+		t.Errorf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+	}
+}
+
+// Returns a sprite that covers the whole white texture, with its own material
+func whiteSprite() *glitch.Sprite {
+	return glitch.NewSprite(glitch.WhiteTexture(), glitch.WhiteTexture().Bounds())
+}
+
+func TestSpriteKernel(t *testing.T) {
+	pixels := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	pixels.SetRGBA(0, 0, color.RGBA{255, 0, 0, 255})
+	pixels.SetRGBA(1, 0, color.RGBA{0, 0, 255, 255})
+	texture := glitch.NewTexture(pixels, false)
+	defer texture.Delete()
+
+	scene := glitchtest.NewScene(32, 16)
+	sprite := glitch.NewSprite(texture, texture.Bounds())
+	sprite.RectDraw(scene.Sorter, glm.R(0, 0, 32, 8))
+	sprite.RectDrawColorMask(scene.Sorter, glm.R(0, 8, 32, 16), glitch.RGBA{0.5, 1, 1, 1})
+	img := scene.Render()
+
+	assertPixel(t, img, 4, 4, color.RGBA{255, 0, 0, 255})
+	assertPixel(t, img, 28, 4, color.RGBA{0, 0, 255, 255})
+	assertPixel(t, img, 4, 12, color.RGBA{128, 0, 0, 255})
+	assertPixel(t, img, 28, 12, color.RGBA{0, 0, 255, 255})
+}
+
+func TestInstancedMeshColorMask(t *testing.T) {
+	scene := glitchtest.NewScene(32, 16)
+	instanced, err := glitch.NewInstancedSprite(glitch.WhiteTexture(), glitch.WhiteTexture().Bounds())
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := glitch.WhiteTexture().Bounds().W()
+	for i, mask := range []glitch.RGBA{{1, 0, 0, 1}, {0, 1, 1, 1}} {
+		matrix := glitch.Mat4Ident
+		matrix.Scale(16/size, 16/size, 1).Translate(float64(8+16*i), 8, 0)
+		instanced.Add(matrix, mask)
+	}
+
+	// The draw's mask is multiplied with each instance's mask
+	instanced.DrawColorMask(scene.Sorter, glitch.Mat4Ident, glitch.RGBA{0.5, 0.5, 0.5, 1})
+	img := scene.Render()
+
+	assertPixel(t, img, 8, 8, color.RGBA{128, 0, 0, 255})
+	assertPixel(t, img, 24, 8, color.RGBA{0, 128, 128, 255})
+}
+
+func TestInstancedMeshErrors(t *testing.T) {
+	mesh := glitch.NewSpriteMesh(1, 1, glm.R(0, 0, 1, 1))
+	_, err := glitch.NewInstancedMesh(mesh, glitch.DefaultMaterial(glitch.WhiteTexture()))
+	if err == nil {
+		t.Errorf("a shader without instance attributes didn't return an error")
+	}
+}
+
+func TestFlatKernel(t *testing.T) {
+	shader, err := glitch.NewShader(shaders.DiffuseShader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shader.Delete()
+
+	material := glitch.NewMaterial(shader)
+	material.SetTexture(glitch.WhiteTexture())
+	material.SetCullMode(glitch.CullModeNormal)
+	material.SetUniform("viewPos", glitch.Vec3{16, 16, 1})
+	material.SetUniform("material.ambient", glitch.Vec3{0.2, 0, 0})
+	material.SetUniform("material.diffuse", glitch.Vec3{0, 0.5, 0})
+	material.SetUniform("material.specular", glitch.Vec3{0, 0, 0})
+	material.SetUniform("material.shininess", float32(1))
+	material.SetUniform("dirLight.direction", glitch.Vec3{0, 0, 1})
+	material.SetUniform("dirLight.ambient", glitch.Vec3{1, 1, 1})
+	material.SetUniform("dirLight.diffuse", glitch.Vec3{1, 1, 1})
+	material.SetUniform("dirLight.specular", glitch.Vec3{0, 0, 0})
+
+	scene := glitchtest.NewScene(32, 32)
+	model := glitch.NewModel(glitch.NewCubeMesh(1), material)
+	matrix := glitch.Mat4Ident
+	matrix.Scale(16, 16, 1).Translate(16, 16, 0)
+	model.Draw(scene.Sorter, matrix)
+	img := scene.Render()
+
+	// Only the front face is left after culling, and it faces the light
+	assertPixel(t, img, 16, 16, color.RGBA{51, 128, 0, 255})
+	assertPixel(t, img, 2, 2, color.RGBA{0, 0, 0, 0})
+}
+
+func TestShaderWarnings(t *testing.T) {
+	cfg := shaders.SpriteShader
+	shader, err := glitch.NewShaderExt(cfg.VertexShader, cfg.FragmentShader, cfg.VertexFormat, cfg.UniformFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shader.Delete()
+	if w := shader.Warnings(); len(w) != 0 {
+		t.Errorf("got warnings for the sprite shader: %q", w)
+	}
+
+	// The shininess uniform is used by the shader, but can't be set without being in the format
+	cfg = shaders.DiffuseShader
+	uniformFmt := slices.DeleteFunc(slices.Clone(cfg.UniformFormat), func(u shaders.Attr) bool {
+		return u.Name == "material.shininess"
+	})
+	shader, err = glitch.NewShaderExt(cfg.VertexShader, cfg.FragmentShader, cfg.VertexFormat, uniformFmt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shader.Delete()
+	want := []string{`uniform "material.shininess" isn't in the UniformFormat, so it can't be set`}
+	if w := shader.Warnings(); !slices.Equal(w, want) {
+		t.Errorf("got warnings %q, want %q", w, want)
+	}
+}
+
+func TestBlendModes(t *testing.T) {
+	dst := glitch.RGBA{0.5, 0.25, 1, 1}
+	src := glitch.RGBA{0.5, 0.5, 0.5, 0.5} // Premultiplied, like the shaders output
+
+	tests := []struct {
+		name string
+		mode glitch.BlendMode
+		want color.RGBA
+	}{
+		{"None", glitch.BlendModeNone, color.RGBA{255, 255, 255, 255}}, // The mask is ignored without blending, so the source is opaque white
+		{"Normal", glitch.BlendModeNormal, color.RGBA{191, 159, 255, 255}},
+		{"Additive", glitch.BlendModeAdditive, color.RGBA{255, 191, 255, 255}},
+		{"Multiply", glitch.BlendModeMultiply, color.RGBA{128, 64, 255, 128}}, // The alpha is multiplied too, so the straight color is the destination
+		{"Screen", glitch.BlendModeScreen, color.RGBA{191, 159, 255, 255}},
+		{"Subtract", glitch.BlendModeSubtract, color.RGBA{0, 0, 128, 255}},
+		{"Min", glitch.BlendModeMin, color.RGBA{255, 128, 255, 128}},
+		{"Max", glitch.BlendModeMax, color.RGBA{128, 128, 255, 255}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scene := glitchtest.NewScene(8, 8)
+			scene.Clear = dst
+			sprite := whiteSprite()
+			sprite.Material().SetBlendMode(test.mode)
+			mask := src
+			if test.mode == glitch.BlendModeNone {
+				mask = glitch.White
+			}
+			sprite.RectDrawColorMask(scene.Sorter, scene.Bounds(), mask)
+			assertPixel(t, scene.Render(), 4, 4, test.want)
+		})
+	}
+}
+
+func TestDepthMode(t *testing.T) {
+	for _, mode := range []glitch.DepthMode{glitch.DepthModeNone, glitch.DepthModeLess} {
+		scene := glitchtest.NewScene(8, 8)
+
+		near := whiteSprite()
+		near.Material().SetDepthMode(mode)
+		far := whiteSprite()
+		far.Material().SetDepthMode(mode)
+
+		// The near quad is drawn first, so the far quad only covers it without depth testing
+		matrix := glitch.Mat4Ident
+		matrix.Translate(4, 4, 0.5)
+		near.DrawColorMask(scene.Sorter, matrix, glitch.RGBA{1, 0, 0, 1})
+		matrix = glitch.Mat4Ident
+		matrix.Translate(4, 4, -0.5)
+		far.DrawColorMask(scene.Sorter, matrix, glitch.RGBA{0, 0, 1, 1})
+		img := scene.Render()
+
+		want := color.RGBA{0, 0, 255, 255}
+		if mode == glitch.DepthModeLess {
+			want = color.RGBA{255, 0, 0, 255}
+		}
+		assertPixel(t, img, 4, 4, want)
+	}
+}
+
+func TestCullMode(t *testing.T) {
+	for _, mode := range []glitch.CullMode{glitch.CullModeNone, glitch.CullModeNormal} {
+		scene := glitchtest.NewScene(16, 8)
+
+		sprite := whiteSprite()
+		sprite.Material().SetCullMode(mode)
+		sprite.RectDraw(scene.Sorter, glm.R(0, 0, 8, 8))
+
+		// Sprite quads are wound clockwise, so they face away from the camera. Mirroring one
+		// flips its winding, so it faces the camera instead
+		size := sprite.Bounds().W()
+		matrix := glitch.Mat4Ident
+		matrix.Scale(-8/size, 8/size, 1).Translate(12, 4, 0)
+		sprite.Draw(scene.Sorter, matrix)
+		img := scene.Render()
+
+		assertPixel(t, img, 12, 4, color.RGBA{255, 255, 255, 255})
+		if mode == glitch.CullModeNone {
+			assertPixel(t, img, 4, 4, color.RGBA{255, 255, 255, 255})
+		} else {
+			assertPixel(t, img, 4, 4, color.RGBA{0, 0, 0, 0})
+		}
+	}
+}
+
+func TestFrameConfigErrors(t *testing.T) {
+	_, err := glitch.NewFrameConfig(glm.R(0, 0, 8, 8), glitch.FrameConfig{
+		Colors: []glitch.TextureFormat{glitch.TextureFormatRGBA8, glitch.TextureFormatDepth24},
+	})
+	if err == nil {
+		t.Errorf("creating a frame with a depth color attachment didn't return an error")
+	}
+
+	post := glitch.NewPostProcess()
+	if err := post.SetFormat(glitch.TextureFormatDepth32F); err == nil {
+		t.Errorf("setting a depth format on a post process didn't return an error")
+	}
+}
+
+func TestPostPassErrors(t *testing.T) {
+	if _, err := glitch.IdentityLUT(1); err == nil {
+		t.Errorf("IdentityLUT(1) didn't return an error")
+	}
+	lut, err := glitch.IdentityLUT(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lut.Bounds() != image.Rect(0, 0, 4, 2) {
+		t.Errorf("got a lut with bounds %v, want 4x2", lut.Bounds())
+	}
+
+	texture := glitch.NewTexture(lut, true)
+	defer texture.Delete()
+	if _, err := glitch.NewColorGradePass(texture, 1); err != nil {
+		t.Errorf("got error %v for an identity lut", err)
+	}
+	square := glitch.NewTexture(image.NewRGBA(image.Rect(0, 0, 3, 3)), true)
+	defer square.Delete()
+	if _, err := glitch.NewColorGradePass(square, 1); err == nil {
+		t.Errorf("a 3x3 lut didn't return an error")
+	}
+	if _, err := glitch.NewColorGradePass(nil, 1); err == nil {
+		t.Errorf("a missing lut didn't return an error")
+	}
+
+	bloom, err := glitch.NewBloomPasses(0.8, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bloom) != 4 {
+		t.Errorf("got %d bloom passes, want the threshold, two blurs and the combine", len(bloom))
+	}
+}
@@ -0,0 +1,241 @@
+package glitch
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/unitoftime/flow/glm"
+	"github.com/unitoftime/glitch/internal/gl"
+	"github.com/unitoftime/glitch/internal/mainthread"
+	"github.com/unitoftime/glitch/shaders"
+)
+
+// InstancedMesh draws many copies of the same mesh with a single draw call. The mesh is buffered
+// to the GPU once, and each copy (ie instance) only uploads its own matrix, color mask and custom
+// attributes. The material's shader reads these through the attributes that have instance
+// swizzles (eg shaders.InstanceMatrix), like shaders.SpriteInstancedShader does.
+// Note: The instances are read when the draw is flushed to the GPU, so don't modify them
+// between drawing the InstancedMesh and drawing the target that it was added to.
+type InstancedMesh struct {
+	mesh       *Mesh // A buffered mesh, so the instances draw through the buffered mesh path
+	meshBounds Box
+	instances  *instanceBuffer
+	material   Material
+}
+
+// NewInstancedMesh buffers mesh for instanced drawing with material.
+// The material's shader must have instance attributes. It returns an error if the context can't
+// draw instances, like WebGL1 without the ANGLE_instanced_arrays extension.
+func NewInstancedMesh(mesh *Mesh, material Material) (*InstancedMesh, error) {
+	shader := material.shader
+	if len(shader.instanceFmt) == 0 {
+		return nil, fmt.Errorf("instanced mesh: the material's shader doesn't have any instance attributes")
+	}
+	var supported bool
+	mainthread.Call(func() {
+		supported = gl.InstancingSupported()
+	})
+	if !supported {
+		return nil, fmt.Errorf("instanced mesh: the context can't draw instances")
+	}
+
+	instances := newInstanceBuffer(shader.instanceFmt)
+	buffered := mesh.Buffer(shader)
+	buffered.buffer.instances = instances
+
+	mainthread.Call(func() {
+		instances.mainthreadSetup(shader, buffered.buffer.vao)
+	})
+
+	return &InstancedMesh{
+		mesh:       buffered,
+		meshBounds: mesh.Bounds(),
+		instances:  instances,
+		material:   material,
+	}, nil
+}
+
+// NewInstancedSprite creates an InstancedMesh of the sprite at bounds inside the texture,
+// centered on (0, 0) like NewSprite
+func NewInstancedSprite(texture *Texture, bounds Rect) (*InstancedMesh, error) {
+	uvBounds := glm.R(
+		bounds.Min.X/float64(texture.width),
+		bounds.Min.Y/float64(texture.height),
+		bounds.Max.X/float64(texture.width),
+		bounds.Max.Y/float64(texture.height),
+	)
+	mesh := NewSpriteMesh(bounds.W(), bounds.H(), uvBounds)
+	return NewInstancedMesh(mesh, DefaultInstancedMaterial(texture))
+}
+
+// Add adds an instance of the mesh. The custom values are written to the shader's
+// shaders.InstanceCustom attributes, one after another in the order of the vertex format.
+func (m *InstancedMesh) Add(matrix Mat4, mask RGBA, custom ...float32) {
+	m.instances.add(glm4(matrix), mask, custom)
+
+	bounds := m.meshBounds.Apply(matrix)
+	if m.instances.count == 1 {
+		m.mesh.bounds = bounds
+	} else {
+		m.mesh.bounds = m.mesh.bounds.Union(bounds)
+	}
+}
+
+// Clear removes all of the instances
+func (m *InstancedMesh) Clear() {
+	m.instances.clear()
+	m.mesh.bounds = Box{}
+}
+
+// Len returns the number of instances
+func (m *InstancedMesh) Len() int {
+	return m.instances.count
+}
+
+// Bounds returns the bounds of every instance together
+func (m *InstancedMesh) Bounds() Box {
+	return m.mesh.bounds
+}
+
+func (m *InstancedMesh) Material() *Material {
+	return &m.material
+}
+
+// Draw draws every instance, with matrix applied on top of each instance's matrix
+func (m *InstancedMesh) Draw(target BatchTarget, matrix Mat4) {
+	m.DrawColorMask(target, matrix, White)
+}
+
+// DrawColorMask draws every instance, with matrix applied on top of each instance's matrix and
+// mask multiplied with each instance's color mask. The mask is set through the shader's "mask"
+// uniform, so custom shaders without it ignore the mask.
+func (m *InstancedMesh) DrawColorMask(target BatchTarget, matrix Mat4, mask RGBA) {
+	target.Add(m.mesh.g(), glm4(matrix), mask, m.material)
+}
+
+// Sets the color mask of an instanced draw, if the shader has a mask uniform
+func (s *Shader) setInstanceMask(mask RGBA) {
+	if _, ok := s.uniformLocs["mask"]; !ok {
+		return
+	}
+	s.setUniform("mask", mask)
+}
+
+//--------------------------------------------------------------------------------
+
+// Holds the per instance attributes. Unlike the vertex buffers, the attributes are interleaved
+// so that growing the buffer doesn't move where each attribute starts.
+type instanceBuffer struct {
+	format     shaders.VertexFormat
+	stride     int // The number of floats per instance
+	customSize int // The number of custom floats per instance
+	data       []float32
+	count      int
+	dirty      bool // Set if data has changed since it was last buffered
+
+	vbo      gl.Buffer
+	capacity int // The number of instances that the GPU buffer can hold
+}
+
+func newInstanceBuffer(format shaders.VertexFormat) *instanceBuffer {
+	b := &instanceBuffer{
+		format: format,
+	}
+	for _, attr := range format {
+		switch attr.Swizzle {
+		case shaders.InstanceMatrix:
+			if attr.Type != shaders.AttrMat4 {
+				panic(fmt.Sprintf("instance attribute %s: InstanceMatrix must be a mat4", attr.Name))
+			}
+		case shaders.InstanceColorRGBA:
+			if attr.Type != shaders.AttrVec4 {
+				panic(fmt.Sprintf("instance attribute %s: InstanceColorRGBA must be a vec4", attr.Name))
+			}
+		case shaders.InstanceCustom:
+			if attr.Size() > 4 {
+				panic(fmt.Sprintf("instance attribute %s: InstanceCustom must be a float or vector", attr.Name))
+			}
+			b.customSize += attr.Size()
+		default:
+			panic(fmt.Sprintf("Unsupported %T: %+v", attr, attr))
+		}
+		b.stride += attr.Size()
+	}
+	return b
+}
+
+// Creates the GPU buffer and adds the instance attributes to the vertex array.
+// Matrices take up one attribute location per column.
+func (b *instanceBuffer) mainthreadSetup(shader *Shader, vao gl.Buffer) {
+	gl.BindVertexArray(vao)
+	b.vbo = gl.GenBuffers()
+	gl.BindBuffer(gl.ARRAY_BUFFER, b.vbo)
+
+	offset := 0
+	for _, attr := range b.format {
+		loc := gl.GetAttribLocation(shader.program, attr.Name)
+		if loc.Value < 0 {
+			offset += attr.Size() * sof
+			continue // Skip: The attribute isn't used by the shader
+		}
+		columns := 1
+		if attr.Type == shaders.AttrMat4 {
+			columns = 4
+		}
+		size := attr.Size() / columns
+		for c := 0; c < columns; c++ {
+			colLoc := gl.Attrib{Value: loc.Value + c}
+			gl.VertexAttribPointer(colLoc, size, gl.FLOAT, false, b.stride*sof, offset+c*size*sof)
+			gl.EnableVertexAttribArray(colLoc)
+			gl.VertexAttribDivisor(colLoc, 1)
+		}
+		offset += attr.Size() * sof
+	}
+}
+
+func (b *instanceBuffer) add(matrix glMat4, mask RGBA, custom []float32) {
+	if len(custom) != b.customSize {
+		panic(fmt.Sprintf("InstancedMesh.Add: expected %d custom values, got %d", b.customSize, len(custom)))
+	}
+
+	for _, attr := range b.format {
+		switch attr.Swizzle {
+		case shaders.InstanceMatrix:
+			b.data = append(b.data, matrix[:]...)
+		case shaders.InstanceColorRGBA:
+			color := glc4(mask)
+			b.data = append(b.data, color[:]...)
+		case shaders.InstanceCustom:
+			size := attr.Size()
+			b.data = append(b.data, custom[:size]...)
+			custom = custom[size:]
+		}
+	}
+	b.count++
+	b.dirty = true
+}
+
+func (b *instanceBuffer) clear() {
+	b.data = b.data[:0]
+	b.count = 0
+	b.dirty = true
+}
+
+func (b *instanceBuffer) mainthreadBufferData() {
+	if !b.dirty {
+		return
+	}
+	b.dirty = false
+	if b.count == 0 {
+		return
+	}
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, b.vbo)
+	if b.count > b.capacity {
+		b.capacity = max(b.count, 2*b.capacity)
+		gl.BufferData(gl.ARRAY_BUFFER, b.capacity*b.stride*sof, nil, gl.DYNAMIC_DRAW)
+	}
+
+	data := b.data[:b.count*b.stride]
+	gl.BufferSubDataByte(gl.ARRAY_BUFFER, 0, unsafe.Slice((*byte)(unsafe.Pointer(&data[0])), len(data)*sof))
+}
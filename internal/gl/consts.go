@@ -44,6 +44,10 @@ const (
 	FILL = 0x1B02
 
 	DEPTH_COMPONENT24        = 0x81A6
+	DEPTH24_STENCIL8         = 0x88F0
+	DEPTH_STENCIL            = 0x84F9
+	DEPTH_STENCIL_ATTACHMENT = 0x821A
+	UNSIGNED_INT_24_8        = 0x84FA
 	DEPTH_COMPONENT32F       = 0x8CAC
 	TEXTURE_BORDER_COLOR     = 0x1004
 	READ_FRAMEBUFFER         = 0x8CA8
@@ -53,6 +57,26 @@ const (
 	// CLAMP_TO_BORDER = 0x812D // TODO - this isn't supported by webgl, using clamp_to_edge instead
 	VERTEX_ARRAY_BINDING = 0x85B5
 
+	// Sized internal formats
+	RGBA8   = 0x8058
+	R8      = 0x8229
+	RG8     = 0x822B
+	R16F    = 0x822D
+	RG16F   = 0x822F
+	RGBA16F = 0x881A
+	R32F    = 0x822E
+	RG32F   = 0x8230
+	RGBA32F = 0x8814
+	RG      = 0x8227
+
+	MAX_COLOR_ATTACHMENTS = 0x8CDF
+	MAX_DRAW_BUFFERS      = 0x8824
+
+	// EXT_texture_filter_anisotropic
+	TEXTURE_MAX_ANISOTROPY     = 0x84FE
+	MAX_TEXTURE_MAX_ANISOTROPY = 0x84FF
+	NUM_EXTENSIONS             = 0x821D
+
 	POINTS                                       = 0x0000
 	LINES                                        = 0x0001
 	LINE_LOOP                                    = 0x0002
@@ -70,6 +94,8 @@ const (
 	ONE_MINUS_DST_COLOR                          = 0x0307
 	SRC_ALPHA_SATURATE                           = 0x0308
 	FUNC_ADD                                     = 0x8006
+	MIN                                          = 0x8007
+	MAX                                          = 0x8008
 	BLEND_EQUATION                               = 0x8009
 	BLEND_EQUATION_RGB                           = 0x8009
 	BLEND_EQUATION_ALPHA                         = 0x883D
@@ -88,7 +114,10 @@ const (
 	ELEMENT_ARRAY_BUFFER                         = 0x8893
 	ARRAY_BUFFER_BINDING                         = 0x8894
 	ELEMENT_ARRAY_BUFFER_BINDING                 = 0x8895
+	PIXEL_PACK_BUFFER                            = 0x88EB
+	PIXEL_PACK_BUFFER_BINDING                    = 0x88ED
 	STREAM_DRAW                                  = 0x88E0
+	STREAM_READ                                  = 0x88E1
 	STATIC_DRAW                                  = 0x88E4
 	DYNAMIC_DRAW                                 = 0x88E8
 	BUFFER_SIZE                                  = 0x8764
@@ -356,6 +385,13 @@ const (
 	FLOAT_MAT4   = 0x8B5C
 	SAMPLER_2D   = 0x8B5E
 	SAMPLER_CUBE = 0x8B60
+
+	FLOAT_MAT2x3 = 0x8B65
+	FLOAT_MAT2x4 = 0x8B66
+	FLOAT_MAT3x2 = 0x8B67
+	FLOAT_MAT3x4 = 0x8B68
+	FLOAT_MAT4x2 = 0x8B69
+	FLOAT_MAT4x3 = 0x8B6A
 )
 
 const (
@@ -0,0 +1,2116 @@
+//go:build !js && headless
+// +build !js,headless
+
+package gl
+
+// This is a software implementation of the gl API, selected with the `headless` build tag.
+// Draw calls are rasterized on the CPU into in-memory surfaces, so the whole draw path can
+// run in environments without a GPU (eg `go test -tags headless ./...` on CI).
+// Shaders don't get compiled. Instead each shader source needs a Go kernel registered for
+// it (see RegisterVertexKernel and RegisterFragmentKernel).
+//
+// Like a real context, all of the state is global, so calls should still be made from the
+// main thread.
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+const (
+	maxVertexAttribs    = 16
+	maxTextureUnits     = 32
+	maxColorAttachments = 8
+	maxAnisotropy       = 16
+	maxTextureSize      = 16384
+)
+
+// ContextWatcher is this library's context watcher, satisfying glfw.ContextWatcher interface.
+// There is nothing to initialize for the software rasterizer.
+var ContextWatcher = new(contextWatcher)
+
+type contextWatcher struct{}
+
+func (cw *contextWatcher) OnMakeCurrent(context interface{}) {}
+func (contextWatcher) OnDetach()                             {}
+
+type buffer struct {
+	data  []byte
+	usage Enum
+}
+
+type attribPointer struct {
+	enabled    bool
+	buffer     *buffer
+	bufferName uint32
+	size       int
+	ty         Enum
+	normalized bool
+	stride     int
+	offset     int
+	divisor    int // Zero advances per vertex, otherwise once every divisor instances
+}
+
+type vertexArray struct {
+	attribs      [maxVertexAttribs]attribPointer
+	elements     *buffer
+	elementsName uint32
+}
+
+// fetch reads the value of an attribute for the vertex at index of the given instance
+func (v *vertexArray) fetch(location, index, instance int) [4]float32 {
+	attr := &v.attribs[location]
+	if !attr.enabled || attr.buffer == nil {
+		return ctx.genericAttribs[location]
+	}
+	if attr.divisor > 0 {
+		index = instance / attr.divisor
+	}
+
+	ret := [4]float32{0, 0, 0, 1}
+	size := typeSize(attr.ty)
+	stride := attr.stride
+	if stride == 0 {
+		stride = attr.size * size
+	}
+	start := attr.offset + index*stride
+	data := attr.buffer.data
+	for i := 0; i < attr.size && i < 4; i++ {
+		off := start + i*size
+		if off+size > len(data) {
+			break
+		}
+		ret[i] = decodeAttrib(data[off:], attr.ty, attr.normalized)
+	}
+	return ret
+}
+
+func decodeAttrib(data []byte, ty Enum, normalized bool) float32 {
+	if normalized || ty == FLOAT {
+		return decodeComponent(data, ty)
+	}
+	switch ty {
+	case BYTE:
+		return float32(int8(data[0]))
+	case UNSIGNED_BYTE:
+		return float32(data[0])
+	case SHORT:
+		return float32(int16(*(*uint16)(unsafe.Pointer(&data[0]))))
+	case UNSIGNED_SHORT:
+		return float32(*(*uint16)(unsafe.Pointer(&data[0])))
+	case INT:
+		return float32(*(*int32)(unsafe.Pointer(&data[0])))
+	case UNSIGNED_INT:
+		return float32(*(*uint32)(unsafe.Pointer(&data[0])))
+	}
+	return 0
+}
+
+type shader struct {
+	ty       Enum
+	source   string
+	compiled bool
+	deleted  bool
+}
+
+type uniform struct {
+	declaration
+	value []float32
+}
+
+type program struct {
+	shaders   []*shader
+	linked    bool
+	validated bool
+	infoLog   string
+
+	vertex     *VertexKernel
+	fragment   *FragmentKernel
+	varyingMap []int // For each fragment input component, the index of the vertex output component
+
+	attribBindings map[string]int // From BindAttribLocation
+	attribs        []declaration
+	attribLocs     map[string]int
+
+	uniforms     []uniform
+	uniformIndex map[string]int
+	locations    []uniformLocation
+}
+
+type uniformLocation struct {
+	uniform int
+	element int
+}
+
+// framebuffer attachments are tracked by surface so that respecifying a texture's storage
+// is seen by every framebuffer it's attached to.
+type framebuffer struct {
+	name        uint32
+	colors      [maxColorAttachments]*surface
+	depth       *surface
+	stencil     *surface
+	drawBuffers []Enum
+	readBuffer  Enum
+}
+
+func (f *framebuffer) isDefault() bool {
+	return f.name == 0
+}
+
+func (f *framebuffer) attachment(buf Enum) *surface {
+	if f.isDefault() {
+		if buf == NONE {
+			return nil
+		}
+		return f.colors[0]
+	}
+	i := int(buf) - COLOR_ATTACHMENT0
+	if i < 0 || i >= len(f.colors) {
+		return nil
+	}
+	return f.colors[i]
+}
+
+// drawTargets returns the surface that each fragment output is written to, indexed by output
+// location. Entries are nil for outputs that are written to NONE.
+func (f *framebuffer) drawTargets() []*surface {
+	targets := make([]*surface, len(f.drawBuffers))
+	for i, buf := range f.drawBuffers {
+		targets[i] = f.attachment(buf)
+	}
+	return targets
+}
+
+func (f *framebuffer) readTarget() *surface {
+	return f.attachment(f.readBuffer)
+}
+
+func (f *framebuffer) depthSurface() *surface {
+	if f.depth != nil {
+		return f.depth
+	}
+	return f.stencil
+}
+
+func (f *framebuffer) stencilSurface() *surface {
+	return f.stencil
+}
+
+// size returns the size of the smallest attachment
+func (f *framebuffer) size() (int, int) {
+	w, h := maxTextureSize, maxTextureSize
+	found := false
+	for _, s := range append(f.colors[:], f.depth, f.stencil) {
+		if s == nil {
+			continue
+		}
+		found = true
+		w = min(w, s.width)
+		h = min(h, s.height)
+	}
+	if !found {
+		return 0, 0
+	}
+	return w, h
+}
+
+type context struct {
+	lastError Enum
+	nextName  uint32
+
+	buffers       map[uint32]*buffer
+	vertexArrays  map[uint32]*vertexArray
+	textures      map[uint32]*surface
+	renderbuffers map[uint32]*surface
+	framebuffers  map[uint32]*framebuffer
+	shaders       map[uint32]*shader
+	programs      map[uint32]*program
+
+	// Bindings
+	arrayBuffer                      uint32
+	pixelPackBuffer                  uint32
+	vertexArray                      *vertexArray
+	vertexArrayName                  uint32
+	defaultVertexArray               *vertexArray
+	program                          *program
+	programName                      uint32
+	activeTexture                    int
+	textureUnits                     [maxTextureUnits]*surface
+	textureUnitNames                 [maxTextureUnits]uint32
+	renderbuffer                     *surface
+	renderbufferName                 uint32
+	readFramebuffer, drawFramebuffer *framebuffer
+	defaultFramebuffer               *framebuffer
+	genericAttribs                   [maxVertexAttribs][4]float32
+	unpackAlignment, unpackRowLength int
+	packAlignment                    int
+
+	// Fixed function state
+	capabilities                 map[Enum]bool
+	viewport                     [4]int
+	scissorBox                   [4]int
+	depthRange                   [2]float32
+	clearColor                   [4]float32
+	clearDepth                   float32
+	clearStencil                 int
+	colorMask                    [4]bool
+	depthMask                    bool
+	depthFunc                    Enum
+	blendSrcRGB, blendDstRGB     Enum
+	blendSrcAlpha, blendDstAlpha Enum
+	blendEqRGB, blendEqAlpha     Enum
+	blendColor                   [4]float32
+	cullFace, frontFace          Enum
+	lineWidth                    float32
+
+	// Index 0 is the front face and index 1 is the back face
+	stencilFunc    [2]Enum
+	stencilRef     [2]int
+	stencilMask    [2]uint32
+	stencilWrite   [2]uint32
+	stencilOp      [2][3]Enum
+	polygonOffset  [2]float32
+	sampleCoverage float32
+	sampleInvert   bool
+}
+
+var ctx = newContext()
+
+func newContext() *context {
+	c := &context{
+		buffers:       make(map[uint32]*buffer),
+		vertexArrays:  make(map[uint32]*vertexArray),
+		textures:      make(map[uint32]*surface),
+		renderbuffers: make(map[uint32]*surface),
+		framebuffers:  make(map[uint32]*framebuffer),
+		shaders:       make(map[uint32]*shader),
+		programs:      make(map[uint32]*program),
+
+		unpackAlignment: 4,
+		packAlignment:   4,
+
+		capabilities: map[Enum]bool{
+			DITHER:      true,
+			MULTISAMPLE: true,
+		},
+		depthRange:     [2]float32{0, 1},
+		clearDepth:     1,
+		colorMask:      [4]bool{true, true, true, true},
+		depthMask:      true,
+		depthFunc:      LESS,
+		blendSrcRGB:    ONE,
+		blendDstRGB:    ZERO,
+		blendSrcAlpha:  ONE,
+		blendDstAlpha:  ZERO,
+		blendEqRGB:     FUNC_ADD,
+		blendEqAlpha:   FUNC_ADD,
+		cullFace:       BACK,
+		frontFace:      CCW,
+		lineWidth:      1,
+		stencilFunc:    [2]Enum{ALWAYS, ALWAYS},
+		stencilMask:    [2]uint32{0xFFFFFFFF, 0xFFFFFFFF},
+		stencilWrite:   [2]uint32{0xFFFFFFFF, 0xFFFFFFFF},
+		stencilOp:      [2][3]Enum{{KEEP, KEEP, KEEP}, {KEEP, KEEP, KEEP}},
+		sampleCoverage: 1,
+	}
+	for i := range c.genericAttribs {
+		c.genericAttribs[i] = [4]float32{0, 0, 0, 1}
+	}
+
+	c.defaultVertexArray = &vertexArray{}
+	c.vertexArray = c.defaultVertexArray
+
+	// The default framebuffer has no window behind it. It grows to fit the viewport whenever
+	// one is set while it is bound.
+	color := newSurface()
+	color.alloc(RGBA, 0, 0)
+	depth := newSurface()
+	depth.alloc(DEPTH_COMPONENT24, 0, 0)
+	c.defaultFramebuffer = &framebuffer{
+		colors:      [maxColorAttachments]*surface{color},
+		depth:       depth,
+		drawBuffers: []Enum{BACK},
+		readBuffer:  BACK,
+	}
+	c.readFramebuffer = c.defaultFramebuffer
+	c.drawFramebuffer = c.defaultFramebuffer
+	return c
+}
+
+func (c *context) setError(err Enum) {
+	if c.lastError == NO_ERROR {
+		c.lastError = err
+	}
+}
+
+func (c *context) genName() uint32 {
+	c.nextName++
+	return c.nextName
+}
+
+func (c *context) boundTexture() *surface {
+	return c.textureUnits[c.activeTexture]
+}
+
+func (c *context) boundBuffer(target Enum) *buffer {
+	switch target {
+	case ARRAY_BUFFER:
+		return c.buffers[c.arrayBuffer]
+	case PIXEL_PACK_BUFFER:
+		return c.buffers[c.pixelPackBuffer]
+	case ELEMENT_ARRAY_BUFFER:
+		return c.vertexArray.elements
+	}
+	c.setError(INVALID_ENUM)
+	return nil
+}
+
+func (c *context) resizeDefaultFramebuffer(width, height int) {
+	fb := c.defaultFramebuffer
+	color := fb.colors[0]
+	if width <= color.width && height <= color.height {
+		return
+	}
+	width = max(width, color.width)
+	height = max(height, color.height)
+
+	resize := func(s *surface) {
+		old := *s
+		s.alloc(s.format, width, height)
+		for y := 0; y < old.height; y++ {
+			copy(s.pix[4*y*width:], old.pix[4*y*old.width:4*(y+1)*old.width])
+		}
+	}
+	resize(color)
+	resize(fb.depth)
+}
+
+// bytesOf returns the memory backing a slice (or the value a pointer points to)
+func bytesOf(data interface{}) []byte {
+	if data == nil {
+		return nil
+	}
+	if b, ok := data.([]byte); ok {
+		return b
+	}
+	v := reflect.ValueOf(data)
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return nil
+		}
+		return unsafe.Slice((*byte)(v.UnsafePointer()), v.Len()*int(v.Type().Elem().Size()))
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return unsafe.Slice((*byte)(v.UnsafePointer()), int(v.Type().Elem().Size()))
+	}
+	panic(fmt.Sprintf("gl: unsupported data type: %T", data))
+}
+
+// --------------------------------------------------------------------------------
+// Buffers and vertex arrays
+
+// TODO: right now I force you to make them 1 at a time
+func GenVertexArrays() Buffer {
+	name := ctx.genName()
+	ctx.vertexArrays[name] = &vertexArray{}
+	return Buffer{name}
+}
+
+// TODO: right now I force you to make them 1 at a time
+func GenBuffers() Buffer {
+	return CreateBuffer()
+}
+
+func BindVertexArray(b Buffer) {
+	if b.Value == 0 {
+		ctx.vertexArray = ctx.defaultVertexArray
+		ctx.vertexArrayName = 0
+		return
+	}
+	vao, ok := ctx.vertexArrays[b.Value]
+	if !ok {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	ctx.vertexArray = vao
+	ctx.vertexArrayName = b.Value
+}
+
+func DeleteBuffers(v Buffer) {
+	DeleteBuffer(v)
+}
+
+func DeleteVertexArrays(v Buffer) {
+	if ctx.vertexArrayName == v.Value {
+		BindVertexArray(Buffer{0})
+	}
+	delete(ctx.vertexArrays, v.Value)
+}
+
+func BufferData(target Enum, size int, data interface{}, usage Enum) {
+	b := ctx.boundBuffer(target)
+	if b == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	b.data = make([]byte, size)
+	b.usage = usage
+	copy(b.data, bytesOf(data))
+}
+
+func BufferDataImguiPassthrough(target Enum, size int, data unsafe.Pointer, usage Enum) {
+	var src []byte
+	if data != nil {
+		src = unsafe.Slice((*byte)(data), size)
+	}
+	BufferData(target, size, src, usage)
+}
+
+func BufferInit(target Enum, size int, usage Enum) {
+	BufferData(target, size, nil, usage)
+}
+
+func bufferSubData(target Enum, offset int, data []byte) {
+	b := ctx.boundBuffer(target)
+	if b == nil || offset < 0 || offset+len(data) > len(b.data) {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	copy(b.data[offset:], data)
+}
+
+func BufferSubDataUint32(target Enum, offset int, data []uint32) {
+	bufferSubData(target, offset, bytesOf(data))
+}
+
+func BufferSubDataByte(target Enum, offset int, data []byte) {
+	bufferSubData(target, offset, data)
+}
+
+func BufferSubData(target Enum, offset int, data interface{}) {
+	switch data.(type) {
+	case []float32, [][2]float32, [][3]float32, []byte, []uint32:
+		bufferSubData(target, offset, bytesOf(data))
+	default:
+		panic("Invalid data type!")
+	}
+}
+
+func GetBufferSubDataByte(target Enum, offset int, dst []byte) {
+	b := ctx.boundBuffer(target)
+	if b == nil || offset < 0 || offset+len(dst) > len(b.data) {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	copy(dst, b.data[offset:])
+}
+
+func GetBufferSubData(target Enum, offset int, data interface{}) {
+	b := ctx.boundBuffer(target)
+	if b == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	switch t := data.(type) {
+	case *[]float32:
+		copy(bytesOf(*t), b.data[offset:])
+	case *[]byte:
+		copy(*t, b.data[offset:])
+	default:
+		panic("Invalid data type!")
+	}
+}
+
+func BindBuffer(target Enum, b Buffer) {
+	buf, ok := ctx.buffers[b.Value]
+	if !ok && b.Value != 0 {
+		// Like GL, binding a name that hasn't been created yet creates it
+		buf = &buffer{}
+		ctx.buffers[b.Value] = buf
+	}
+	switch target {
+	case ARRAY_BUFFER:
+		ctx.arrayBuffer = b.Value
+	case PIXEL_PACK_BUFFER:
+		ctx.pixelPackBuffer = b.Value
+	case ELEMENT_ARRAY_BUFFER:
+		ctx.vertexArray.elements = buf
+		ctx.vertexArray.elementsName = b.Value
+	default:
+		ctx.setError(INVALID_ENUM)
+	}
+}
+
+func CreateBuffer() Buffer {
+	name := ctx.genName()
+	ctx.buffers[name] = &buffer{}
+	return Buffer{name}
+}
+
+func DeleteBuffer(v Buffer) {
+	if ctx.arrayBuffer == v.Value {
+		ctx.arrayBuffer = 0
+	}
+	if ctx.pixelPackBuffer == v.Value {
+		ctx.pixelPackBuffer = 0
+	}
+	if ctx.vertexArray.elementsName == v.Value {
+		ctx.vertexArray.elements = nil
+		ctx.vertexArray.elementsName = 0
+	}
+	delete(ctx.buffers, v.Value)
+}
+
+func IsBuffer(b Buffer) bool {
+	_, ok := ctx.buffers[b.Value]
+	return ok
+}
+
+func GetBufferParameteri(target, pname Enum) int {
+	b := ctx.boundBuffer(target)
+	if b == nil {
+		return 0
+	}
+	switch pname {
+	case BUFFER_SIZE:
+		return len(b.data)
+	case BUFFER_USAGE:
+		return int(b.usage)
+	}
+	ctx.setError(INVALID_ENUM)
+	return 0
+}
+
+func VertexAttribPointer(dst Attrib, size int, ty Enum, normalized bool, stride int, offset int) {
+	if dst.Value < 0 || dst.Value >= maxVertexAttribs {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	attr := &ctx.vertexArray.attribs[dst.Value]
+	attr.buffer = ctx.buffers[ctx.arrayBuffer]
+	attr.bufferName = ctx.arrayBuffer
+	attr.size = size
+	attr.ty = ty
+	attr.normalized = normalized
+	attr.stride = stride
+	attr.offset = offset
+}
+
+func VertexAttribDivisor(index Attrib, divisor int) {
+	if index.Value < 0 || index.Value >= maxVertexAttribs {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	ctx.vertexArray.attribs[index.Value].divisor = divisor
+}
+
+func VertexAttribIPointer(dst Attrib, size int, ty Enum, stride int, offset int) {
+	VertexAttribPointer(dst, size, ty, false, stride, offset)
+}
+
+func EnableVertexAttribArray(a Attrib) {
+	if a.Value < 0 || a.Value >= maxVertexAttribs {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	ctx.vertexArray.attribs[a.Value].enabled = true
+}
+
+func DisableVertexAttribArray(a Attrib) {
+	if a.Value < 0 || a.Value >= maxVertexAttribs {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	ctx.vertexArray.attribs[a.Value].enabled = false
+}
+
+func GetVertexAttribf(src Attrib, pname Enum) float32 {
+	var params [4]float32
+	GetVertexAttribfv(params[:], src, pname)
+	return params[0]
+}
+
+func GetVertexAttribfv(dst []float32, src Attrib, pname Enum) {
+	if pname == CURRENT_VERTEX_ATTRIB {
+		copy(dst, ctx.genericAttribs[src.Value][:])
+		return
+	}
+	dst[0] = float32(GetVertexAttribi(src, pname))
+}
+
+func GetVertexAttribi(src Attrib, pname Enum) int32 {
+	attr := ctx.vertexArray.attribs[src.Value]
+	switch pname {
+	case VERTEX_ATTRIB_ARRAY_ENABLED:
+		return boolToInt(attr.enabled)
+	case VERTEX_ATTRIB_ARRAY_SIZE:
+		return int32(attr.size)
+	case VERTEX_ATTRIB_ARRAY_STRIDE:
+		return int32(attr.stride)
+	case VERTEX_ATTRIB_ARRAY_TYPE:
+		return int32(attr.ty)
+	case VERTEX_ATTRIB_ARRAY_NORMALIZED:
+		return boolToInt(attr.normalized)
+	case VERTEX_ATTRIB_ARRAY_BUFFER_BINDING:
+		return int32(attr.bufferName)
+	case CURRENT_VERTEX_ATTRIB:
+		return int32(ctx.genericAttribs[src.Value][0])
+	}
+	ctx.setError(INVALID_ENUM)
+	return 0
+}
+
+func GetVertexAttribiv(dst []int32, src Attrib, pname Enum) {
+	if pname == CURRENT_VERTEX_ATTRIB {
+		for i, v := range ctx.genericAttribs[src.Value] {
+			dst[i] = int32(v)
+		}
+		return
+	}
+	dst[0] = GetVertexAttribi(src, pname)
+}
+
+func boolToInt(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func vertexAttrib(dst Attrib, v ...float32) {
+	if dst.Value < 0 || dst.Value >= maxVertexAttribs {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	attr := [4]float32{0, 0, 0, 1}
+	copy(attr[:], v)
+	ctx.genericAttribs[dst.Value] = attr
+}
+
+func VertexAttrib1f(dst Attrib, x float32)          { vertexAttrib(dst, x) }
+func VertexAttrib1fv(dst Attrib, src []float32)     { vertexAttrib(dst, src[:1]...) }
+func VertexAttrib2f(dst Attrib, x, y float32)       { vertexAttrib(dst, x, y) }
+func VertexAttrib2fv(dst Attrib, src []float32)     { vertexAttrib(dst, src[:2]...) }
+func VertexAttrib3f(dst Attrib, x, y, z float32)    { vertexAttrib(dst, x, y, z) }
+func VertexAttrib3fv(dst Attrib, src []float32)     { vertexAttrib(dst, src[:3]...) }
+func VertexAttrib4f(dst Attrib, x, y, z, w float32) { vertexAttrib(dst, x, y, z, w) }
+func VertexAttrib4fv(dst Attrib, src []float32)     { vertexAttrib(dst, src[:4]...) }
+
+// --------------------------------------------------------------------------------
+// Drawing
+
+// DrawArrays renders primitives from the enabled vertex arrays
+func DrawArrays(mode Enum, first, count int) {
+	indices := make([]int, count)
+	for i := range indices {
+		indices[i] = first + i
+	}
+	ctx.drawElements(mode, indices, 0)
+}
+
+// DrawElements renders primitives using the indices in the bound element array buffer
+func DrawElements(mode Enum, count int, ty Enum, offset int) {
+	DrawElementsInstanced(mode, count, ty, offset, 1)
+}
+
+// DrawElementsInstanced renders instanceCount copies of the primitives in the bound element
+// array buffer
+func DrawElementsInstanced(mode Enum, count int, ty Enum, offset int, instanceCount int) {
+	b := ctx.vertexArray.elements
+	if b == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	if instanceCount < 0 {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	size := typeSize(ty)
+	if offset < 0 || offset+count*size > len(b.data) {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	indices := make([]int, count)
+	for i := range indices {
+		data := b.data[offset+i*size:]
+		switch ty {
+		case UNSIGNED_BYTE:
+			indices[i] = int(data[0])
+		case UNSIGNED_SHORT:
+			indices[i] = int(*(*uint16)(unsafe.Pointer(&data[0])))
+		default:
+			indices[i] = int(*(*uint32)(unsafe.Pointer(&data[0])))
+		}
+	}
+	for instance := 0; instance < instanceCount; instance++ {
+		ctx.drawElements(mode, indices, instance)
+	}
+}
+
+// Clear clears the buffers selected by mask in the bound draw framebuffer
+func Clear(mask Enum) {
+	fb := ctx.drawFramebuffer
+	box := [4]int{0, 0, maxTextureSize, maxTextureSize}
+	if ctx.capabilities[SCISSOR_TEST] {
+		box = ctx.scissorBox
+	}
+
+	if mask&COLOR_BUFFER_BIT != 0 {
+		for _, buf := range fb.drawBuffers {
+			if s := fb.attachment(buf); s != nil {
+				s.fill(ctx.clearColor, ctx.colorMask, box)
+			}
+		}
+	}
+
+	if depth := fb.depthSurface(); depth != nil && mask&DEPTH_BUFFER_BIT != 0 && ctx.depthMask {
+		x0, y0, x1, y1 := depth.clip(box)
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				depth.setDepth(x, y, ctx.clearDepth)
+			}
+		}
+	}
+
+	if stencil := fb.stencilSurface(); stencil != nil && mask&STENCIL_BUFFER_BIT != 0 {
+		x0, y0, x1, y1 := stencil.clip(box)
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				stencil.writeStencil(x, y, ctx.clearStencil, ctx.stencilWrite[0])
+			}
+		}
+	}
+}
+
+func ClearColor(red, green, blue, alpha float32) {
+	ctx.clearColor = [4]float32{red, green, blue, alpha}
+}
+
+func ClearDepthf(d float32) {
+	ctx.clearDepth = clamp01(d)
+}
+
+func ClearStencil(s int) {
+	ctx.clearStencil = s
+}
+
+func ColorMask(red, green, blue, alpha bool) {
+	ctx.colorMask = [4]bool{red, green, blue, alpha}
+}
+
+func Finish() {}
+func Flush()  {}
+
+// --------------------------------------------------------------------------------
+// Fixed function state
+
+func Enable(cap Enum) {
+	ctx.capabilities[cap] = true
+}
+
+func Disable(cap Enum) {
+	ctx.capabilities[cap] = false
+}
+
+func IsEnabled(cap Enum) bool {
+	return ctx.capabilities[cap]
+}
+
+func BlendColor(red, green, blue, alpha float32) {
+	ctx.blendColor = [4]float32{clamp01(red), clamp01(green), clamp01(blue), clamp01(alpha)}
+}
+
+func BlendEquation(mode Enum) {
+	BlendEquationSeparate(mode, mode)
+}
+
+func BlendEquationSeparate(modeRGB, modeAlpha Enum) {
+	ctx.blendEqRGB = modeRGB
+	ctx.blendEqAlpha = modeAlpha
+}
+
+func BlendFunc(sfactor, dfactor Enum) {
+	BlendFuncSeparate(sfactor, dfactor, sfactor, dfactor)
+}
+
+func BlendFuncSeparate(sfactorRGB, dfactorRGB, sfactorAlpha, dfactorAlpha Enum) {
+	ctx.blendSrcRGB = sfactorRGB
+	ctx.blendDstRGB = dfactorRGB
+	ctx.blendSrcAlpha = sfactorAlpha
+	ctx.blendDstAlpha = dfactorAlpha
+}
+
+func CullFace(mode Enum) {
+	ctx.cullFace = mode
+}
+
+func FrontFace(mode Enum) {
+	ctx.frontFace = mode
+}
+
+func DepthFunc(fn Enum) {
+	ctx.depthFunc = fn
+}
+
+func DepthMask(flag bool) {
+	ctx.depthMask = flag
+}
+
+func DepthRangef(n, f float32) {
+	ctx.depthRange = [2]float32{clamp01(n), clamp01(f)}
+}
+
+// Note: Only filled polygons are rasterized
+func PolygonMode(face, mode Enum) {}
+
+// Note: The offset is tracked, but not applied
+func PolygonOffset(factor, units float32) {
+	ctx.polygonOffset = [2]float32{factor, units}
+}
+
+func LineWidth(width float32) {
+	ctx.lineWidth = width
+}
+
+func Hint(target, mode Enum) {}
+
+func SampleCoverage(value float32, invert bool) {
+	ctx.sampleCoverage = value
+	ctx.sampleInvert = invert
+}
+
+func Scissor(x, y, width, height int32) {
+	ctx.scissorBox = [4]int{int(x), int(y), int(width), int(height)}
+}
+
+// Viewport sets the viewport. If the default framebuffer is bound it grows to contain it.
+func Viewport(x, y, width, height int) {
+	ctx.viewport = [4]int{x, y, width, height}
+	if ctx.drawFramebuffer.isDefault() {
+		ctx.resizeDefaultFramebuffer(x+width, y+height)
+	}
+}
+
+func stencilFaces(face Enum) []int {
+	switch face {
+	case FRONT:
+		return []int{0}
+	case BACK:
+		return []int{1}
+	}
+	return []int{0, 1}
+}
+
+func StencilFunc(fn Enum, ref int, mask uint32) {
+	StencilFuncSeparate(FRONT_AND_BACK, fn, ref, mask)
+}
+
+func StencilFuncSeparate(face, fn Enum, ref int, mask uint32) {
+	for _, i := range stencilFaces(face) {
+		ctx.stencilFunc[i] = fn
+		ctx.stencilRef[i] = ref
+		ctx.stencilMask[i] = mask
+	}
+}
+
+func StencilMask(mask uint32) {
+	StencilMaskSeparate(FRONT_AND_BACK, mask)
+}
+
+func StencilMaskSeparate(face Enum, mask uint32) {
+	for _, i := range stencilFaces(face) {
+		ctx.stencilWrite[i] = mask
+	}
+}
+
+func StencilOp(fail, zfail, zpass Enum) {
+	StencilOpSeparate(FRONT_AND_BACK, fail, zfail, zpass)
+}
+
+func StencilOpSeparate(face, sfail, dpfail, dppass Enum) {
+	for _, i := range stencilFaces(face) {
+		ctx.stencilOp[i] = [3]Enum{sfail, dpfail, dppass}
+	}
+}
+
+// --------------------------------------------------------------------------------
+// Textures
+
+func ActiveTexture(texture Enum) {
+	unit := int(texture) - TEXTURE0
+	if unit < 0 || unit >= maxTextureUnits {
+		ctx.setError(INVALID_ENUM)
+		return
+	}
+	ctx.activeTexture = unit
+}
+
+func BindTexture(target Enum, t Texture) {
+	if target != TEXTURE_2D {
+		ctx.setError(INVALID_ENUM)
+		return
+	}
+	if t.Value == 0 {
+		ctx.textureUnits[ctx.activeTexture] = nil
+		ctx.textureUnitNames[ctx.activeTexture] = 0
+		return
+	}
+	tex, ok := ctx.textures[t.Value]
+	if !ok {
+		tex = newSurface()
+		ctx.textures[t.Value] = tex
+	}
+	ctx.textureUnits[ctx.activeTexture] = tex
+	ctx.textureUnitNames[ctx.activeTexture] = t.Value
+}
+
+func CreateTexture() Texture {
+	name := ctx.genName()
+	ctx.textures[name] = newSurface()
+	return Texture{name}
+}
+
+func DeleteTexture(v Texture) {
+	tex, ok := ctx.textures[v.Value]
+	if !ok {
+		return
+	}
+	for i := range ctx.textureUnits {
+		if ctx.textureUnits[i] == tex {
+			ctx.textureUnits[i] = nil
+			ctx.textureUnitNames[i] = 0
+		}
+	}
+	delete(ctx.textures, v.Value)
+}
+
+func IsTexture(t Texture) bool {
+	_, ok := ctx.textures[t.Value]
+	return ok
+}
+
+func TexImage2D(target Enum, level int, width, height int, format Enum, ty Enum, data []byte) {
+	TexImage2DFull(target, level, format, width, height, format, ty, data)
+}
+
+// TexImage2DFull specifies the storage of the bound texture. Only level 0 is stored.
+func TexImage2DFull(target Enum, level int, format1 Enum, width, height int, format Enum, ty Enum, data []byte) {
+	tex := ctx.boundTexture()
+	if tex == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	if level != 0 {
+		return // Skip: Mipmap levels aren't stored
+	}
+	if !tex.alloc(format1, width, height) {
+		ctx.setError(INVALID_ENUM)
+		return
+	}
+	tex.upload(0, 0, width, height, format, ty, data)
+}
+
+func TexImage2DFullImguiPassthrough(target Enum, level int, format1 Enum, width, height int, format Enum, ty Enum, p unsafe.Pointer) {
+	var data []byte
+	if p != nil {
+		size := rowStride(width, ctx.unpackRowLength, ctx.unpackAlignment, format, ty) * height
+		data = unsafe.Slice((*byte)(p), size)
+	}
+	TexImage2DFull(target, level, format1, width, height, format, ty, data)
+}
+
+func TexSubImage2D(target Enum, level int, x, y, width, height int, format, ty Enum, data []byte) {
+	tex := ctx.boundTexture()
+	if tex == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	if level != 0 {
+		return
+	}
+	tex.upload(x, y, width, height, format, ty, data)
+}
+
+func CompressedTexImage2D(target Enum, level int, internalformat Enum, width, height, border int, data []byte) {
+	ctx.setError(INVALID_ENUM)
+}
+
+func CompressedTexSubImage2D(target Enum, level, xoffset, yoffset, width, height int, format Enum, data []byte) {
+	ctx.setError(INVALID_ENUM)
+}
+
+func CopyTexImage2D(target Enum, level int, internalformat Enum, x, y, width, height, border int) {
+	tex := ctx.boundTexture()
+	if tex == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	if !tex.alloc(internalformat, width, height) {
+		ctx.setError(INVALID_ENUM)
+		return
+	}
+	CopyTexSubImage2D(target, level, 0, 0, x, y, width, height)
+}
+
+func CopyTexSubImage2D(target Enum, level, xoffset, yoffset, x, y, width, height int) {
+	tex := ctx.boundTexture()
+	src := ctx.readFramebuffer.readTarget()
+	if tex == nil || src == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	all := [4]bool{true, true, true, true}
+	for j := 0; j < height; j++ {
+		for i := 0; i < width; i++ {
+			if !src.contains(x+i, y+j) || !tex.contains(xoffset+i, yoffset+j) {
+				continue
+			}
+			tex.set(xoffset+i, yoffset+j, src.get(x+i, y+j), all)
+		}
+	}
+}
+
+// Note: Mipmap levels aren't stored, sampling always reads the base level
+func GenerateMipmap(target Enum) {}
+
+func TexParameterf(target, pname Enum, param float32) {
+	if pname == TEXTURE_MAX_ANISOTROPY {
+		tex := ctx.boundTexture()
+		if tex == nil {
+			ctx.setError(INVALID_OPERATION)
+			return
+		}
+		tex.anisotropy = min(max(param, 1), maxAnisotropy)
+		return
+	}
+	TexParameteri(target, pname, int(param))
+}
+
+// InstancingSupported returns true if instances can be drawn, which the software rasterizer
+// always can
+func InstancingSupported() bool {
+	return true
+}
+
+// MaxAnisotropy returns the maximum anisotropic filtering level.
+// Note: The level is stored on textures but doesn't change how they are sampled
+func MaxAnisotropy() float32 {
+	return maxAnisotropy
+}
+
+func TexParameterfv(target, pname Enum, params []float32) {
+	TexParameteri(target, pname, int(params[0]))
+}
+
+func TexParameteri(target, pname Enum, param int) {
+	tex := ctx.boundTexture()
+	if tex == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	switch pname {
+	case TEXTURE_MIN_FILTER:
+		tex.minFilter = Enum(param)
+	case TEXTURE_MAG_FILTER:
+		tex.magFilter = Enum(param)
+	case TEXTURE_WRAP_S:
+		tex.wrapS = Enum(param)
+	case TEXTURE_WRAP_T:
+		tex.wrapT = Enum(param)
+	}
+}
+
+func TexParameteriv(target, pname Enum, params []int32) {
+	TexParameteri(target, pname, int(params[0]))
+}
+
+func GetTexParameteriv(dst []int32, target, pname Enum) {
+	tex := ctx.boundTexture()
+	if tex == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	switch pname {
+	case TEXTURE_MIN_FILTER:
+		dst[0] = int32(tex.minFilter)
+	case TEXTURE_MAG_FILTER:
+		dst[0] = int32(tex.magFilter)
+	case TEXTURE_WRAP_S:
+		dst[0] = int32(tex.wrapS)
+	case TEXTURE_WRAP_T:
+		dst[0] = int32(tex.wrapT)
+	default:
+		ctx.setError(INVALID_ENUM)
+	}
+}
+
+func GetTexParameterfv(dst []float32, target, pname Enum) {
+	if pname == TEXTURE_MAX_ANISOTROPY {
+		tex := ctx.boundTexture()
+		if tex == nil {
+			ctx.setError(INVALID_OPERATION)
+			return
+		}
+		dst[0] = tex.anisotropy
+		return
+	}
+	var v [1]int32
+	GetTexParameteriv(v[:], target, pname)
+	dst[0] = float32(v[0])
+}
+
+func PixelStorei(pname Enum, param int32) {
+	switch pname {
+	case UNPACK_ALIGNMENT:
+		ctx.unpackAlignment = int(param)
+	case UNPACK_ROW_LENGTH:
+		ctx.unpackRowLength = int(param)
+	case PACK_ALIGNMENT:
+		ctx.packAlignment = int(param)
+	}
+}
+
+// --------------------------------------------------------------------------------
+// Framebuffers and renderbuffers
+
+func CreateFramebuffer() Framebuffer {
+	name := ctx.genName()
+	ctx.framebuffers[name] = &framebuffer{
+		name:        name,
+		drawBuffers: []Enum{COLOR_ATTACHMENT0},
+		readBuffer:  COLOR_ATTACHMENT0,
+	}
+	return Framebuffer{name}
+}
+
+func BindFramebuffer(target Enum, fb Framebuffer) {
+	f := ctx.defaultFramebuffer
+	if fb.Value != 0 {
+		var ok bool
+		f, ok = ctx.framebuffers[fb.Value]
+		if !ok {
+			ctx.setError(INVALID_OPERATION)
+			return
+		}
+	}
+
+	switch target {
+	case FRAMEBUFFER:
+		ctx.readFramebuffer = f
+		ctx.drawFramebuffer = f
+	case READ_FRAMEBUFFER:
+		ctx.readFramebuffer = f
+	case DRAW_FRAMEBUFFER:
+		ctx.drawFramebuffer = f
+	default:
+		ctx.setError(INVALID_ENUM)
+	}
+}
+
+func DeleteFramebuffer(v Framebuffer) {
+	f, ok := ctx.framebuffers[v.Value]
+	if !ok {
+		return
+	}
+	if ctx.readFramebuffer == f {
+		ctx.readFramebuffer = ctx.defaultFramebuffer
+	}
+	if ctx.drawFramebuffer == f {
+		ctx.drawFramebuffer = ctx.defaultFramebuffer
+	}
+	delete(ctx.framebuffers, v.Value)
+}
+
+func IsFramebuffer(fb Framebuffer) bool {
+	_, ok := ctx.framebuffers[fb.Value]
+	return ok
+}
+
+// GetBoundFramebuffer returns the currently bound framebuffer.
+func GetBoundFramebuffer() Framebuffer {
+	return Framebuffer{ctx.drawFramebuffer.name}
+}
+
+func framebufferTarget(target Enum) *framebuffer {
+	if target == READ_FRAMEBUFFER {
+		return ctx.readFramebuffer
+	}
+	return ctx.drawFramebuffer
+}
+
+func attach(target, attachment Enum, s *surface) {
+	f := framebufferTarget(target)
+	if f.isDefault() {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	switch attachment {
+	case DEPTH_ATTACHMENT:
+		f.depth = s
+	case STENCIL_ATTACHMENT:
+		f.stencil = s
+	case DEPTH_STENCIL_ATTACHMENT:
+		f.depth = s
+		f.stencil = s
+	default:
+		i := int(attachment) - COLOR_ATTACHMENT0
+		if i < 0 || i >= maxColorAttachments {
+			ctx.setError(INVALID_ENUM)
+			return
+		}
+		f.colors[i] = s
+	}
+}
+
+func FramebufferTexture2D(target, attachment, texTarget Enum, t Texture, level int) {
+	var s *surface
+	if t.Value != 0 {
+		s = ctx.textures[t.Value]
+	}
+	attach(target, attachment, s)
+}
+
+func FramebufferRenderbuffer(target, attachment, rbTarget Enum, rb Renderbuffer) {
+	var s *surface
+	if rb.Value != 0 {
+		s = ctx.renderbuffers[rb.Value]
+	}
+	attach(target, attachment, s)
+}
+
+func CheckFramebufferStatus(target Enum) Enum {
+	f := framebufferTarget(target)
+	if f.isDefault() {
+		return FRAMEBUFFER_COMPLETE
+	}
+	var size [2]int
+	found := false
+	for _, s := range append(f.colors[:], f.depth, f.stencil) {
+		if s == nil {
+			continue
+		}
+		if s.width == 0 || s.height == 0 {
+			return FRAMEBUFFER_INCOMPLETE_ATTACHMENT
+		}
+		if found && size != [2]int{s.width, s.height} {
+			return FRAMEBUFFER_INCOMPLETE_DIMENSIONS
+		}
+		size = [2]int{s.width, s.height}
+		found = true
+	}
+	if !found {
+		return FRAMEBUFFER_INCOMPLETE_MISSING_ATTACHMENT
+	}
+	return FRAMEBUFFER_COMPLETE
+}
+
+func GetFramebufferAttachmentParameteri(target, attachment, pname Enum) int {
+	f := framebufferTarget(target)
+	var s *surface
+	switch attachment {
+	case DEPTH_ATTACHMENT, DEPTH_STENCIL_ATTACHMENT:
+		s = f.depth
+	case STENCIL_ATTACHMENT:
+		s = f.stencil
+	default:
+		s = f.attachment(attachment)
+	}
+
+	switch pname {
+	case FRAMEBUFFER_ATTACHMENT_OBJECT_TYPE:
+		if s == nil {
+			return NONE
+		}
+		for _, t := range ctx.textures {
+			if t == s {
+				return TEXTURE
+			}
+		}
+		return RENDERBUFFER
+	case FRAMEBUFFER_ATTACHMENT_OBJECT_NAME:
+		for name, t := range ctx.textures {
+			if t == s {
+				return int(name)
+			}
+		}
+		for name, r := range ctx.renderbuffers {
+			if r == s {
+				return int(name)
+			}
+		}
+		return 0
+	}
+	ctx.setError(INVALID_ENUM)
+	return 0
+}
+
+func DrawBuffer(target Enum) {
+	ctx.drawFramebuffer.drawBuffers = []Enum{target}
+}
+
+// DrawBuffers sets the color attachments that fragment outputs 0, 1, 2... are written to
+func DrawBuffers(targets []Enum) {
+	if len(targets) > maxColorAttachments {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	ctx.drawFramebuffer.drawBuffers = append([]Enum(nil), targets...)
+}
+
+func ReadBuffer(target Enum) {
+	ctx.readFramebuffer.readBuffer = target
+}
+
+// BlitFramebuffer copies a block of pixels from the read framebuffer to the draw framebuffer.
+// Note: Scaled blits always use nearest filtering
+func BlitFramebuffer(srcX0 int32, srcY0 int32, srcX1 int32, srcY1 int32, dstX0 int32, dstY0 int32, dstX1 int32, dstY1 int32, mask uint32, filter uint32) {
+	ctx.blit(
+		[4]int{int(srcX0), int(srcY0), int(srcX1), int(srcY1)},
+		[4]int{int(dstX0), int(dstY0), int(dstX1), int(dstY1)},
+		Enum(mask),
+	)
+}
+
+// ReadPixels returns pixel data from the read buffer of the bound read framebuffer.
+// Rows are returned bottom to top, as in GL.
+func ReadPixels(dst []byte, x, y, width, height int, format, ty Enum) {
+	f := ctx.readFramebuffer
+	src := f.readTarget()
+	if format == DEPTH_COMPONENT {
+		src = f.depthSurface()
+	}
+	if src == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	// Like OpenGL ES, float color buffers can only be read as floats, and normalized color
+	// buffers can't be
+	if !src.depth && (src.bits == 0) != (ty == FLOAT) {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	src.download(dst, x, y, width, height, format, ty)
+}
+
+// ReadPixelsOffset is ReadPixels, but writes into the buffer bound to PIXEL_PACK_BUFFER
+// starting at offset.
+func ReadPixelsOffset(offset int, x, y, width, height int, format, ty Enum) {
+	b := ctx.boundBuffer(PIXEL_PACK_BUFFER)
+	if b == nil || offset < 0 || offset > len(b.data) {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	ReadPixels(b.data[offset:], x, y, width, height, format, ty)
+}
+
+func CreateRenderbuffer() Renderbuffer {
+	name := ctx.genName()
+	ctx.renderbuffers[name] = newSurface()
+	return Renderbuffer{name}
+}
+
+func BindRenderbuffer(target Enum, rb Renderbuffer) {
+	if rb.Value == 0 {
+		ctx.renderbuffer = nil
+		ctx.renderbufferName = 0
+		return
+	}
+	r, ok := ctx.renderbuffers[rb.Value]
+	if !ok {
+		r = newSurface()
+		ctx.renderbuffers[rb.Value] = r
+	}
+	ctx.renderbuffer = r
+	ctx.renderbufferName = rb.Value
+}
+
+func DeleteRenderbuffer(v Renderbuffer) {
+	if ctx.renderbufferName == v.Value {
+		ctx.renderbuffer = nil
+		ctx.renderbufferName = 0
+	}
+	delete(ctx.renderbuffers, v.Value)
+}
+
+func IsRenderbuffer(rb Renderbuffer) bool {
+	_, ok := ctx.renderbuffers[rb.Value]
+	return ok
+}
+
+func RenderbufferStorage(target, internalFormat Enum, width, height int) {
+	if ctx.renderbuffer == nil {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	if !ctx.renderbuffer.alloc(internalFormat, width, height) {
+		ctx.setError(INVALID_ENUM)
+	}
+}
+
+// RenderbufferStorageMultisample allocates the renderbuffer storage.
+// Note: The software rasterizer doesn't multisample, so the storage is always single sampled
+func RenderbufferStorageMultisample(target Enum, samples int, internalFormat Enum, width, height int) {
+	RenderbufferStorage(target, internalFormat, width, height)
+}
+
+func GetRenderbufferParameteri(target, pname Enum) int {
+	r := ctx.renderbuffer
+	if r == nil {
+		ctx.setError(INVALID_OPERATION)
+		return 0
+	}
+	switch pname {
+	case RENDERBUFFER_WIDTH:
+		return r.width
+	case RENDERBUFFER_HEIGHT:
+		return r.height
+	case RENDERBUFFER_INTERNAL_FORMAT:
+		return int(r.format)
+	}
+	ctx.setError(INVALID_ENUM)
+	return 0
+}
+
+// --------------------------------------------------------------------------------
+// Shaders and programs
+
+func CreateShader(ty Enum) Shader {
+	if ty != VERTEX_SHADER && ty != FRAGMENT_SHADER {
+		ctx.setError(INVALID_ENUM)
+		return NoShader
+	}
+	name := ctx.genName()
+	ctx.shaders[name] = &shader{ty: ty}
+	return Shader{name}
+}
+
+func ShaderSource(s Shader, src string) {
+	sh, ok := ctx.shaders[s.Value]
+	if !ok {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	sh.source = src
+}
+
+// CompileShader always succeeds. The GLSL itself is never parsed beyond its declarations,
+// missing kernels are reported when the program is linked.
+func CompileShader(s Shader) {
+	sh, ok := ctx.shaders[s.Value]
+	if !ok {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	sh.compiled = true
+}
+
+func DeleteShader(s Shader) {
+	sh, ok := ctx.shaders[s.Value]
+	if !ok {
+		return
+	}
+	sh.deleted = true
+	delete(ctx.shaders, s.Value)
+}
+
+func IsShader(s Shader) bool {
+	_, ok := ctx.shaders[s.Value]
+	return ok
+}
+
+func GetShaderi(s Shader, pname Enum) int {
+	sh, ok := ctx.shaders[s.Value]
+	if !ok {
+		ctx.setError(INVALID_VALUE)
+		return 0
+	}
+	switch pname {
+	case SHADER_TYPE:
+		return int(sh.ty)
+	case COMPILE_STATUS:
+		return int(boolToInt(sh.compiled))
+	case DELETE_STATUS:
+		return int(boolToInt(sh.deleted))
+	case INFO_LOG_LENGTH:
+		return 0
+	case SHADER_SOURCE_LENGTH:
+		if sh.source == "" {
+			return 0
+		}
+		return len(sh.source) + 1
+	}
+	ctx.setError(INVALID_ENUM)
+	return 0
+}
+
+func GetShaderInfoLog(s Shader) string {
+	return ""
+}
+
+func GetShaderSource(s Shader) string {
+	sh, ok := ctx.shaders[s.Value]
+	if !ok {
+		return ""
+	}
+	return sh.source
+}
+
+func GetShaderPrecisionFormat(shadertype, precisiontype Enum) (rangeLow, rangeHigh, precision int) {
+	return 127, 127, 23
+}
+
+func ReleaseShaderCompiler() {}
+
+func CreateProgram() Program {
+	name := ctx.genName()
+	ctx.programs[name] = &program{
+		attribBindings: make(map[string]int),
+	}
+	return Program{name}
+}
+
+func AttachShader(p Program, s Shader) {
+	prog, ok := ctx.programs[p.Value]
+	sh, ok2 := ctx.shaders[s.Value]
+	if !ok || !ok2 {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	prog.shaders = append(prog.shaders, sh)
+}
+
+func DetachShader(p Program, s Shader) {
+	prog, ok := ctx.programs[p.Value]
+	if !ok {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	sh := ctx.shaders[s.Value]
+	for i := range prog.shaders {
+		if prog.shaders[i] == sh {
+			prog.shaders = append(prog.shaders[:i], prog.shaders[i+1:]...)
+			return
+		}
+	}
+}
+
+func GetAttachedShaders(p Program) []Shader {
+	prog, ok := ctx.programs[p.Value]
+	if !ok {
+		return nil
+	}
+	ret := make([]Shader, 0, len(prog.shaders))
+	for name, sh := range ctx.shaders {
+		for _, attached := range prog.shaders {
+			if sh == attached {
+				ret = append(ret, Shader{name})
+			}
+		}
+	}
+	return ret
+}
+
+func BindAttribLocation(p Program, a Attrib, name string) {
+	prog, ok := ctx.programs[p.Value]
+	if !ok {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	prog.attribBindings[name] = a.Value
+}
+
+// LinkProgram links the program by looking up the Go kernels registered for the sources of
+// its shaders. The attributes and uniforms are taken from the GLSL declarations.
+func LinkProgram(p Program) {
+	prog, ok := ctx.programs[p.Value]
+	if !ok {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	prog.linked = false
+	prog.infoLog = ""
+	err := prog.link()
+	if err != nil {
+		prog.infoLog = err.Error()
+		return
+	}
+	prog.linked = true
+}
+
+func (p *program) link() error {
+	var vs, fs *shader
+	for _, sh := range p.shaders {
+		switch sh.ty {
+		case VERTEX_SHADER:
+			vs = sh
+		case FRAGMENT_SHADER:
+			fs = sh
+		}
+	}
+	if vs == nil || fs == nil {
+		return fmt.Errorf("headless: program needs a vertex and a fragment shader")
+	}
+
+	p.vertex = vertexKernels[kernelKey(vs.source)]
+	if p.vertex == nil {
+		return fmt.Errorf("headless: no Go kernel registered for vertex shader:\n%s", vs.source)
+	}
+	p.fragment = fragmentKernels[kernelKey(fs.source)]
+	if p.fragment == nil {
+		return fmt.Errorf("headless: no Go kernel registered for fragment shader:\n%s", fs.source)
+	}
+
+	// Match the fragment inputs to the vertex outputs by name
+	offsets := make(map[string]int)
+	offset := 0
+	for _, v := range p.vertex.Varyings {
+		offsets[v.Name] = offset
+		offset += v.Size
+	}
+	p.varyingMap = make([]int, 0)
+	for _, v := range p.fragment.Varyings {
+		start, ok := offsets[v.Name]
+		if !ok {
+			return fmt.Errorf("headless: fragment input %q isn't written by the vertex shader", v.Name)
+		}
+		for i := 0; i < v.Size; i++ {
+			p.varyingMap = append(p.varyingMap, start+i)
+		}
+	}
+
+	// Attributes: explicit layout locations win, then BindAttribLocation, then the first free slot
+	p.attribs = scanInputs(vs.source)
+	p.attribLocs = make(map[string]int)
+	used := make(map[int]bool)
+	for i := range p.attribs {
+		if p.attribs[i].location < 0 {
+			if loc, ok := p.attribBindings[p.attribs[i].name]; ok {
+				p.attribs[i].location = loc
+			}
+		}
+		if p.attribs[i].location >= 0 {
+			for n := 0; n < p.attribs[i].slots(); n++ {
+				used[p.attribs[i].location+n] = true
+			}
+		}
+	}
+	for i := range p.attribs {
+		slots := p.attribs[i].slots()
+		if p.attribs[i].location < 0 {
+			next := 0
+			for !freeSlots(used, next, slots) {
+				next++
+			}
+			p.attribs[i].location = next
+			for n := 0; n < slots; n++ {
+				used[next+n] = true
+			}
+		}
+		if p.attribs[i].location+slots > maxVertexAttribs {
+			return fmt.Errorf("headless: too many vertex attributes")
+		}
+		p.attribLocs[p.attribs[i].name] = p.attribs[i].location
+	}
+
+	// Uniforms
+	p.uniforms = p.uniforms[:0]
+	p.uniformIndex = make(map[string]int)
+	p.locations = p.locations[:0]
+	for _, sh := range []*shader{vs, fs} {
+		for _, decl := range scanUniforms(sh.source) {
+			if _, ok := p.uniformIndex[decl.name]; ok {
+				continue // Skip: Already declared by the other stage
+			}
+			idx := len(p.uniforms)
+			p.uniforms = append(p.uniforms, uniform{
+				declaration: decl,
+				value:       make([]float32, decl.size*typeComponents(decl.ty)),
+			})
+			p.uniformIndex[decl.name] = idx
+			for e := 0; e < decl.size; e++ {
+				p.locations = append(p.locations, uniformLocation{idx, e})
+			}
+		}
+	}
+	return nil
+}
+
+func DeleteProgram(p Program) {
+	prog, ok := ctx.programs[p.Value]
+	if !ok {
+		return
+	}
+	if ctx.program == prog {
+		ctx.program = nil
+		ctx.programName = 0
+	}
+	delete(ctx.programs, p.Value)
+}
+
+func IsProgram(p Program) bool {
+	_, ok := ctx.programs[p.Value]
+	return ok
+}
+
+func UseProgram(p Program) {
+	if p.Value == 0 {
+		ctx.program = nil
+		ctx.programName = 0
+		return
+	}
+	prog, ok := ctx.programs[p.Value]
+	if !ok || !prog.linked {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	ctx.program = prog
+	ctx.programName = p.Value
+}
+
+func ValidateProgram(p Program) {
+	prog, ok := ctx.programs[p.Value]
+	if !ok {
+		ctx.setError(INVALID_VALUE)
+		return
+	}
+	prog.validated = prog.linked
+}
+
+func GetProgrami(p Program, pname Enum) int {
+	prog, ok := ctx.programs[p.Value]
+	if !ok {
+		ctx.setError(INVALID_VALUE)
+		return 0
+	}
+	switch pname {
+	case LINK_STATUS:
+		return int(boolToInt(prog.linked))
+	case VALIDATE_STATUS:
+		return int(boolToInt(prog.validated))
+	case DELETE_STATUS:
+		return 0
+	case INFO_LOG_LENGTH:
+		if prog.infoLog == "" {
+			return 0
+		}
+		return len(prog.infoLog) + 1
+	case ATTACHED_SHADERS:
+		return len(prog.shaders)
+	case ACTIVE_ATTRIBUTES:
+		return len(prog.attribs)
+	case ACTIVE_UNIFORMS:
+		return len(prog.uniforms)
+	case ACTIVE_ATTRIBUTE_MAX_LENGTH:
+		n := 0
+		for _, a := range prog.attribs {
+			n = max(n, len(a.name)+1)
+		}
+		return n
+	case ACTIVE_UNIFORM_MAX_LENGTH:
+		n := 0
+		for _, u := range prog.uniforms {
+			n = max(n, len(u.name)+len("[0]")+1)
+		}
+		return n
+	}
+	ctx.setError(INVALID_ENUM)
+	return 0
+}
+
+func GetProgramInfoLog(p Program) string {
+	prog, ok := ctx.programs[p.Value]
+	if !ok {
+		return ""
+	}
+	return prog.infoLog
+}
+
+func GetActiveAttrib(p Program, index uint32) (name string, size int, ty Enum) {
+	prog, ok := ctx.programs[p.Value]
+	if !ok || int(index) >= len(prog.attribs) {
+		ctx.setError(INVALID_VALUE)
+		return "", 0, 0
+	}
+	a := prog.attribs[index]
+	return a.name, a.size, a.ty
+}
+
+func GetActiveUniform(p Program, index uint32) (name string, size int, ty Enum) {
+	prog, ok := ctx.programs[p.Value]
+	if !ok || int(index) >= len(prog.uniforms) {
+		ctx.setError(INVALID_VALUE)
+		return "", 0, 0
+	}
+	u := prog.uniforms[index]
+	name = u.name
+	if u.size > 1 {
+		name += "[0]"
+	}
+	return name, u.size, u.ty
+}
+
+func GetAttribLocation(p Program, name string) Attrib {
+	prog, ok := ctx.programs[p.Value]
+	if !ok || !prog.linked {
+		ctx.setError(INVALID_OPERATION)
+		return Attrib{-1}
+	}
+	loc, ok := prog.attribLocs[name]
+	if !ok {
+		return Attrib{-1}
+	}
+	return Attrib{loc}
+}
+
+func GetUniformLocation(p Program, name string) Uniform {
+	prog, ok := ctx.programs[p.Value]
+	if !ok || !prog.linked {
+		ctx.setError(INVALID_OPERATION)
+		return Uniform{-1}
+	}
+
+	element := 0
+	if i := strings.IndexByte(name, '['); i >= 0 && strings.HasSuffix(name, "]") {
+		e, err := strconv.Atoi(name[i+1 : len(name)-1])
+		if err != nil {
+			return Uniform{-1}
+		}
+		name, element = name[:i], e
+	}
+	idx, ok := prog.uniformIndex[name]
+	if !ok || element >= prog.uniforms[idx].size {
+		return Uniform{-1}
+	}
+	for loc, l := range prog.locations {
+		if l.uniform == idx && l.element == element {
+			return Uniform{int32(loc)}
+		}
+	}
+	return Uniform{-1}
+}
+
+// uniformDest returns the slice of uniform storage that a write to dst starts at
+func uniformDest(dst Uniform) []float32 {
+	p := ctx.program
+	if p == nil {
+		ctx.setError(INVALID_OPERATION)
+		return nil
+	}
+	if dst.Value < 0 || int(dst.Value) >= len(p.locations) {
+		return nil // Note: Like GL, writes to location -1 are silently ignored
+	}
+	l := p.locations[dst.Value]
+	u := p.uniforms[l.uniform]
+	return u.value[l.element*typeComponents(u.ty):]
+}
+
+func uniformf(dst Uniform, src ...float32) {
+	copy(uniformDest(dst), src)
+}
+
+func uniformi(dst Uniform, src ...int32) {
+	dest := uniformDest(dst)
+	for i := 0; i < len(src) && i < len(dest); i++ {
+		dest[i] = float32(src[i])
+	}
+}
+
+func Uniform1f(dst Uniform, v float32)              { uniformf(dst, v) }
+func Uniform1fv(dst Uniform, src []float32)         { uniformf(dst, src...) }
+func Uniform1i(dst Uniform, v int)                  { uniformi(dst, int32(v)) }
+func Uniform1iv(dst Uniform, src []int32)           { uniformi(dst, src...) }
+func Uniform2f(dst Uniform, v0, v1 float32)         { uniformf(dst, v0, v1) }
+func Uniform2fv(dst Uniform, src []float32)         { uniformf(dst, src...) }
+func Uniform2i(dst Uniform, v0, v1 int)             { uniformi(dst, int32(v0), int32(v1)) }
+func Uniform2iv(dst Uniform, src []int32)           { uniformi(dst, src...) }
+func Uniform3f(dst Uniform, v0, v1, v2 float32)     { uniformf(dst, v0, v1, v2) }
+func Uniform3fv(dst Uniform, src []float32)         { uniformf(dst, src...) }
+func Uniform3i(dst Uniform, v0, v1, v2 int32)       { uniformi(dst, v0, v1, v2) }
+func Uniform3iv(dst Uniform, src []int32)           { uniformi(dst, src...) }
+func Uniform4f(dst Uniform, v0, v1, v2, v3 float32) { uniformf(dst, v0, v1, v2, v3) }
+func Uniform4fv(dst Uniform, src []float32)         { uniformf(dst, src...) }
+func Uniform4i(dst Uniform, v0, v1, v2, v3 int32)   { uniformi(dst, v0, v1, v2, v3) }
+func Uniform4iv(dst Uniform, src []int32)           { uniformi(dst, src...) }
+func UniformMatrix2fv(dst Uniform, src []float32)   { uniformf(dst, src...) }
+func UniformMatrix4fv(dst Uniform, src []float32)   { uniformf(dst, src...) }
+
+func UniformMatrix3fv(dst Uniform, count int32, transpose bool, value *float32) {
+	src := unsafe.Slice(value, 9*int(count))
+	if !transpose {
+		uniformf(dst, src...)
+		return
+	}
+	m := make([]float32, len(src))
+	for n := 0; n < int(count); n++ {
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				m[9*n+3*i+j] = src[9*n+3*j+i]
+			}
+		}
+	}
+	uniformf(dst, m...)
+}
+
+func GetUniformfv(dst []float32, src Uniform, p Program) {
+	prog, ok := ctx.programs[p.Value]
+	if !ok || src.Value < 0 || int(src.Value) >= len(prog.locations) {
+		ctx.setError(INVALID_OPERATION)
+		return
+	}
+	l := prog.locations[src.Value]
+	u := prog.uniforms[l.uniform]
+	n := typeComponents(u.ty)
+	copy(dst, u.value[l.element*n:(l.element+1)*n])
+}
+
+func GetUniformiv(dst []int32, src Uniform, p Program) {
+	tmp := make([]float32, len(dst))
+	GetUniformfv(tmp, src, p)
+	for i := range dst {
+		dst[i] = int32(tmp[i])
+	}
+}
+
+// --------------------------------------------------------------------------------
+// Queries
+
+func GetError() Enum {
+	err := ctx.lastError
+	ctx.lastError = NO_ERROR
+	return err
+}
+
+func GetString(pname Enum) string {
+	switch pname {
+	case VENDOR:
+		return "glitch"
+	case RENDERER:
+		return "headless software rasterizer"
+	case VERSION:
+		return "3.3 headless"
+	case SHADING_LANGUAGE_VERSION:
+		return "3.30 headless"
+	}
+	return ""
+}
+
+func GetBooleanv(dst []bool, pname Enum) {
+	switch pname {
+	case COLOR_WRITEMASK:
+		copy(dst, ctx.colorMask[:])
+	case DEPTH_WRITEMASK:
+		dst[0] = ctx.depthMask
+	default:
+		dst[0] = ctx.capabilities[pname]
+	}
+}
+
+func GetFloatv(dst []float32, pname Enum) {
+	switch pname {
+	case COLOR_CLEAR_VALUE:
+		copy(dst, ctx.clearColor[:])
+	case DEPTH_CLEAR_VALUE:
+		dst[0] = ctx.clearDepth
+	case BLEND_COLOR:
+		copy(dst, ctx.blendColor[:])
+	case DEPTH_RANGE:
+		copy(dst, ctx.depthRange[:])
+	case LINE_WIDTH:
+		dst[0] = ctx.lineWidth
+	case MAX_TEXTURE_MAX_ANISOTROPY:
+		dst[0] = maxAnisotropy
+	default:
+		data := make([]int32, len(dst))
+		GetIntegerv(pname, data)
+		for i := range dst {
+			dst[i] = float32(data[i])
+		}
+	}
+}
+
+func GetIntegerv(pname Enum, data []int32) {
+	set := func(v ...int) {
+		for i := 0; i < len(v) && i < len(data); i++ {
+			data[i] = int32(v[i])
+		}
+	}
+	switch pname {
+	case VIEWPORT:
+		set(ctx.viewport[:]...)
+	case SCISSOR_BOX:
+		set(ctx.scissorBox[:]...)
+	case FRAMEBUFFER_BINDING:
+		set(int(ctx.drawFramebuffer.name))
+	case READ_FRAMEBUFFER_BINDING:
+		set(int(ctx.readFramebuffer.name))
+	case RENDERBUFFER_BINDING:
+		set(int(ctx.renderbufferName))
+	case TEXTURE_BINDING_2D:
+		set(int(ctx.textureUnitNames[ctx.activeTexture]))
+	case ACTIVE_TEXTURE:
+		set(TEXTURE0 + ctx.activeTexture)
+	case ARRAY_BUFFER_BINDING:
+		set(int(ctx.arrayBuffer))
+	case PIXEL_PACK_BUFFER_BINDING:
+		set(int(ctx.pixelPackBuffer))
+	case ELEMENT_ARRAY_BUFFER_BINDING:
+		set(int(ctx.vertexArray.elementsName))
+	case VERTEX_ARRAY_BINDING:
+		set(int(ctx.vertexArrayName))
+	case CURRENT_PROGRAM:
+		set(int(ctx.programName))
+	case BLEND_SRC_RGB:
+		set(int(ctx.blendSrcRGB))
+	case BLEND_DST_RGB:
+		set(int(ctx.blendDstRGB))
+	case BLEND_SRC_ALPHA:
+		set(int(ctx.blendSrcAlpha))
+	case BLEND_DST_ALPHA:
+		set(int(ctx.blendDstAlpha))
+	case BLEND_EQUATION_RGB:
+		set(int(ctx.blendEqRGB))
+	case BLEND_EQUATION_ALPHA:
+		set(int(ctx.blendEqAlpha))
+	case DEPTH_FUNC:
+		set(int(ctx.depthFunc))
+	case CULL_FACE_MODE:
+		set(int(ctx.cullFace))
+	case FRONT_FACE:
+		set(int(ctx.frontFace))
+	case UNPACK_ALIGNMENT:
+		set(ctx.unpackAlignment)
+	case UNPACK_ROW_LENGTH:
+		set(ctx.unpackRowLength)
+	case PACK_ALIGNMENT:
+		set(ctx.packAlignment)
+	case STENCIL_CLEAR_VALUE:
+		set(ctx.clearStencil)
+	case MAX_TEXTURE_SIZE, MAX_RENDERBUFFER_SIZE:
+		set(maxTextureSize)
+	case MAX_VIEWPORT_DIMS:
+		set(maxTextureSize, maxTextureSize)
+	case MAX_VERTEX_ATTRIBS:
+		set(maxVertexAttribs)
+	case MAX_TEXTURE_IMAGE_UNITS, MAX_COMBINED_TEXTURE_IMAGE_UNITS:
+		set(maxTextureUnits)
+	case MAX_COLOR_ATTACHMENTS, MAX_DRAW_BUFFERS:
+		set(maxColorAttachments)
+	case SAMPLES:
+		set(0)
+	default:
+		set(0)
+	}
+}
+
+// GetInteger returns the int value of parameter pname.
+func GetInteger(pname Enum) Object {
+	var data [4]int32
+	GetIntegerv(pname, data[:])
+	return Object{uint32(data[0])}
+}
@@ -1,5 +1,5 @@
-//go:build !js
-// +build !js
+//go:build !js && !headless
+// +build !js,!headless
 
 package gl
 
@@ -94,11 +94,27 @@ func ReadBuffer(target Enum) {
 	gl.ReadBuffer(uint32(target))
 }
 
+func DrawBuffers(targets []Enum) {
+	bufs := make([]uint32, len(targets))
+	for i := range targets {
+		bufs[i] = uint32(targets[i])
+	}
+	gl.DrawBuffers(int32(len(bufs)), &bufs[0])
+}
+
 // gl.VertexAttribPointer(uint32(loc), int32(vectorSize), gl.FLOAT, false, int32(vectorSize * componentSize), gl.PtrOffset(offset))
 func VertexAttribPointer(dst Attrib, size int, ty Enum, normalized bool, stride int, offset int) {
 	gl.VertexAttribPointer(uint32(dst.Value), int32(size), uint32(ty), normalized, int32(stride), gl.PtrOffset(offset))
 }
 
+// VertexAttribDivisor sets how many instances are drawn before the attribute advances. Zero
+// means that the attribute advances once per vertex.
+//
+// https://registry.khronos.org/OpenGL-Refpages/es3.0/html/glVertexAttribDivisor.xhtml
+func VertexAttribDivisor(index Attrib, divisor int) {
+	gl.VertexAttribDivisor(uint32(index.Value), uint32(divisor))
+}
+
 func VertexAttribIPointer(dst Attrib, size int, ty Enum, stride int, offset int) {
 	gl.VertexAttribIPointer(uint32(dst.Value), int32(size), uint32(ty), int32(stride), gl.PtrOffset(offset))
 }
@@ -255,6 +271,11 @@ func BufferSubData(target Enum, offset int, data interface{}) {
 	gl.BufferSubData(uint32(target), offset, size, gl.Ptr(data))
 }
 
+// GetBufferSubDataByte reads len(dst) bytes from the bound buffer, starting at offset.
+func GetBufferSubDataByte(target Enum, offset int, dst []byte) {
+	gl.GetBufferSubData(uint32(target), offset, len(dst), gl.Ptr(&dst[0]))
+}
+
 func GetBufferSubData(target Enum, offset int, data interface{}) {
 	size := 0
 	// TODO - other types
@@ -522,6 +543,13 @@ func DrawElements(mode Enum, count int, ty Enum, offset int) {
 	gl.DrawElements(uint32(mode), int32(count), uint32(ty), gl.PtrOffset(offset))
 }
 
+// DrawElementsInstanced renders instanceCount copies of the primitives from a bound buffer.
+//
+// https://registry.khronos.org/OpenGL-Refpages/es3.0/html/glDrawElementsInstanced.xhtml
+func DrawElementsInstanced(mode Enum, count int, ty Enum, offset int, instanceCount int) {
+	gl.DrawElementsInstanced(uint32(mode), int32(count), uint32(ty), gl.PtrOffset(offset), int32(instanceCount))
+}
+
 // Enable enables various GL capabilities.
 //
 // http://www.khronos.org/opengles/sdk/docs/man3/html/glEnable.xhtml
@@ -811,6 +839,27 @@ func GetString(pname Enum) string {
 	return gl.GoStr(gl.GetString(uint32(pname)))
 }
 
+// InstancingSupported returns true if instances can be drawn, which OpenGL 3.3 always can
+func InstancingSupported() bool {
+	return true
+}
+
+// MaxAnisotropy returns the maximum anisotropic filtering level, or 0 if the anisotropic
+// filtering extension isn't available.
+func MaxAnisotropy() float32 {
+	var n int32
+	gl.GetIntegerv(NUM_EXTENSIONS, &n)
+	for i := uint32(0); i < uint32(n); i++ {
+		ext := gl.GoStr(gl.GetStringi(EXTENSIONS, i))
+		if ext == "GL_EXT_texture_filter_anisotropic" || ext == "GL_ARB_texture_filter_anisotropic" {
+			var max float32
+			gl.GetFloatv(MAX_TEXTURE_MAX_ANISOTROPY, &max)
+			return max
+		}
+	}
+	return 0
+}
+
 // GetTexParameterfv returns the float values of a texture parameter.
 //
 // http://www.khronos.org/opengles/sdk/docs/man3/html/glGetTexParameter.xhtml
@@ -970,6 +1019,12 @@ func ReadPixels(dst []byte, x, y, width, height int, format, ty Enum) {
 	gl.ReadPixels(int32(x), int32(y), int32(width), int32(height), uint32(format), uint32(ty), gl.Ptr(&dst[0]))
 }
 
+// ReadPixelsOffset is ReadPixels, but writes into the buffer bound to PIXEL_PACK_BUFFER
+// starting at offset. The transfer happens asynchronously on the GPU.
+func ReadPixelsOffset(offset int, x, y, width, height int, format, ty Enum) {
+	gl.ReadPixels(int32(x), int32(y), int32(width), int32(height), uint32(format), uint32(ty), gl.PtrOffset(offset))
+}
+
 // ReleaseShaderCompiler frees resources allocated by the shader compiler.
 //
 // http://www.khronos.org/opengles/sdk/docs/man3/html/glReleaseShaderCompiler.xhtml
@@ -985,6 +1040,14 @@ func RenderbufferStorage(target, internalFormat Enum, width, height int) {
 	gl.RenderbufferStorage(uint32(target), uint32(internalFormat), int32(width), int32(height))
 }
 
+// RenderbufferStorageMultisample establishes the data storage, format, number of samples and
+// dimensions of a renderbuffer object's image.
+//
+// http://www.khronos.org/opengles/sdk/docs/man3/html/glRenderbufferStorageMultisample.xhtml
+func RenderbufferStorageMultisample(target Enum, samples int, internalFormat Enum, width, height int) {
+	gl.RenderbufferStorageMultisample(uint32(target), int32(samples), uint32(internalFormat), int32(width), int32(height))
+}
+
 // SampleCoverage sets multisample coverage parameters.
 //
 // http://www.khronos.org/opengles/sdk/docs/man3/html/glSampleCoverage.xhtml
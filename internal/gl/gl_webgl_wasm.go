@@ -87,6 +87,8 @@ var (
 	fnDeleteVertexArray       js.Value
 	fnDeleteBuffer            js.Value
 	fnDrawElements            js.Value
+	fnDrawElementsInstanced   js.Value
+	fnVertexAttribDivisor     js.Value
 
 	fnEnable    js.Value
 	fnDepthFunc js.Value
@@ -96,6 +98,7 @@ var (
 	fnFrontFace js.Value
 
 	fnBindFramebuffer  js.Value
+	fnUniform1i        js.Value
 	fnUniform1fv       js.Value
 	fnUniform2fv       js.Value
 	fnUniform3fv       js.Value
@@ -165,6 +168,7 @@ func (contextWatcher) OnMakeCurrent(context interface{}) {
 	fnDepthFunc = c.Get("depthFunc").Call("bind", c)
 	fnCullFace = c.Get("cullFace").Call("bind", c)
 	fnFrontFace = c.Get("frontFace").Call("bind", c)
+	fnUniform1i = c.Get("uniform1i").Call("bind", c)
 	fnUniform1fv = c.Get("uniform1fv").Call("bind", c)
 	fnUniform2fv = c.Get("uniform2fv").Call("bind", c)
 	fnUniform3fv = c.Get("uniform3fv").Call("bind", c)
@@ -191,10 +195,19 @@ func (contextWatcher) OnMakeCurrent(context interface{}) {
 	fnTexSubImage2D = c.Get("texSubImage2D").Call("bind", c)
 
 	// WebGL2 Only
+	instancing = false
 	if !webgl1Mode {
 		fnBindVertexArray = c.Get("bindVertexArray").Call("bind", c)
 		fnCreateVertexArray = c.Get("createVertexArray").Call("bind", c)
 		fnDeleteVertexArray = c.Get("deleteVertexArray").Call("bind", c)
+		fnDrawElementsInstanced = c.Get("drawElementsInstanced").Call("bind", c)
+		fnVertexAttribDivisor = c.Get("vertexAttribDivisor").Call("bind", c)
+		instancing = true
+	} else if ext := c.Call("getExtension", "ANGLE_instanced_arrays"); !ext.IsNull() {
+		// WebGL1 can only draw instances through the extension
+		fnDrawElementsInstanced = ext.Get("drawElementsInstancedANGLE").Call("bind", ext)
+		fnVertexAttribDivisor = ext.Get("vertexAttribDivisorANGLE").Call("bind", ext)
+		instancing = true
 	}
 }
 func (contextWatcher) OnDetach() {
@@ -205,6 +218,7 @@ func (contextWatcher) OnDetach() {
 var c js.Value
 var versionStr string
 var webgl1Mode bool
+var instancing bool // Set if the context can draw instances
 
 func sliceToByteSlice(s any) []byte {
 	switch s := s.(type) {
@@ -390,6 +404,18 @@ func byteSliceToTypedArray(s []byte) (js.Value, int) {
 	return jsMemory, len(s)
 }
 
+// Returns the first length bytes of the copy buffer, viewed as the typed array that webgl
+// requires for pixel data of type ty
+func pixelSubarray(length int, ty Enum) js.Value {
+	switch ty {
+	case FLOAT:
+		return jsMemoryFloat32.Call("subarray", 0, length/4)
+	case UNSIGNED_INT:
+		return jsMemoryUint32.Call("subarray", 0, length/4)
+	}
+	return jsMemory.Call("subarray", 0, length)
+}
+
 //--------------------------------------------------------------------------------
 
 func GenVertexArrays() Buffer {
@@ -436,8 +462,7 @@ func BufferData(target Enum, size int, data any, usage Enum) {
 }
 
 func BlitFramebuffer(srcX0 int32, srcY0 int32, srcX1 int32, srcY1 int32, dstX0 int32, dstY0 int32, dstX1 int32, dstY1 int32, mask uint32, filter uint32) {
-	panic("Not supported!") // TODO
-	// gl.BlitFrameBuffer(srcX0 int32, srcY0 int32, srcX1 int32, srcY1 int32, dstX0 int32, dstY0 int32, dstX1 int32, dstY1 int32, mask uint32, filter uint32)
+	c.Call("blitFramebuffer", srcX0, srcY0, srcX1, srcY1, dstX0, dstY0, dstX1, dstY1, mask, filter)
 }
 
 // func PtrOffset(offset int) unsafe.Pointer {
@@ -457,6 +482,14 @@ func BlitFramebuffer(srcX0 int32, srcY0 int32, srcX1 int32, srcY1 int32, dstX0 i
 // 	//	gl.DrawBuffer(uint32(target))
 // }
 
+func DrawBuffers(targets []Enum) {
+	bufs := make([]any, len(targets))
+	for i := range targets {
+		bufs[i] = int(targets[i])
+	}
+	c.Call("drawBuffers", js.ValueOf(bufs))
+}
+
 func ReadBuffer(target Enum) {
 	c.Call("readBuffer", int(target))
 	//	gl.ReadBuffer(uint32(target))
@@ -506,7 +539,7 @@ func BlendEquation(mode Enum) {
 }
 
 func BlendEquationSeparate(modeRGB, modeAlpha Enum) {
-	c.Call("blendEquationSeparate", modeRGB, modeAlpha)
+	c.Call("blendEquationSeparate", int(modeRGB), int(modeAlpha))
 }
 
 func BlendFunc(sfactor, dfactor Enum) {
@@ -535,6 +568,14 @@ func BufferSubDataUint32(target Enum, offset int, data []uint32) {
 	fnBufferSubData.Invoke(int(target), offset, array, 0, length)
 }
 
+// Note: Webgl2 only
+func GetBufferSubDataByte(target Enum, offset int, dst []byte) {
+	resizeJavascriptCopyBuffer(len(dst))
+	subarray := jsMemory.Call("subarray", 0, len(dst))
+	c.Call("getBufferSubData", int(target), offset, subarray)
+	js.CopyBytesToGo(dst, subarray)
+}
+
 // Note: I removed this because it requires me to do interface-based type switches which causes allocs
 // func BufferSubData(target Enum, offset int, data any) {
 // 	array, length := SliceToTypedArray(data)
@@ -578,13 +619,13 @@ func ClearColor(red, green, blue, alpha float32) {
 // 	c.Call("clearDepth", d)
 // }
 
-// func ClearStencil(s int) {
-// 	c.Call("clearStencil", s)
-// }
+func ClearStencil(s int) {
+	c.Call("clearStencil", s)
+}
 
-// func ColorMask(red, green, blue, alpha bool) {
-// 	c.Call("colorMask", red, green, blue, alpha)
-// }
+func ColorMask(red, green, blue, alpha bool) {
+	c.Call("colorMask", red, green, blue, alpha)
+}
 
 func CompileShader(s Shader) {
 	c.Call("compileShader", s.Value)
@@ -625,9 +666,9 @@ func CreateProgram() Program {
 	// return Program{Value: c.Call("createProgram")}
 }
 
-// func CreateRenderbuffer() Renderbuffer {
-// 	return Renderbuffer{Value: c.Call("createRenderbuffer")}
-// }
+func CreateRenderbuffer() Renderbuffer {
+	return Renderbuffer{Value: c.Call("createRenderbuffer")}
+}
 
 func CreateShader(ty Enum) Shader {
 	return Shader{Value: c.Call("createShader", int(ty))}
@@ -661,9 +702,9 @@ func DeleteProgram(p Program) {
 	c.Call("deleteProgram", p.Value)
 }
 
-// func DeleteRenderbuffer(v Renderbuffer) {
-// 	c.Call("deleteRenderbuffer", v.Value)
-// }
+func DeleteRenderbuffer(v Renderbuffer) {
+	c.Call("deleteRenderbuffer", v.Value)
+}
 
 func DeleteShader(s Shader) {
 	fnDeleteShader.Invoke(s.Value)
@@ -710,6 +751,11 @@ func DrawElements(mode Enum, count int, ty Enum, offset int) {
 	// c.Call("drawElements", int(mode), count, int(ty), offset)
 }
 
+// Note: WebGL2 only
+func DrawElementsInstanced(mode Enum, count int, ty Enum, offset int, instanceCount int) {
+	fnDrawElementsInstanced.Invoke(int(mode), count, int(ty), offset, instanceCount)
+}
+
 func Enable(cap Enum) {
 	fnEnable.Invoke(int(cap))
 	// c.Call("enable", int(cap))
@@ -728,9 +774,9 @@ func Flush() {
 	fnFlush.Invoke()
 }
 
-// func FramebufferRenderbuffer(target, attachment, rbTarget Enum, rb Renderbuffer) {
-// 	c.Call("framebufferRenderbuffer", target, attachment, int(rbTarget), rb.Value)
-// }
+func FramebufferRenderbuffer(target, attachment, rbTarget Enum, rb Renderbuffer) {
+	c.Call("framebufferRenderbuffer", int(target), int(attachment), int(rbTarget), rb.Value)
+}
 
 func FramebufferTexture2D(target, attachment, texTarget Enum, t Texture, level int) {
 	fnFramebufferTexture2D.Invoke(int(target), int(attachment), int(texTarget), t.Value, level)
@@ -741,15 +787,15 @@ func GenerateMipmap(target Enum) {
 	fnGenerateMipmap.Invoke(int(target))
 }
 
-// func GetActiveAttrib(p Program, index uint32) (name string, size int, ty Enum) {
-// 	ai := c.Call("getActiveAttrib", p.Value, index)
-// 	return ai.Get("name").String(), ai.Get("size").Int(), Enum(ai.Get("type").Int())
-// }
+func GetActiveAttrib(p Program, index uint32) (name string, size int, ty Enum) {
+	ai := c.Call("getActiveAttrib", p.Value, index)
+	return ai.Get("name").String(), ai.Get("size").Int(), Enum(ai.Get("type").Int())
+}
 
-// func GetActiveUniform(p Program, index uint32) (name string, size int, ty Enum) {
-// 	ai := c.Call("getActiveUniform", p.Value, index)
-// 	return ai.Get("name").String(), ai.Get("size").Int(), Enum(ai.Get("type").Int())
-// }
+func GetActiveUniform(p Program, index uint32) (name string, size int, ty Enum) {
+	ai := c.Call("getActiveUniform", p.Value, index)
+	return ai.Get("name").String(), ai.Get("size").Int(), Enum(ai.Get("type").Int())
+}
 
 // func GetAttachedShaders(p Program) []Shader {
 // 	objs := c.Call("getAttachedShaders", p.Value)
@@ -879,6 +925,22 @@ func GetShaderSource(s Shader) string {
 	return c.Call("getShaderSource", s.Value).String()
 }
 
+// InstancingSupported returns true if instances can be drawn, which WebGL1 can only do if the
+// ANGLE_instanced_arrays extension is available.
+func InstancingSupported() bool {
+	return instancing
+}
+
+// MaxAnisotropy returns the maximum anisotropic filtering level, or 0 if the
+// EXT_texture_filter_anisotropic extension isn't available.
+func MaxAnisotropy() float32 {
+	ext := c.Call("getExtension", "EXT_texture_filter_anisotropic")
+	if ext.IsNull() {
+		return 0
+	}
+	return float32(c.Call("getParameter", MAX_TEXTURE_MAX_ANISOTROPY).Float())
+}
+
 func GetString(pname Enum) string {
 	// return fnGetParameter.Invoke(int(pname)).String()
 	return c.Call("getParameter", int(pname)).String()
@@ -982,66 +1044,69 @@ func LinkProgram(p Program) {
 	// c.Call("linkProgram", p.Value)
 }
 
-// func PixelStorei(pname Enum, param int32) {
-// 	c.Call("pixelStorei", int(pname), param)
-// }
+func PixelStorei(pname Enum, param int32) {
+	c.Call("pixelStorei", int(pname), param)
+}
 
 // func PolygonOffset(factor, units float32) {
 // 	c.Call("polygonOffset", factor, units)
 // }
 
-// func ReadPixels(dst []byte, x, y, width, height int, format, ty Enum) {
-// 	println("ReadPixels: not yet tested (TODO: remove this after it's confirmed to work. Your feedback is welcome.)")
-// 	if ty == Enum(UNSIGNED_BYTE) {
-// 		c.Call("readPixels", x, y, width, height, format, int(ty), dst)
-// 	} else {
-// 		tmpDst := make([]float32, len(dst)/4)
-// 		c.Call("readPixels", x, y, width, height, format, int(ty), tmpDst)
-// 		for i, f := range tmpDst {
-// 			binary.LittleEndian.PutUint32(dst[i*4:], math.Float32bits(f))
-// 		}
-// 	}
-// }
+func ReadPixels(dst []byte, x, y, width, height int, format, ty Enum) {
+	resizeJavascriptCopyBuffer(len(dst))
+	subarray := jsMemory.Call("subarray", 0, len(dst))
+	c.Call("readPixels", x, y, width, height, int(format), int(ty), subarray)
+	js.CopyBytesToGo(dst, subarray)
+}
+
+// Note: Webgl2 only
+func ReadPixelsOffset(offset int, x, y, width, height int, format, ty Enum) {
+	c.Call("readPixels", x, y, width, height, int(format), int(ty), offset)
+}
 
 func ReleaseShaderCompiler() {
 	// do nothing
 }
 
-// func RenderbufferStorage(target, internalFormat Enum, width, height int) {
-// 	c.Call("renderbufferStorage", target, internalFormat, width, height)
-// }
+func RenderbufferStorage(target, internalFormat Enum, width, height int) {
+	c.Call("renderbufferStorage", int(target), int(internalFormat), width, height)
+}
+
+func RenderbufferStorageMultisample(target Enum, samples int, internalFormat Enum, width, height int) {
+	c.Call("renderbufferStorageMultisample", int(target), samples, int(internalFormat), width, height)
+}
 
 // func SampleCoverage(value float32, invert bool) {
 // 	c.Call("sampleCoverage", value, invert)
 // }
 
-// func Scissor(x, y, width, height int32) {
-// 	c.Call("scissor", x, y, width, height)
-// }
+func Scissor(x, y, width, height int32) {
+	c.Call("scissor", x, y, width, height)
+}
 
 func ShaderSource(s Shader, src string) {
 	c.Call("shaderSource", s.Value, src)
 }
 
-// func StencilFunc(fn Enum, ref int, mask uint32) {
-// 	c.Call("stencilFunc", fn, ref, mask)
-// }
+func StencilFunc(fn Enum, ref int, mask uint32) {
+	c.Call("stencilFunc", int(fn), ref, mask)
+}
 
 // func StencilFuncSeparate(face, fn Enum, ref int, mask uint32) {
 // 	c.Call("stencilFuncSeparate", face, fn, ref, mask)
 // }
 
-// func StencilMask(mask uint32) {
-// 	c.Call("stencilMask", mask)
-// }
+func StencilMask(mask uint32) {
+	c.Call("stencilMask", mask)
+}
 
 // func StencilMaskSeparate(face Enum, mask uint32) {
 // 	c.Call("stencilMaskSeparate", face, mask)
 // }
 
-// func StencilOp(fail, zfail, zpass Enum) {
-// 	c.Call("stencilOp", fail, zfail, zpass)
-// }
+func StencilOp(fail, zfail, zpass Enum) {
+	c.Call("stencilOp", int(fail), int(zfail), int(zpass))
+}
 
 // func StencilOpSeparate(face, sfail, dpfail, dppass Enum) {
 // 	c.Call("stencilOpSeparate", face, sfail, dpfail, dppass)
@@ -1061,7 +1126,7 @@ func ShaderSource(s Shader, src string) {
 func TexImage2DFull(target Enum, level int, format1 Enum, width, height int, format Enum, ty Enum, data []byte) {
 	array, length := byteSliceToTypedArray(data)
 	if !array.IsNull() {
-		subarray := array.Call("subarray", 0, length)
+		subarray := pixelSubarray(length, ty)
 		fnTexImage2D.Invoke(int(target), level, int(format1), width, height, 0, int(format), int(ty), subarray)
 	} else {
 		fnTexImage2D.Invoke(int(target), level, int(format1), width, height, 0, int(format), int(ty), nil)
@@ -1071,7 +1136,7 @@ func TexImage2DFull(target Enum, level int, format1 Enum, width, height int, for
 func TexSubImage2D(target Enum, level int, x, y, width, height int, format, ty Enum, data []byte) {
 	array, length := byteSliceToTypedArray(data)
 	if !array.IsNull() {
-		subarray := array.Call("subarray", 0, length)
+		subarray := pixelSubarray(length, ty)
 		fnTexSubImage2D.Invoke(int(target), level, x, y, width, height, int(format), int(ty), subarray)
 	} else {
 		// TODO: is this the correct behavior?
@@ -1081,9 +1146,9 @@ func TexSubImage2D(target Enum, level int, x, y, width, height int, format, ty E
 	// c.Call("texSubImage2D", int(target), level, x, y, width, height, format, int(ty), subarray)
 }
 
-// func TexParameterf(target, pname Enum, param float32) {
-// 	c.Call("texParameterf", int(target), int(pname), param)
-// }
+func TexParameterf(target, pname Enum, param float32) {
+	c.Call("texParameterf", int(target), int(pname), param)
+}
 
 // func TexParameterfv(target, pname Enum, params []float32) {
 // 	println("TexParameterfv: not yet tested (TODO: remove this after it's confirmed to work. Your feedback is welcome.)")
@@ -1113,9 +1178,9 @@ func Uniform1fv(dst Uniform, src []float32) {
 	fnUniform1fv.Invoke(dst.Value, subarray)
 }
 
-// func Uniform1i(dst Uniform, v int) {
-// 	c.Call("uniform1i", dst.Value, v)
-// }
+func Uniform1i(dst Uniform, v int) {
+	fnUniform1i.Invoke(dst.Value, v)
+}
 
 // func Uniform1iv(dst Uniform, src []int32) {
 // 	c.Call("uniform1iv", dst.Value, src)
@@ -1247,6 +1312,11 @@ func VertexAttribPointer(dst Attrib, size int, ty Enum, normalized bool, stride,
 	// c.Call("vertexAttribPointer", dst.Value, size, int(ty), normalized, stride, offset)
 }
 
+// Note: WebGL2 only
+func VertexAttribDivisor(index Attrib, divisor int) {
+	fnVertexAttribDivisor.Invoke(index.Value, divisor)
+}
+
 // func VertexAttribIPointer(dst Attrib, size int, ty Enum, stride, offset int) {
 // 	c.Call("vertexAttribIPointer", dst.Value, size, int(ty), stride, offset)
 // }
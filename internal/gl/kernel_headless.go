@@ -0,0 +1,316 @@
+//go:build !js && headless
+// +build !js,headless
+
+package gl
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The software rasterizer can't run GLSL, so every shader that gets linked needs a Go
+// equivalent registered against its source. Kernels are looked up by a normalized form of
+// the source (comments, preprocessor lines, precision statements and whitespace removed)
+// so that version or precision rewrites of the same shader still find their kernel.
+
+// Varying describes one output of a vertex kernel (or input of a fragment kernel)
+type Varying struct {
+	Name string
+	Size int // Number of float32 components
+}
+
+// VertexKernel is a Go implementation of a GLSL vertex shader.
+// Main returns gl_Position and writes the outputs to out, packed in the order of Varyings.
+type VertexKernel struct {
+	Varyings []Varying
+	Main     func(s *KernelState, out []float32) [4]float32
+}
+
+// FragmentKernel is a Go implementation of a GLSL fragment shader.
+// Main receives the interpolated inputs, packed in the order of Varyings, and returns the
+// fragment color. Returning false discards the fragment.
+//
+// Shaders with more than one output (for multiple draw buffers) set Outputs and implement
+// MainOutputs instead, which writes the color of each output location to out.
+type FragmentKernel struct {
+	Varyings    []Varying
+	Main        func(s *KernelState, in []float32) ([4]float32, bool)
+	Outputs     int
+	MainOutputs func(s *KernelState, in []float32, out [][4]float32) bool
+}
+
+func (k *FragmentKernel) shade(s *KernelState, in []float32, out [][4]float32) bool {
+	if k.MainOutputs != nil {
+		return k.MainOutputs(s, in, out)
+	}
+	color, keep := k.Main(s, in)
+	out[0] = color
+	return keep
+}
+
+var vertexKernels = make(map[string]*VertexKernel)
+var fragmentKernels = make(map[string]*FragmentKernel)
+
+// RegisterVertexKernel registers the Go implementation of the vertex shader with source src
+func RegisterVertexKernel(src string, kernel *VertexKernel) {
+	vertexKernels[kernelKey(src)] = kernel
+}
+
+// RegisterFragmentKernel registers the Go implementation of the fragment shader with source src
+func RegisterFragmentKernel(src string, kernel *FragmentKernel) {
+	fragmentKernels[kernelKey(src)] = kernel
+}
+
+var precisionRegexp = regexp.MustCompile(`precision\s+\w+\s+\w+\s*;`)
+
+func kernelKey(src string) string {
+	src = stripComments(src)
+	var b strings.Builder
+	for _, line := range strings.Split(src, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue // Skip: preprocessor directive
+		}
+		line = precisionRegexp.ReplaceAllString(line, "")
+		for _, field := range strings.Fields(line) {
+			b.WriteString(field)
+		}
+	}
+	return b.String()
+}
+
+func stripComments(src string) string {
+	var b strings.Builder
+	for i := 0; i < len(src); i++ {
+		if strings.HasPrefix(src[i:], "//") {
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				break
+			}
+			i += end - 1
+			continue
+		}
+		if strings.HasPrefix(src[i:], "/*") {
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				break
+			}
+			// Keep line numbering intact
+			b.WriteString(strings.Repeat("\n", strings.Count(src[i:i+2+end], "\n")))
+			i += 2 + end + 1
+			continue
+		}
+		b.WriteByte(src[i])
+	}
+	return b.String()
+}
+
+// KernelState is what a kernel invocation can read: the vertex attributes of the current
+// vertex, the uniforms of the program and the textures bound to its samplers.
+type KernelState struct {
+	program *program
+	attribs [maxVertexAttribs][4]float32
+}
+
+// Attrib returns the value of the named vertex attribute for the current vertex
+func (s *KernelState) Attrib(name string) [4]float32 {
+	loc, ok := s.program.attribLocs[name]
+	if !ok {
+		return [4]float32{0, 0, 0, 1}
+	}
+	return s.attribs[loc]
+}
+
+// Mat4Attrib returns the named mat4 vertex attribute, which takes up four consecutive
+// locations (one per column)
+func (s *KernelState) Mat4Attrib(name string) [16]float32 {
+	var m [16]float32
+	loc, ok := s.program.attribLocs[name]
+	if !ok {
+		return m
+	}
+	for col := 0; col < 4 && loc+col < maxVertexAttribs; col++ {
+		copy(m[col*4:col*4+4], s.attribs[loc+col][:])
+	}
+	return m
+}
+
+// Uniform returns the current value of the named uniform
+func (s *KernelState) Uniform(name string) []float32 {
+	idx, ok := s.program.uniformIndex[name]
+	if !ok {
+		return nil
+	}
+	return s.program.uniforms[idx].value
+}
+
+// Vec3 returns the named uniform as a vec3
+func (s *KernelState) Vec3(name string) [3]float32 {
+	var v [3]float32
+	copy(v[:], s.Uniform(name))
+	return v
+}
+
+// Vec4 returns the named uniform as a vec4
+func (s *KernelState) Vec4(name string) [4]float32 {
+	var v [4]float32
+	copy(v[:], s.Uniform(name))
+	return v
+}
+
+// Mat4 returns the named uniform as a column major mat4
+func (s *KernelState) Mat4(name string) [16]float32 {
+	var m [16]float32
+	copy(m[:], s.Uniform(name))
+	return m
+}
+
+// Texture samples the texture bound to the texture unit of the named sampler uniform
+func (s *KernelState) Texture(sampler string, u, v float32) [4]float32 {
+	unit := 0
+	if val := s.Uniform(sampler); len(val) > 0 {
+		unit = int(val[0])
+	}
+	if unit < 0 || unit >= len(ctx.textureUnits) || ctx.textureUnits[unit] == nil {
+		return [4]float32{0, 0, 0, 1}
+	}
+	return ctx.textureUnits[unit].sample(u, v)
+}
+
+// --------------------------------------------------------------------------------
+// Declaration scanning: The kernels replace the shader bodies, but the attributes and
+// uniforms still come from the GLSL declarations so locations and reflection behave.
+
+type declaration struct {
+	name     string
+	ty       Enum
+	size     int // Array length
+	location int // Explicit layout location, or -1
+}
+
+var glslTypes = map[string]struct {
+	ty         Enum
+	components int
+}{
+	"float":       {FLOAT, 1},
+	"vec2":        {FLOAT_VEC2, 2},
+	"vec3":        {FLOAT_VEC3, 3},
+	"vec4":        {FLOAT_VEC4, 4},
+	"int":         {INT, 1},
+	"ivec2":       {INT_VEC2, 2},
+	"ivec3":       {INT_VEC3, 3},
+	"ivec4":       {INT_VEC4, 4},
+	"bool":        {BOOL, 1},
+	"bvec2":       {BOOL_VEC2, 2},
+	"bvec3":       {BOOL_VEC3, 3},
+	"bvec4":       {BOOL_VEC4, 4},
+	"mat2":        {FLOAT_MAT2, 4},
+	"mat3":        {FLOAT_MAT3, 9},
+	"mat4":        {FLOAT_MAT4, 16},
+	"sampler2D":   {SAMPLER_2D, 1},
+	"samplerCube": {SAMPLER_CUBE, 1},
+}
+
+func typeComponents(ty Enum) int {
+	for _, t := range glslTypes {
+		if t.ty == ty {
+			return t.components
+		}
+	}
+	return 4
+}
+
+var (
+	structRegexp  = regexp.MustCompile(`struct\s+(\w+)\s*\{([^}]*)\}\s*;`)
+	memberRegexp  = regexp.MustCompile(`(?:(?:lowp|mediump|highp)\s+)?(\w+)\s+(\w+)\s*(?:\[\s*(\d+)\s*\])?\s*;`)
+	inputRegexp   = regexp.MustCompile(`(?m)^\s*(?:layout\s*\(\s*location\s*=\s*(\d+)\s*\)\s*)?(?:in|attribute)\s+(?:(?:lowp|mediump|highp)\s+)?(\w+)\s+(\w+)\s*(?:\[\s*(\d+)\s*\])?\s*;`)
+	uniformRegexp = regexp.MustCompile(`(?m)^\s*(?:layout\s*\([^)]*\)\s*)?uniform\s+(?:(?:lowp|mediump|highp)\s+)?(\w+)\s+(\w+)\s*(?:\[\s*(\d+)\s*\])?\s*;`)
+)
+
+// slots returns the number of attribute locations that the declaration takes up.
+// Matrices take one location per column.
+func (d declaration) slots() int {
+	switch d.ty {
+	case FLOAT_MAT2:
+		return 2 * d.size
+	case FLOAT_MAT3:
+		return 3 * d.size
+	case FLOAT_MAT4:
+		return 4 * d.size
+	}
+	return d.size
+}
+
+func freeSlots(used map[int]bool, start, n int) bool {
+	for i := start; i < start+n; i++ {
+		if used[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func arraySize(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// scanInputs returns the vertex attributes declared in a vertex shader
+func scanInputs(src string) []declaration {
+	src = stripComments(src)
+	ret := make([]declaration, 0)
+	for _, m := range inputRegexp.FindAllStringSubmatch(src, -1) {
+		t, ok := glslTypes[m[2]]
+		if !ok {
+			continue
+		}
+		loc := -1
+		if m[1] != "" {
+			loc, _ = strconv.Atoi(m[1])
+		}
+		ret = append(ret, declaration{m[3], t.ty, arraySize(m[4]), loc})
+	}
+	return ret
+}
+
+// scanUniforms returns the uniforms declared in a shader, with struct uniforms flattened
+// into one declaration per member (eg "material.ambient")
+func scanUniforms(src string) []declaration {
+	src = stripComments(src)
+
+	structs := make(map[string][]declaration)
+	for _, m := range structRegexp.FindAllStringSubmatch(src, -1) {
+		members := make([]declaration, 0)
+		for _, mm := range memberRegexp.FindAllStringSubmatch(m[2], -1) {
+			t, ok := glslTypes[mm[1]]
+			if !ok {
+				continue
+			}
+			members = append(members, declaration{mm[2], t.ty, arraySize(mm[3]), -1})
+		}
+		structs[m[1]] = members
+	}
+
+	ret := make([]declaration, 0)
+	for _, m := range uniformRegexp.FindAllStringSubmatch(src, -1) {
+		if members, ok := structs[m[1]]; ok {
+			for _, member := range members {
+				member.name = m[2] + "." + member.name
+				ret = append(ret, member)
+			}
+			continue
+		}
+		t, ok := glslTypes[m[1]]
+		if !ok {
+			continue
+		}
+		ret = append(ret, declaration{m[2], t.ty, arraySize(m[3]), -1})
+	}
+	return ret
+}
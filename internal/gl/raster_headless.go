@@ -0,0 +1,869 @@
+//go:build !js && headless
+// +build !js,headless
+
+package gl
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// --------------------------------------------------------------------------------
+// Surfaces: The storage behind textures, renderbuffers and the default framebuffer.
+// Every texel is stored as four float32s. Normalized fixed point formats are quantized on
+// write so that blending behaves like it would on an 8 bit target.
+
+type surface struct {
+	width, height int
+	format        Enum // Internal format
+	channels      int  // Number of color channels stored (the rest read back as 0, 0, 0, 1)
+	bits          int  // Bits per channel for normalized formats, 0 for float formats
+	depth         bool // Component 0 is depth, component 1 is stencil
+	pix           []float32
+
+	minFilter, magFilter Enum
+	wrapS, wrapT         Enum
+	anisotropy           float32
+}
+
+func newSurface() *surface {
+	return &surface{
+		format:     RGBA,
+		channels:   4,
+		bits:       8,
+		minFilter:  NEAREST_MIPMAP_LINEAR,
+		magFilter:  LINEAR,
+		wrapS:      REPEAT,
+		wrapT:      REPEAT,
+		anisotropy: 1,
+	}
+}
+
+type surfaceFormat struct {
+	channels int
+	bits     int
+	depth    bool
+}
+
+var surfaceFormats = map[Enum]surfaceFormat{
+	RGBA:               {4, 8, false},
+	RGB:                {3, 8, false},
+	RED:                {1, 8, false},
+	ALPHA:              {4, 8, false},
+	LUMINANCE:          {3, 8, false},
+	LUMINANCE_ALPHA:    {4, 8, false},
+	RGBA4:              {4, 4, false},
+	RGB5_A1:            {4, 5, false},
+	RGB565:             {3, 6, false},
+	RGBA8:              {4, 8, false},
+	RG8:                {2, 8, false},
+	R8:                 {1, 8, false},
+	RGBA16F:            {4, 0, false},
+	RG16F:              {2, 0, false},
+	R16F:               {1, 0, false},
+	RGBA32F:            {4, 0, false},
+	RG32F:              {2, 0, false},
+	R32F:               {1, 0, false},
+	DEPTH_COMPONENT:    {1, 0, true},
+	DEPTH_COMPONENT16:  {1, 0, true},
+	DEPTH_COMPONENT24:  {1, 0, true},
+	DEPTH_COMPONENT32F: {1, 0, true},
+	STENCIL_INDEX8:     {1, 0, true},
+	DEPTH24_STENCIL8:   {2, 0, true},
+	DEPTH_STENCIL:      {2, 0, true},
+}
+
+// alloc (re)allocates the surface storage, which also clears it
+func (s *surface) alloc(format Enum, width, height int) bool {
+	f, ok := surfaceFormats[format]
+	if !ok {
+		return false
+	}
+	s.format = format
+	s.channels = f.channels
+	s.bits = f.bits
+	s.depth = f.depth
+	s.width = width
+	s.height = height
+	s.pix = make([]float32, 4*width*height)
+	if !s.depth {
+		for i := 3; i < len(s.pix); i += 4 {
+			s.pix[i] = 1
+		}
+	}
+	return true
+}
+
+func (s *surface) contains(x, y int) bool {
+	return x >= 0 && y >= 0 && x < s.width && y < s.height
+}
+
+func (s *surface) get(x, y int) [4]float32 {
+	i := 4 * (y*s.width + x)
+	return [4]float32{s.pix[i], s.pix[i+1], s.pix[i+2], s.pix[i+3]}
+}
+
+func (s *surface) quantize(v float32) float32 {
+	if s.bits == 0 {
+		return v
+	}
+	v = clamp01(v)
+	scale := float32(int(1)<<s.bits - 1)
+	return float32(math.Round(float64(v*scale))) / scale
+}
+
+// set writes the color channels of c that are enabled in mask
+func (s *surface) set(x, y int, c [4]float32, mask [4]bool) {
+	i := 4 * (y*s.width + x)
+	for n := 0; n < 4; n++ {
+		if !mask[n] {
+			continue
+		}
+		if n >= s.channels {
+			continue // Skip: channel isn't stored
+		}
+		s.pix[i+n] = s.quantize(c[n])
+	}
+}
+
+func (s *surface) setDepth(x, y int, d float32) {
+	s.pix[4*(y*s.width+x)] = clamp01(d)
+}
+
+func (s *surface) getStencil(x, y int) int {
+	return int(s.pix[4*(y*s.width+x)+1])
+}
+
+// writeStencil writes the bits of v that are set in mask. Stencil buffers are 8 bit
+func (s *surface) writeStencil(x, y int, v int, mask uint32) {
+	old := uint32(s.getStencil(x, y))
+	v2 := (old &^ mask) | (uint32(v) & mask)
+	s.pix[4*(y*s.width+x)+1] = float32(v2 & 0xFF)
+}
+
+func (s *surface) fill(c [4]float32, mask [4]bool, box [4]int) {
+	x0, y0, x1, y1 := s.clip(box)
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			s.set(x, y, c, mask)
+		}
+	}
+}
+
+// clip intersects a box (x, y, w, h) with the surface bounds
+func (s *surface) clip(box [4]int) (int, int, int, int) {
+	x0 := max(box[0], 0)
+	y0 := max(box[1], 0)
+	x1 := min(box[0]+box[2], s.width)
+	y1 := min(box[1]+box[3], s.height)
+	return x0, y0, x1, y1
+}
+
+func wrapCoord(i, size int, mode Enum) int {
+	switch mode {
+	case REPEAT:
+		i %= size
+		if i < 0 {
+			i += size
+		}
+		return i
+	case MIRRORED_REPEAT:
+		period := 2 * size
+		i %= period
+		if i < 0 {
+			i += period
+		}
+		if i >= size {
+			i = period - 1 - i
+		}
+		return i
+	default: // CLAMP_TO_EDGE
+		return min(max(i, 0), size-1)
+	}
+}
+
+func (s *surface) texel(x, y int) [4]float32 {
+	x = wrapCoord(x, s.width, s.wrapS)
+	y = wrapCoord(y, s.height, s.wrapT)
+	return s.get(x, y)
+}
+
+// sample reads the surface like texture() would in a shader.
+// Note: No derivatives are computed, so the magnification filter is always used and
+// mipmapped minification filters sample the base level.
+func (s *surface) sample(u, v float32) [4]float32 {
+	if s.width == 0 || s.height == 0 {
+		return [4]float32{0, 0, 0, 1}
+	}
+
+	x := float64(u) * float64(s.width)
+	y := float64(v) * float64(s.height)
+	if s.magFilter == NEAREST {
+		return s.texel(int(math.Floor(x)), int(math.Floor(y)))
+	}
+
+	x -= 0.5
+	y -= 0.5
+	x0 := math.Floor(x)
+	y0 := math.Floor(y)
+	fx := float32(x - x0)
+	fy := float32(y - y0)
+	ix, iy := int(x0), int(y0)
+
+	a := s.texel(ix, iy)
+	b := s.texel(ix+1, iy)
+	c := s.texel(ix, iy+1)
+	d := s.texel(ix+1, iy+1)
+
+	var ret [4]float32
+	for i := range ret {
+		top := a[i] + (b[i]-a[i])*fx
+		bot := c[i] + (d[i]-c[i])*fx
+		ret[i] = top + (bot-top)*fy
+	}
+	return ret
+}
+
+// --------------------------------------------------------------------------------
+// Pixel transfer
+
+func formatComponents(format Enum) int {
+	switch format {
+	case RGBA:
+		return 4
+	case RGB:
+		return 3
+	case LUMINANCE_ALPHA, RG:
+		return 2
+	default: // RED, ALPHA, LUMINANCE, DEPTH_COMPONENT
+		return 1
+	}
+}
+
+func typeSize(ty Enum) int {
+	switch ty {
+	case UNSIGNED_BYTE, BYTE:
+		return 1
+	case UNSIGNED_SHORT, SHORT:
+		return 2
+	default: // FLOAT, INT, UNSIGNED_INT
+		return 4
+	}
+}
+
+func decodeComponent(data []byte, ty Enum) float32 {
+	switch ty {
+	case UNSIGNED_BYTE:
+		return float32(data[0]) / 255
+	case UNSIGNED_SHORT:
+		return float32(binary.NativeEndian.Uint16(data)) / math.MaxUint16
+	case UNSIGNED_INT:
+		return float32(float64(binary.NativeEndian.Uint32(data)) / math.MaxUint32)
+	case FLOAT:
+		return math.Float32frombits(binary.NativeEndian.Uint32(data))
+	}
+	return 0
+}
+
+func encodeComponent(dst []byte, v float32, ty Enum) {
+	switch ty {
+	case UNSIGNED_BYTE:
+		dst[0] = uint8(math.Round(float64(clamp01(v)) * 255))
+	case UNSIGNED_SHORT:
+		binary.NativeEndian.PutUint16(dst, uint16(math.Round(float64(clamp01(v))*math.MaxUint16)))
+	case UNSIGNED_INT:
+		binary.NativeEndian.PutUint32(dst, uint32(math.Round(float64(clamp01(v))*math.MaxUint32)))
+	case FLOAT:
+		binary.NativeEndian.PutUint32(dst, math.Float32bits(v))
+	}
+}
+
+// decodePixel converts one pixel of client data into an RGBA (or depth) value
+func decodePixel(data []byte, format, ty Enum) [4]float32 {
+	size := typeSize(ty)
+	c := func(i int) float32 { return decodeComponent(data[i*size:], ty) }
+	switch format {
+	case RGBA:
+		return [4]float32{c(0), c(1), c(2), c(3)}
+	case RGB:
+		return [4]float32{c(0), c(1), c(2), 1}
+	case RG:
+		return [4]float32{c(0), c(1), 0, 1}
+	case RED, DEPTH_COMPONENT:
+		return [4]float32{c(0), 0, 0, 1}
+	case ALPHA:
+		return [4]float32{0, 0, 0, c(0)}
+	case LUMINANCE:
+		return [4]float32{c(0), c(0), c(0), 1}
+	case LUMINANCE_ALPHA:
+		return [4]float32{c(0), c(0), c(0), c(1)}
+	}
+	return [4]float32{0, 0, 0, 1}
+}
+
+func encodePixel(dst []byte, v [4]float32, format, ty Enum) {
+	size := typeSize(ty)
+	switch format {
+	case RGBA, RGB, RG:
+		for i := 0; i < formatComponents(format); i++ {
+			encodeComponent(dst[i*size:], v[i], ty)
+		}
+	case ALPHA:
+		encodeComponent(dst, v[3], ty)
+	default: // RED, DEPTH_COMPONENT
+		encodeComponent(dst, v[0], ty)
+	}
+}
+
+// rowStride returns the number of bytes between rows of client pixel data
+func rowStride(width, rowLength, alignment int, format, ty Enum) int {
+	if rowLength > 0 {
+		width = rowLength
+	}
+	stride := width * formatComponents(format) * typeSize(ty)
+	if alignment > 1 && stride%alignment != 0 {
+		stride += alignment - stride%alignment
+	}
+	return stride
+}
+
+// upload copies client pixel data into the x, y, width, height region of the surface
+func (s *surface) upload(x, y, width, height int, format, ty Enum, data []byte) {
+	if data == nil {
+		return
+	}
+	stride := rowStride(width, ctx.unpackRowLength, ctx.unpackAlignment, format, ty)
+	pixSize := formatComponents(format) * typeSize(ty)
+	all := [4]bool{true, true, true, true}
+	for j := 0; j < height; j++ {
+		for i := 0; i < width; i++ {
+			off := j*stride + i*pixSize
+			if off+pixSize > len(data) {
+				return
+			}
+			if !s.contains(x+i, y+j) {
+				continue
+			}
+			v := decodePixel(data[off:], format, ty)
+			if s.depth {
+				s.setDepth(x+i, y+j, v[0])
+			} else {
+				s.set(x+i, y+j, v, all)
+			}
+		}
+	}
+}
+
+// download copies the x, y, width, height region of the surface into client memory
+func (s *surface) download(dst []byte, x, y, width, height int, format, ty Enum) {
+	stride := rowStride(width, 0, ctx.packAlignment, format, ty)
+	pixSize := formatComponents(format) * typeSize(ty)
+	for j := 0; j < height; j++ {
+		for i := 0; i < width; i++ {
+			off := j*stride + i*pixSize
+			if off+pixSize > len(dst) {
+				return
+			}
+			if !s.contains(x+i, y+j) {
+				continue
+			}
+			encodePixel(dst[off:], s.get(x+i, y+j), format, ty)
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------
+// Fixed function math
+
+func clamp01(v float32) float32 {
+	return min(max(v, 0), 1)
+}
+
+func blendFactor(factor Enum, src, dst [4]float32, i int) float32 {
+	switch factor {
+	case ZERO:
+		return 0
+	case ONE:
+		return 1
+	case SRC_COLOR:
+		return src[i]
+	case ONE_MINUS_SRC_COLOR:
+		return 1 - src[i]
+	case DST_COLOR:
+		return dst[i]
+	case ONE_MINUS_DST_COLOR:
+		return 1 - dst[i]
+	case SRC_ALPHA:
+		return src[3]
+	case ONE_MINUS_SRC_ALPHA:
+		return 1 - src[3]
+	case DST_ALPHA:
+		return dst[3]
+	case ONE_MINUS_DST_ALPHA:
+		return 1 - dst[3]
+	case CONSTANT_COLOR:
+		return ctx.blendColor[i]
+	case ONE_MINUS_CONSTANT_COLOR:
+		return 1 - ctx.blendColor[i]
+	case CONSTANT_ALPHA:
+		return ctx.blendColor[3]
+	case ONE_MINUS_CONSTANT_ALPHA:
+		return 1 - ctx.blendColor[3]
+	case SRC_ALPHA_SATURATE:
+		if i == 3 {
+			return 1
+		}
+		return min(src[3], 1-dst[3])
+	}
+	return 0
+}
+
+func blendEquation(eq Enum, s, d float32) float32 {
+	switch eq {
+	case FUNC_SUBTRACT:
+		return s - d
+	case FUNC_REVERSE_SUBTRACT:
+		return d - s
+	case MIN:
+		return min(s, d)
+	case MAX:
+		return max(s, d)
+	}
+	return s + d
+}
+
+func blend(src, dst [4]float32) [4]float32 {
+	var ret [4]float32
+	for i := range ret {
+		srcFactor, dstFactor, eq := ctx.blendSrcRGB, ctx.blendDstRGB, ctx.blendEqRGB
+		if i == 3 {
+			srcFactor, dstFactor, eq = ctx.blendSrcAlpha, ctx.blendDstAlpha, ctx.blendEqAlpha
+		}
+		if eq == MIN || eq == MAX {
+			ret[i] = blendEquation(eq, src[i], dst[i]) // Note: MIN and MAX ignore the factors
+			continue
+		}
+		ret[i] = blendEquation(eq,
+			src[i]*blendFactor(srcFactor, src, dst, i),
+			dst[i]*blendFactor(dstFactor, src, dst, i))
+	}
+	return ret
+}
+
+func compare(fn Enum, incoming, stored float32) bool {
+	switch fn {
+	case NEVER:
+		return false
+	case LESS:
+		return incoming < stored
+	case EQUAL:
+		return incoming == stored
+	case LEQUAL:
+		return incoming <= stored
+	case GREATER:
+		return incoming > stored
+	case NOTEQUAL:
+		return incoming != stored
+	case GEQUAL:
+		return incoming >= stored
+	}
+	return true // ALWAYS
+}
+
+// stencilTest compares the face's reference value against the stencil buffer
+func (c *context) stencilTest(buf *surface, face, x, y int) bool {
+	mask := c.stencilMask[face]
+	ref := uint32(c.stencilRef[face]) & mask
+	stored := uint32(buf.getStencil(x, y)) & mask
+	return compare(c.stencilFunc[face], float32(ref), float32(stored))
+}
+
+// stencilUpdate applies a stencil op (ie one of the ops set by StencilOp) to the stencil buffer
+func (c *context) stencilUpdate(buf *surface, face, x, y int, op Enum) {
+	v := buf.getStencil(x, y)
+	switch op {
+	case KEEP:
+		return
+	case ZERO:
+		v = 0
+	case REPLACE:
+		v = c.stencilRef[face]
+	case INCR:
+		v = min(v+1, 0xFF)
+	case DECR:
+		v = max(v-1, 0)
+	case INVERT:
+		v = ^v
+	case INCR_WRAP:
+		v = (v + 1) & 0xFF
+	case DECR_WRAP:
+		v = (v - 1) & 0xFF
+	}
+	buf.writeStencil(x, y, v, c.stencilWrite[face])
+}
+
+// --------------------------------------------------------------------------------
+// Rasterization
+
+type processedVertex struct {
+	clip [4]float64
+	vary []float32
+}
+
+// screenVertex is a vertex after clipping and the viewport transform
+type screenVertex struct {
+	x, y, z float64
+	invW    float64
+	vary    []float32
+}
+
+func (c *context) drawElements(mode Enum, indices []int, instance int) {
+	p := c.program
+	if p == nil || !p.linked {
+		c.setError(INVALID_OPERATION)
+		return
+	}
+	fb := c.drawFramebuffer
+	targets := fb.drawTargets()
+
+	// Vertex stage. Each index only gets shaded once per draw.
+	state := &KernelState{program: p}
+	vao := c.vertexArray
+	numVary := varyingsSize(p.vertex.Varyings)
+	cache := make(map[int]*processedVertex)
+	vertex := func(index int) *processedVertex {
+		if v, ok := cache[index]; ok {
+			return v
+		}
+		for _, attr := range p.attribs {
+			for n := 0; n < attr.slots(); n++ {
+				state.attribs[attr.location+n] = vao.fetch(attr.location+n, index, instance)
+			}
+		}
+		v := &processedVertex{vary: make([]float32, numVary)}
+		pos := p.vertex.Main(state, v.vary)
+		v.clip = [4]float64{float64(pos[0]), float64(pos[1]), float64(pos[2]), float64(pos[3])}
+		cache[index] = v
+		return v
+	}
+
+	// Primitive assembly
+	tri := func(a, b, c2 int) {
+		c.rasterTriangle(state, fb, targets, vertex(a), vertex(b), vertex(c2))
+	}
+	switch mode {
+	case TRIANGLES:
+		for i := 0; i+2 < len(indices); i += 3 {
+			tri(indices[i], indices[i+1], indices[i+2])
+		}
+	case TRIANGLE_STRIP:
+		for i := 0; i+2 < len(indices); i++ {
+			if i%2 == 0 {
+				tri(indices[i], indices[i+1], indices[i+2])
+			} else {
+				tri(indices[i+1], indices[i], indices[i+2])
+			}
+		}
+	case TRIANGLE_FAN:
+		for i := 1; i+1 < len(indices); i++ {
+			tri(indices[0], indices[i], indices[i+1])
+		}
+	default:
+		// Note: Points and lines aren't rasterized
+	}
+}
+
+func varyingsSize(varyings []Varying) int {
+	size := 0
+	for _, v := range varyings {
+		size += v.Size
+	}
+	return size
+}
+
+// clipPolygon clips against the near and far planes (and w > 0). The x and y planes are
+// handled by restricting rasterization to the viewport.
+func clipPolygon(poly []*processedVertex) []*processedVertex {
+	planes := []func(v [4]float64) float64{
+		func(v [4]float64) float64 { return v[3] - 1e-6 },
+		func(v [4]float64) float64 { return v[3] + v[2] },
+		func(v [4]float64) float64 { return v[3] - v[2] },
+	}
+	for _, plane := range planes {
+		if len(poly) == 0 {
+			return poly
+		}
+		out := make([]*processedVertex, 0, len(poly)+1)
+		for i := range poly {
+			cur := poly[i]
+			next := poly[(i+1)%len(poly)]
+			dc := plane(cur.clip)
+			dn := plane(next.clip)
+			if dc >= 0 {
+				out = append(out, cur)
+			}
+			if (dc >= 0) != (dn >= 0) {
+				t := dc / (dc - dn)
+				v := &processedVertex{vary: make([]float32, len(cur.vary))}
+				for k := range v.clip {
+					v.clip[k] = cur.clip[k] + (next.clip[k]-cur.clip[k])*t
+				}
+				for k := range v.vary {
+					v.vary[k] = cur.vary[k] + (next.vary[k]-cur.vary[k])*float32(t)
+				}
+				out = append(out, v)
+			}
+		}
+		poly = out
+	}
+	return poly
+}
+
+func (c *context) toScreen(v *processedVertex) screenVertex {
+	invW := 1 / v.clip[3]
+	x := v.clip[0] * invW
+	y := v.clip[1] * invW
+	z := v.clip[2] * invW
+	vp := c.viewport
+	n, f := float64(c.depthRange[0]), float64(c.depthRange[1])
+	return screenVertex{
+		x:    float64(vp[0]) + (x+1)*float64(vp[2])/2,
+		y:    float64(vp[1]) + (y+1)*float64(vp[3])/2,
+		z:    n + (z+1)/2*(f-n),
+		invW: invW,
+		vary: v.vary,
+	}
+}
+
+func edge(a, b screenVertex, px, py float64) float64 {
+	return (b.x-a.x)*(py-a.y) - (b.y-a.y)*(px-a.x)
+}
+
+// ownsEdge is the tie breaker for pixels that land exactly on an edge, so that pixels on
+// an edge shared by two triangles are only drawn once.
+func ownsEdge(a, b screenVertex) bool {
+	dy := b.y - a.y
+	return dy > 0 || (dy == 0 && b.x < a.x)
+}
+
+func (c *context) rasterTriangle(state *KernelState, fb *framebuffer, targets []*surface, a, b, d *processedVertex) {
+	poly := clipPolygon([]*processedVertex{a, b, d})
+	if len(poly) < 3 {
+		return
+	}
+
+	verts := make([]screenVertex, len(poly))
+	for i := range poly {
+		verts[i] = c.toScreen(poly[i])
+	}
+
+	// Culling. Clipping doesn't change the winding so the first fan triangle decides.
+	area := edge(verts[0], verts[1], verts[2].x, verts[2].y)
+	if area == 0 {
+		return
+	}
+	if c.capabilities[CULL_FACE] {
+		front := (area > 0) == (c.frontFace == CCW)
+		switch c.cullFace {
+		case FRONT_AND_BACK:
+			return
+		case FRONT:
+			if front {
+				return
+			}
+		default: // BACK
+			if !front {
+				return
+			}
+		}
+	}
+
+	face := 0
+	if (area > 0) != (c.frontFace == CCW) {
+		face = 1 // Back face
+	}
+	for i := 1; i+1 < len(verts); i++ {
+		c.fillTriangle(state, fb, targets, face, verts[0], verts[i], verts[i+1])
+	}
+}
+
+func (c *context) fillTriangle(state *KernelState, fb *framebuffer, targets []*surface, face int, v0, v1, v2 screenVertex) {
+	area := edge(v0, v1, v2.x, v2.y)
+	if area == 0 {
+		return
+	}
+	if area < 0 {
+		v1, v2 = v2, v1
+		area = -area
+	}
+
+	// Bounding box, restricted to the viewport, scissor box and framebuffer
+	x0 := int(math.Floor(min(v0.x, v1.x, v2.x)))
+	y0 := int(math.Floor(min(v0.y, v1.y, v2.y)))
+	x1 := int(math.Ceil(max(v0.x, v1.x, v2.x)))
+	y1 := int(math.Ceil(max(v0.y, v1.y, v2.y)))
+	vp := c.viewport
+	x0, y0 = max(x0, vp[0]), max(y0, vp[1])
+	x1, y1 = min(x1, vp[0]+vp[2]), min(y1, vp[1]+vp[3])
+	if c.capabilities[SCISSOR_TEST] {
+		sb := c.scissorBox
+		x0, y0 = max(x0, sb[0]), max(y0, sb[1])
+		x1, y1 = min(x1, sb[0]+sb[2]), min(y1, sb[1]+sb[3])
+	}
+	w, h := fb.size()
+	x0, y0 = max(x0, 0), max(y0, 0)
+	x1, y1 = min(x1, w), min(y1, h)
+
+	p := c.program
+	fragVary := make([]float32, varyingsSize(p.fragment.Varyings))
+	depthBuf := fb.depthSurface()
+	depthTest := c.capabilities[DEPTH_TEST] && depthBuf != nil
+	stencilBuf := fb.stencilSurface()
+	stencilTest := c.capabilities[STENCIL_TEST] && stencilBuf != nil
+	blending := c.capabilities[BLEND]
+	outputs := make([][4]float32, max(1, p.fragment.Outputs))
+
+	own0, own1, own2 := ownsEdge(v1, v2), ownsEdge(v2, v0), ownsEdge(v0, v1)
+	for y := y0; y < y1; y++ {
+		py := float64(y) + 0.5
+		for x := x0; x < x1; x++ {
+			px := float64(x) + 0.5
+			w0 := edge(v1, v2, px, py)
+			w1 := edge(v2, v0, px, py)
+			w2 := edge(v0, v1, px, py)
+			if w0 < 0 || w1 < 0 || w2 < 0 {
+				continue
+			}
+			if (w0 == 0 && !own0) || (w1 == 0 && !own1) || (w2 == 0 && !own2) {
+				continue
+			}
+			l0, l1, l2 := w0/area, w1/area, w2/area
+
+			z := l0*v0.z + l1*v1.z + l2*v2.z
+			depthPass := !depthTest || compare(c.depthFunc, float32(z), depthBuf.get(x, y)[0])
+			if !depthPass && !stencilTest {
+				continue // Note: The stencil ops still run on a failed depth test, so only skip early without them
+			}
+
+			// Perspective correct interpolation
+			p0, p1, p2 := l0*v0.invW, l1*v1.invW, l2*v2.invW
+			sum := p0 + p1 + p2
+			p0, p1, p2 = p0/sum, p1/sum, p2/sum
+			for i, idx := range p.varyingMap {
+				fragVary[i] = float32(p0*float64(v0.vary[idx]) + p1*float64(v1.vary[idx]) + p2*float64(v2.vary[idx]))
+			}
+
+			keep := p.fragment.shade(state, fragVary, outputs)
+			if !keep {
+				continue
+			}
+
+			if stencilTest {
+				ops := c.stencilOp[face]
+				if !c.stencilTest(stencilBuf, face, x, y) {
+					c.stencilUpdate(stencilBuf, face, x, y, ops[0])
+					continue
+				}
+				if !depthPass {
+					c.stencilUpdate(stencilBuf, face, x, y, ops[1])
+					continue
+				}
+				c.stencilUpdate(stencilBuf, face, x, y, ops[2])
+			}
+
+			if depthTest && c.depthMask {
+				depthBuf.setDepth(x, y, float32(z))
+			}
+
+			for i, target := range targets {
+				if target == nil || i >= len(outputs) {
+					continue
+				}
+				color := outputs[i]
+				if target.bits > 0 {
+					for n := range color {
+						color[n] = clamp01(color[n])
+					}
+				}
+				if blending {
+					color = blend(color, target.get(x, y))
+				}
+				target.set(x, y, color, c.colorMask)
+			}
+		}
+	}
+}
+
+// blit copies a rectangle of the read framebuffer into the draw framebuffer
+func (c *context) blit(src, dst [4]int, mask Enum) {
+	read := c.readFramebuffer
+	draw := c.drawFramebuffer
+	type pair struct{ from, to *surface }
+	pairs := make([]pair, 0, 2)
+	if mask&COLOR_BUFFER_BIT != 0 {
+		for _, to := range draw.drawTargets() {
+			pairs = append(pairs, pair{read.readTarget(), to})
+		}
+	}
+	if mask&(DEPTH_BUFFER_BIT|STENCIL_BUFFER_BIT) != 0 {
+		pairs = append(pairs, pair{read.depthSurface(), draw.depthSurface()})
+	}
+
+	// Sample position along one axis. Flipped source or destination ranges mirror the copy.
+	srcCoord := func(i, d0, d1, s0, s1 int) int {
+		t := (float64(i) + 0.5) / math.Abs(float64(d1-d0))
+		if d1 < d0 {
+			t = 1 - t
+		}
+		return s0 + int(math.Floor(t*float64(s1-s0)))
+	}
+	dw := abs(dst[2] - dst[0])
+	dh := abs(dst[3] - dst[1])
+	if dw == 0 || dh == 0 {
+		return
+	}
+	all := [4]bool{true, true, true, true}
+	for _, pr := range pairs {
+		if pr.from == nil || pr.to == nil {
+			continue
+		}
+		// Read everything before writing in case the surfaces are the same
+		type write struct {
+			x, y int
+			v    [4]float32
+		}
+		writes := make([]write, 0)
+		for j := 0; j < dh; j++ {
+			for i := 0; i < dw; i++ {
+				dx := min(dst[0], dst[2]) + i
+				dy := min(dst[1], dst[3]) + j
+				sx := srcCoord(i, dst[0], dst[2], src[0], src[2])
+				sy := srcCoord(j, dst[1], dst[3], src[1], src[3])
+				if !pr.to.contains(dx, dy) || !pr.from.contains(sx, sy) {
+					continue
+				}
+				writes = append(writes, write{dx, dy, pr.from.get(sx, sy)})
+			}
+		}
+		for _, wr := range writes {
+			if pr.to.depth {
+				i := 4 * (wr.y*pr.to.width + wr.x)
+				if mask&DEPTH_BUFFER_BIT != 0 {
+					pr.to.pix[i] = wr.v[0]
+				}
+				if mask&STENCIL_BUFFER_BIT != 0 {
+					pr.to.pix[i+1] = wr.v[1]
+				}
+				continue
+			}
+			pr.to.set(wr.x, wr.y, wr.v, all)
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
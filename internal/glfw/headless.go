@@ -0,0 +1,434 @@
+//go:build !js && headless
+// +build !js,headless
+
+package glfw
+
+import (
+	"errors"
+	"image"
+)
+
+// The headless backend has no display, so no windows can be created. It only exists so that
+// the packages which use glfw build without cgo, and the input constants have their glfw values.
+
+// ErrHeadless is returned by CreateWindow in headless builds
+var ErrHeadless = errors.New("glfw: windows can't be created in headless builds")
+
+var contextWatcher ContextWatcher
+
+func Init(cw ContextWatcher) error {
+	contextWatcher = cw
+	return nil
+}
+
+func Terminate() {}
+
+func CreateWindow(width, height int, title string, monitor *Monitor, share *Window) (*Window, error) {
+	return nil, ErrHeadless
+}
+
+func SwapInterval(interval int) {}
+
+func DetachCurrentContext() {}
+
+func PollEvents() {}
+
+func WaitEvents() {}
+
+func WaitEventsTimeout(timeout float64) {}
+
+func PostEmptyEvent() {}
+
+func DefaultWindowHints() {}
+
+// Window is never created in headless builds, see CreateWindow
+type Window struct{}
+
+type Monitor struct{}
+
+func GetPrimaryMonitor() *Monitor {
+	return &Monitor{}
+}
+
+func GetMonitors() []*Monitor {
+	return []*Monitor{}
+}
+
+func (m *Monitor) GetVideoMode() *VidMode {
+	return &VidMode{}
+}
+
+func (m *Monitor) GetVideoModes() []*VidMode {
+	return []*VidMode{}
+}
+
+type CursorPosCallback func(w *Window, xpos float64, ypos float64)
+type MouseMovementCallback func(w *Window, xpos float64, ypos float64, xdelta float64, ydelta float64)
+type KeyCallback func(w *Window, key Key, scancode int, action Action, mods ModifierKey)
+type CharCallback func(w *Window, char rune)
+type ScrollCallback func(w *Window, xoff float64, yoff float64)
+type MouseButtonCallback func(w *Window, button MouseButton, action Action, mods ModifierKey)
+type FramebufferSizeCallback func(w *Window, width int, height int)
+type CloseCallback func(w *Window)
+type RefreshCallback func(w *Window)
+type SizeCallback func(w *Window, width int, height int)
+type CursorEnterCallback func(w *Window, entered bool)
+type CharModsCallback func(w *Window, char rune, mods ModifierKey)
+type PosCallback func(w *Window, xpos int, ypos int)
+type FocusCallback func(w *Window, focused bool)
+type IconifyCallback func(w *Window, iconified bool)
+type DropCallback func(w *Window, names []string)
+
+func (w *Window) SetCursorPosCallback(cbfun CursorPosCallback) (previous CursorPosCallback) {
+	return nil
+}
+func (w *Window) SetMouseMovementCallback(cbfun MouseMovementCallback) (previous MouseMovementCallback) {
+	return nil
+}
+func (w *Window) SetKeyCallback(cbfun KeyCallback) (previous KeyCallback) {
+	return nil
+}
+func (w *Window) SetCharCallback(cbfun CharCallback) (previous CharCallback) {
+	return nil
+}
+func (w *Window) SetScrollCallback(cbfun ScrollCallback) (previous ScrollCallback) {
+	return nil
+}
+func (w *Window) SetMouseButtonCallback(cbfun MouseButtonCallback) (previous MouseButtonCallback) {
+	return nil
+}
+func (w *Window) SetFramebufferSizeCallback(cbfun FramebufferSizeCallback) (previous FramebufferSizeCallback) {
+	return nil
+}
+func (w *Window) SetCloseCallback(cbfun CloseCallback) (previous CloseCallback) {
+	return nil
+}
+func (w *Window) SetRefreshCallback(cbfun RefreshCallback) (previous RefreshCallback) {
+	return nil
+}
+func (w *Window) SetSizeCallback(cbfun SizeCallback) (previous SizeCallback) {
+	return nil
+}
+func (w *Window) SetCursorEnterCallback(cbfun CursorEnterCallback) (previous CursorEnterCallback) {
+	return nil
+}
+func (w *Window) SetCharModsCallback(cbfun CharModsCallback) (previous CharModsCallback) {
+	return nil
+}
+func (w *Window) SetPosCallback(cbfun PosCallback) (previous PosCallback) {
+	return nil
+}
+func (w *Window) SetFocusCallback(cbfun FocusCallback) (previous FocusCallback) {
+	return nil
+}
+func (w *Window) SetIconifyCallback(cbfun IconifyCallback) (previous IconifyCallback) {
+	return nil
+}
+func (w *Window) SetDropCallback(cbfun DropCallback) (previous DropCallback) {
+	return nil
+}
+
+func (w *Window) MakeContextCurrent()          {}
+func (w *Window) SwapBuffers()                 {}
+func (w *Window) ShouldClose() bool            { return true }
+func (w *Window) SetShouldClose(value bool)    {}
+func (w *Window) GetSize() (width, height int) { return 0, 0 }
+func (w *Window) GetFramebufferSize() (width, height int) {
+	return 0, 0
+}
+func (w *Window) GetContentScale() (float32, float32)                                     { return 1.0, 1.0 }
+func (w *Window) GetPos() (x, y int)                                                      { return 0, 0 }
+func (w *Window) SetPos(xpos, ypos int)                                                   {}
+func (w *Window) SetSize(width, height int)                                               {}
+func (w *Window) GetCursorPos() (x, y float64)                                            { return 0, 0 }
+func (w *Window) SetTitle(title string)                                                   {}
+func (w *Window) SetIcon(images []image.Image)                                            {}
+func (w *Window) Show()                                                                   {}
+func (w *Window) Hide()                                                                   {}
+func (w *Window) Destroy()                                                                {}
+func (w *Window) Maximize()                                                               {}
+func (w *Window) Restore()                                                                {}
+func (w *Window) SetFullscreen()                                                          {}
+func (w *Window) SetWindowed(x, y, width, height int)                                     {}
+func (w *Window) SetWindowToFillScreen()                                                  {}
+func (w *Window) SetDecorations(value bool)                                               {}
+func (w *Window) SetMonitor(monitor *Monitor, xpos, ypos, width, height, refreshRate int) {}
+func (w *Window) GetMonitor() *Monitor                                                    { return nil }
+func (w *Window) GetAttrib(attrib Hint) int                                               { return 0 }
+func (w *Window) BrowserHidden() bool                                                     { return false }
+func (w *Window) EmbeddedIframe() bool                                                    { return false }
+func (w *Window) SetSkipWarningOnBrowserClose(value bool)                                 {}
+func (w *Window) SetClipboardString(str string)                                           {}
+func (w *Window) GetClipboardString() string                                              { return "" }
+func (w *Window) GetKey(key Key) Action                                                   { return Release }
+func (w *Window) GetMouseButton(button MouseButton) Action {
+	return Release
+}
+func (w *Window) GetInputMode(mode InputMode) int        { return 0 }
+func (w *Window) SetInputMode(mode InputMode, value int) {}
+func (w *Window) GetConnectedGamepads() []Joystick       { return nil }
+
+type Key int
+
+const (
+	KeySpace        Key = 32
+	KeyApostrophe   Key = 39
+	KeyComma        Key = 44
+	KeyMinus        Key = 45
+	KeyPeriod       Key = 46
+	KeySlash        Key = 47
+	Key0            Key = 48
+	Key1            Key = 49
+	Key2            Key = 50
+	Key3            Key = 51
+	Key4            Key = 52
+	Key5            Key = 53
+	Key6            Key = 54
+	Key7            Key = 55
+	Key8            Key = 56
+	Key9            Key = 57
+	KeySemicolon    Key = 59
+	KeyEqual        Key = 61
+	KeyA            Key = 65
+	KeyB            Key = 66
+	KeyC            Key = 67
+	KeyD            Key = 68
+	KeyE            Key = 69
+	KeyF            Key = 70
+	KeyG            Key = 71
+	KeyH            Key = 72
+	KeyI            Key = 73
+	KeyJ            Key = 74
+	KeyK            Key = 75
+	KeyL            Key = 76
+	KeyM            Key = 77
+	KeyN            Key = 78
+	KeyO            Key = 79
+	KeyP            Key = 80
+	KeyQ            Key = 81
+	KeyR            Key = 82
+	KeyS            Key = 83
+	KeyT            Key = 84
+	KeyU            Key = 85
+	KeyV            Key = 86
+	KeyW            Key = 87
+	KeyX            Key = 88
+	KeyY            Key = 89
+	KeyZ            Key = 90
+	KeyLeftBracket  Key = 91
+	KeyBackslash    Key = 92
+	KeyRightBracket Key = 93
+	KeyGraveAccent  Key = 96
+	KeyWorld1       Key = 161
+	KeyWorld2       Key = 162
+	KeyEscape       Key = 256
+	KeyEnter        Key = 257
+	KeyTab          Key = 258
+	KeyBackspace    Key = 259
+	KeyInsert       Key = 260
+	KeyDelete       Key = 261
+	KeyRight        Key = 262
+	KeyLeft         Key = 263
+	KeyDown         Key = 264
+	KeyUp           Key = 265
+	KeyPageUp       Key = 266
+	KeyPageDown     Key = 267
+	KeyHome         Key = 268
+	KeyEnd          Key = 269
+	KeyCapsLock     Key = 280
+	KeyScrollLock   Key = 281
+	KeyNumLock      Key = 282
+	KeyPrintScreen  Key = 283
+	KeyPause        Key = 284
+	KeyF1           Key = 290
+	KeyF2           Key = 291
+	KeyF3           Key = 292
+	KeyF4           Key = 293
+	KeyF5           Key = 294
+	KeyF6           Key = 295
+	KeyF7           Key = 296
+	KeyF8           Key = 297
+	KeyF9           Key = 298
+	KeyF10          Key = 299
+	KeyF11          Key = 300
+	KeyF12          Key = 301
+	KeyF13          Key = 302
+	KeyF14          Key = 303
+	KeyF15          Key = 304
+	KeyF16          Key = 305
+	KeyF17          Key = 306
+	KeyF18          Key = 307
+	KeyF19          Key = 308
+	KeyF20          Key = 309
+	KeyF21          Key = 310
+	KeyF22          Key = 311
+	KeyF23          Key = 312
+	KeyF24          Key = 313
+	KeyF25          Key = 314
+	KeyKP0          Key = 320
+	KeyKP1          Key = 321
+	KeyKP2          Key = 322
+	KeyKP3          Key = 323
+	KeyKP4          Key = 324
+	KeyKP5          Key = 325
+	KeyKP6          Key = 326
+	KeyKP7          Key = 327
+	KeyKP8          Key = 328
+	KeyKP9          Key = 329
+	KeyKPDecimal    Key = 330
+	KeyKPDivide     Key = 331
+	KeyKPMultiply   Key = 332
+	KeyKPSubtract   Key = 333
+	KeyKPAdd        Key = 334
+	KeyKPEnter      Key = 335
+	KeyKPEqual      Key = 336
+	KeyLeftShift    Key = 340
+	KeyLeftControl  Key = 341
+	KeyLeftAlt      Key = 342
+	KeyLeftSuper    Key = 343
+	KeyRightShift   Key = 344
+	KeyRightControl Key = 345
+	KeyRightAlt     Key = 346
+	KeyRightSuper   Key = 347
+	KeyMenu         Key = 348
+	KeyUnknown      Key = -1
+	KeyLast             = KeyMenu
+)
+
+func GetKeyScanCode(key Key) int {
+	return -1
+}
+
+func GetKeyName(key Key, scancode int) string {
+	return ""
+}
+
+type MouseButton int
+
+const (
+	MouseButton1    MouseButton = 0
+	MouseButton2    MouseButton = 1
+	MouseButton3    MouseButton = 2
+	MouseButton4    MouseButton = 3
+	MouseButton5    MouseButton = 4
+	MouseButton6    MouseButton = 5
+	MouseButton7    MouseButton = 6
+	MouseButton8    MouseButton = 7
+	MouseButtonLast             = MouseButton8
+
+	MouseButtonLeft   = MouseButton1
+	MouseButtonRight  = MouseButton2
+	MouseButtonMiddle = MouseButton3
+)
+
+type PeripheralEvent int
+
+const (
+	Connected    PeripheralEvent = 0x00040001
+	Disconnected PeripheralEvent = 0x00040002
+)
+
+type Joystick int
+
+const (
+	Joystick1 Joystick = iota
+	Joystick2
+	Joystick3
+	Joystick4
+	Joystick5
+	Joystick6
+	Joystick7
+	Joystick8
+	Joystick9
+	Joystick10
+	Joystick11
+	Joystick12
+	Joystick13
+	Joystick14
+	Joystick15
+	Joystick16
+
+	JoystickLast = Joystick16
+)
+
+func (j Joystick) GetName() string                { return "" }
+func (j Joystick) GetButtons() []Action           { return nil }
+func (j Joystick) GetAxes() []float32             { return nil }
+func (j Joystick) Present() bool                  { return false }
+func (j Joystick) IsGamepad() bool                { return false }
+func (j Joystick) GetGamepadState() *GamepadState { return nil }
+
+type GamepadAxis int
+
+const (
+	AxisLeftX GamepadAxis = iota
+	AxisLeftY
+	AxisRightX
+	AxisRightY
+	AxisLeftTrigger
+	AxisRightTrigger
+
+	AxisLast = AxisRightTrigger
+)
+
+type GamepadButton int
+
+const (
+	ButtonA GamepadButton = iota
+	ButtonB
+	ButtonX
+	ButtonY
+	ButtonLeftBumper
+	ButtonRightBumper
+	ButtonBack
+	ButtonStart
+	ButtonGuide
+	ButtonLeftThumb
+	ButtonRightThumb
+	ButtonDpadUp
+	ButtonDpadRight
+	ButtonDpadDown
+	ButtonDpadLeft
+
+	ButtonLast     = ButtonDpadLeft
+	ButtonCross    = ButtonA
+	ButtonCircle   = ButtonB
+	ButtonSquare   = ButtonX
+	ButtonTriangle = ButtonY
+)
+
+type GamepadState struct {
+	Buttons [15]Action
+	Axes    [6]float32
+}
+
+type Action int
+
+const (
+	Release Action = 0
+	Press   Action = 1
+	Repeat  Action = 2
+)
+
+type InputMode int
+
+const (
+	CursorMode             InputMode = 0x00033001
+	StickyKeysMode         InputMode = 0x00033002
+	StickyMouseButtonsMode InputMode = 0x00033003
+)
+
+const (
+	CursorNormal   = 0x00034001
+	CursorHidden   = 0x00034002
+	CursorDisabled = 0x00034003
+)
+
+type ModifierKey int
+
+const (
+	ModShift   ModifierKey = 0x0001
+	ModControl ModifierKey = 0x0002
+	ModAlt     ModifierKey = 0x0004
+	ModSuper   ModifierKey = 0x0008
+)
@@ -1,5 +1,5 @@
-//go:build !js
-// +build !js
+//go:build !js && !headless
+// +build !js,!headless
 
 package glfw
 
@@ -0,0 +1,45 @@
+//go:build !js && headless
+// +build !js,headless
+
+package glfw
+
+const (
+	True              = 1
+	False             = 0
+	OpenGLCoreProfile = 0x00032001
+)
+
+type Hint int
+
+const (
+	AlphaBits Hint = iota
+	DepthBits
+	StencilBits
+	Samples
+	Resizable
+
+	ContextVersionMajor
+	ContextVersionMinor
+	OpenGLProfile
+	OpenGLForwardCompatible
+
+	// Hints for WebGL contexts.
+	PremultipliedAlpha
+	PreserveDrawingBuffer
+	PreferLowPowerToHighPerformance
+	FailIfMajorPerformanceCaveat
+
+	Decorated
+	Floating
+	AutoIconify
+	TransparentFramebuffer
+	Maximized
+	Visible
+
+	RedBits
+	GreenBits
+	BlueBits
+	RefreshRate
+)
+
+func WindowHint(target Hint, hint int) {}
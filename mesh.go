@@ -34,6 +34,7 @@ func NewMesh() *Mesh {
 func (m *Mesh) Buffer(shader *Shader) *Mesh {
 	return &Mesh{
 		buffer: shader.BufferMesh(m),
+		bounds: m.bounds,
 	}
 }
 
@@ -86,6 +87,21 @@ func (m *Mesh) Append(m2 *Mesh) {
 	m.bounds = m.bounds.Union(m2.bounds)
 }
 
+// Returns the box that contains every position
+func positionBounds(positions []glVec3) Box {
+	if len(positions) == 0 {
+		return Box{}
+	}
+	lo, hi := positions[0], positions[0]
+	for _, p := range positions[1:] {
+		for i := range p {
+			lo[i] = min(lo[i], p[i])
+			hi[i] = max(hi[i], p[i])
+		}
+	}
+	return Box{Min: lo.Float64(), Max: hi.Float64()}
+}
+
 func (m *Mesh) Clone() *Mesh {
 	clone := NewMesh()
 	clone.Append(m)
@@ -120,6 +120,9 @@ type VertexBuffer struct {
 	bufferedToGPU      bool   // Tracks whether the data has been written to the GPU
 	deallocAfterBuffer bool   // If set true, once we write data to the GPU we deallocate CPU buffers
 	deleted            bool   // If true, we've already deleted this
+	leakID             uint64 // The id in the leak registry, see SetLeakTracking
+
+	instances *instanceBuffer // If set, the buffer is drawn once per instance
 }
 
 func NewSubBuffers(shader *Shader, numVerts, numIndices int) bufferData {
@@ -232,6 +235,7 @@ func NewVertexBuffer2(shader *Shader, data bufferData) *VertexBuffer {
 
 	b.Clear() // TODO - fix
 
+	b.leakID = trackResource("vertex buffer")
 	runtime.SetFinalizer(b, (*VertexBuffer).delete)
 
 	return b
@@ -242,15 +246,34 @@ func NewVertexBuffer(shader *Shader, numVerts, numIndices int) *VertexBuffer {
 	return NewVertexBuffer2(shader, data)
 }
 
+// Delete frees the buffer on the GPU. The deletion is queued onto the mainthread, after anything
+// that has already been drawn with the buffer. The buffer can't be used afterwards.
+func (v *VertexBuffer) Delete() {
+	if v.deleted {
+		return
+	}
+	runtime.SetFinalizer(v, nil)
+
+	if global.lastBuffer == v {
+		global.flush()
+	}
+	v.delete()
+}
+
 func (v *VertexBuffer) delete() {
 	if v.deleted {
 		return
 	}
 	v.deleted = true
+	untrackResource(v.leakID)
 
 	mainthread.CallNonBlock(func() {
 		gl.DeleteVertexArrays(v.vao)
 		gl.DeleteBuffers(v.vbo)
+		gl.DeleteBuffers(v.ebo)
+		if v.instances != nil {
+			gl.DeleteBuffers(v.instances.vbo)
+		}
 	})
 }
 
@@ -344,9 +367,14 @@ func (v *VertexBuffer) mainthreadDraw() {
 
 	if !v.bufferedToGPU {
 		v.mainthreadBufferData()
-		gl.DrawElements(gl.TRIANGLES, v.numIndicesToDraw, gl.UNSIGNED_INT, 0)
 	} else {
 		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, v.ebo)
+	}
+
+	if v.instances != nil {
+		v.instances.mainthreadBufferData()
+		gl.DrawElementsInstanced(gl.TRIANGLES, v.numIndicesToDraw, gl.UNSIGNED_INT, 0, v.instances.count)
+	} else {
 		gl.DrawElements(gl.TRIANGLES, v.numIndicesToDraw, gl.UNSIGNED_INT, 0)
 	}
 }
@@ -355,7 +383,14 @@ func (v *VertexBuffer) Draw() {
 	if v.numIndicesToDraw <= 0 {
 		return
 	}
+	if v.instances != nil && v.instances.count <= 0 {
+		return
+	}
 
+	if !v.bufferedToGPU {
+		global.stats.VerticesUploaded += int(v.numVerts)
+		global.stats.IndicesUploaded += v.numIndicesToDraw
+	}
 	state.drawVertBuffer(v)
 }
 
@@ -413,6 +448,7 @@ func (b *BufferPool) Reserve(indices []uint32, numVerts int, dests []interface{}
 	indexBatchSize := max(len(indices), 3*b.triangleBatchSize)
 
 	newBuff := NewVertexBuffer(b.shader, vertBatchSize, indexBatchSize)
+	untrackResource(newBuff.leakID) // Owned by the shader, deleted along with it
 	success := newBuff.Reserve(indices, numVerts, dests)
 	if !success {
 		panic(fmt.Sprintf("Failed to reserve on freshly created buffer:\nReserve: %v, %v, %v\nOn: %v %v",
@@ -0,0 +1,409 @@
+package glitch
+
+import (
+	"cmp"
+	"fmt"
+	"image"
+	"math"
+	"slices"
+
+	"github.com/unitoftime/flow/glm"
+)
+
+// TexturePackMethod is the algorithm that a TexturePacker uses to place images on a page
+type TexturePackMethod uint8
+
+const (
+	TexturePackMaxRects TexturePackMethod = iota // Best short side fit MaxRects, packs tightest
+	TexturePackSkyline                           // Bottom left skyline, faster but leaves more gaps
+)
+
+// TexturePackerConfig configures the pages of a TexturePacker
+type TexturePackerConfig struct {
+	Width, Height int // The size of each page, defaults to 2048x2048
+
+	Method TexturePackMethod
+
+	// The number of transparent pixels left between images, so that mipmaps and linear
+	// filtering don't blend neighbouring images together
+	Padding int
+
+	// The number of times the edge pixels of each image are repeated outwards, so that
+	// linear filtering at the edge of a sprite doesn't blend in the padding
+	Extrude int
+
+	Texture TextureConfig // The sampler state of the page textures
+}
+
+// TexturePacker packs many images into a few shared atlas textures (pages), so that sprites
+// from separate images can still be drawn in the same batch. Images can be added one at a time,
+// and the sprites that are returned are updated in place if the packer is repacked.
+type TexturePacker struct {
+	config  TexturePackerConfig
+	entries []*packerEntry
+	pages   []*packerPage
+}
+
+type packerEntry struct {
+	img    *image.RGBA
+	sprite *Sprite
+	page   int
+	bounds image.Rectangle // The bounds of the image on its page, without extrusion
+}
+
+type packerPage struct {
+	img     *image.RGBA
+	texture *Texture
+	packer  rectPacker
+}
+
+func NewTexturePacker(config TexturePackerConfig) *TexturePacker {
+	if config.Width <= 0 {
+		config.Width = 2048
+	}
+	if config.Height <= 0 {
+		config.Height = 2048
+	}
+	return &TexturePacker{
+		config: config,
+	}
+}
+
+// Add packs img onto the first page that it fits on, creating a new page if it doesn't fit on
+// any of them. The returned sprite draws img from its page.
+// Note: The packer keeps img to repack it later, so don't modify it after adding it
+func (p *TexturePacker) Add(img image.Image) (*Sprite, error) {
+	entry := &packerEntry{
+		img: toRgba(img),
+	}
+	err := p.place(entry, true)
+	if err != nil {
+		return nil, err
+	}
+
+	p.entries = append(p.entries, entry)
+	entry.sprite = NewSprite(p.pages[entry.page].texture, rectToFloat(entry.bounds))
+	return entry.sprite, nil
+}
+
+// Repack packs every image again from scratch, largest first, which usually takes fewer pages
+// than adding the images one at a time. The sprites returned by Add are updated to the new
+// layout. The textures of pages that are no longer needed are deleted.
+// If an image can't be placed then an error is returned and the packer keeps its old layout.
+func (p *TexturePacker) Repack() error {
+	oldPages := p.pages
+	oldEntries := make([]packerEntry, len(p.entries))
+	for i, entry := range p.entries {
+		oldEntries[i] = *entry
+	}
+	err := p.layout()
+	if err != nil {
+		p.pages = oldPages
+		for i, entry := range p.entries {
+			*entry = oldEntries[i]
+		}
+		return err
+	}
+
+	// Reuse the old textures where possible, then upload every page in one go
+	for i, page := range p.pages {
+		if i < len(oldPages) {
+			page.texture = oldPages[i].texture
+			page.texture.SetImage(page.img)
+		} else {
+			page.texture = NewTextureConfig(page.img, p.config.Texture)
+		}
+	}
+	for _, page := range oldPages[min(len(p.pages), len(oldPages)):] {
+		page.texture.Delete()
+	}
+
+	for _, entry := range p.entries {
+		entry.sprite.material.SetTexture(p.pages[entry.page].texture)
+		entry.sprite.SetTextureBounds(rectToFloat(entry.bounds))
+	}
+	return nil
+}
+
+// Places every image again on new pages, largest first. The new pages don't have textures yet.
+func (p *TexturePacker) layout() error {
+	sorted := slices.Clone(p.entries)
+	slices.SortStableFunc(sorted, func(a, b *packerEntry) int {
+		sa, sb := a.img.Bounds().Size(), b.img.Bounds().Size()
+		if c := cmp.Compare(max(sb.X, sb.Y), max(sa.X, sa.Y)); c != 0 {
+			return c
+		}
+		return cmp.Compare(sb.X*sb.Y, sa.X*sa.Y)
+	})
+
+	p.pages = nil
+	for _, entry := range sorted {
+		err := p.place(entry, false)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pages returns the atlas textures, in the order that they were created
+func (p *TexturePacker) Pages() []*Texture {
+	textures := make([]*Texture, len(p.pages))
+	for i, page := range p.pages {
+		textures[i] = page.texture
+	}
+	return textures
+}
+
+// Len returns the number of images that have been added
+func (p *TexturePacker) Len() int {
+	return len(p.entries)
+}
+
+// Places entry on the first page that it fits on, adding a page if needed, and copies it into
+// the page image. If upload is set then the page textures are updated too.
+func (p *TexturePacker) place(entry *packerEntry, upload bool) error {
+	size := entry.img.Bounds().Size()
+	extrude := p.config.Extrude
+	cell := size.Add(image.Pt(2*extrude+p.config.Padding, 2*extrude+p.config.Padding))
+	if size.X <= 0 || size.Y <= 0 {
+		return fmt.Errorf("texture packer: image is empty")
+	}
+	if cell.X > p.config.Width+p.config.Padding || cell.Y > p.config.Height+p.config.Padding {
+		return fmt.Errorf("texture packer: %dx%d image doesn't fit on a %dx%d page", size.X, size.Y, p.config.Width, p.config.Height)
+	}
+
+	var pos image.Point
+	page := -1
+	for i := range p.pages {
+		var ok bool
+		pos, ok = p.pages[i].packer.insert(cell.X, cell.Y)
+		if ok {
+			page = i
+			break
+		}
+	}
+
+	if page < 0 {
+		newPage := p.newPage(upload)
+		pos, _ = newPage.packer.insert(cell.X, cell.Y) // Always fits on an empty page
+		p.pages = append(p.pages, newPage)
+		page = len(p.pages) - 1
+	}
+
+	entry.page = page
+	entry.bounds = image.Rectangle{Min: pos, Max: pos.Add(size)}.Add(image.Pt(extrude, extrude))
+	extruded := entry.bounds.Inset(-extrude)
+	copyExtruded(p.pages[page].img, entry.img, entry.bounds, extrude)
+
+	if upload {
+		sub := p.pages[page].img.SubImage(extruded).(*image.RGBA)
+		pix := make([]uint8, 0, 4*extruded.Dx()*extruded.Dy())
+		for y := extruded.Min.Y; y < extruded.Max.Y; y++ {
+			i := sub.PixOffset(extruded.Min.X, y)
+			pix = append(pix, sub.Pix[i:i+4*extruded.Dx()]...)
+		}
+		p.pages[page].texture.SetPixels(extruded.Min.X, extruded.Min.Y, extruded.Dx(), extruded.Dy(), pix)
+	}
+	return nil
+}
+
+// Creates an empty page. The packing area is larger than the page by the padding, because the
+// padding of the images on the right and bottom edges can hang off of the page.
+func (p *TexturePacker) newPage(texture bool) *packerPage {
+	c := p.config
+	page := &packerPage{
+		img:    image.NewRGBA(image.Rect(0, 0, c.Width, c.Height)),
+		packer: newRectPacker(c.Method, c.Width+c.Padding, c.Height+c.Padding),
+	}
+	if texture {
+		page.texture = NewTextureConfig(page.img, c.Texture)
+	}
+	return page
+}
+
+// Copies src into dst at bounds, repeating the edge pixels of src outwards by extrude pixels
+func copyExtruded(dst, src *image.RGBA, bounds image.Rectangle, extrude int) {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	for y := -extrude; y < h+extrude; y++ {
+		sy := min(max(y, 0), h-1)
+		for x := -extrude; x < w+extrude; x++ {
+			sx := min(max(x, 0), w-1)
+			si := src.PixOffset(src.Rect.Min.X+sx, src.Rect.Min.Y+sy)
+			di := dst.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+}
+
+func rectToFloat(r image.Rectangle) Rect {
+	return glm.R(float64(r.Min.X), float64(r.Min.Y), float64(r.Max.X), float64(r.Max.Y))
+}
+
+//--------------------------------------------------------------------------------
+// Rectangle packing
+
+// Places rectangles inside of a fixed size bin
+type rectPacker interface {
+	// Returns the top left corner of a free w x h area, and marks it as used. Returns false if
+	// there isn't enough space.
+	insert(w, h int) (image.Point, bool)
+}
+
+func newRectPacker(method TexturePackMethod, width, height int) rectPacker {
+	switch method {
+	case TexturePackSkyline:
+		return &skylinePacker{
+			width:  width,
+			height: height,
+			nodes:  []skylineNode{{0, 0, width}},
+		}
+	default:
+		return &maxRectsPacker{
+			free: []image.Rectangle{image.Rect(0, 0, width, height)},
+		}
+	}
+}
+
+// Tracks the maximal free rectangles of the bin, which can overlap each other
+type maxRectsPacker struct {
+	free []image.Rectangle
+}
+
+func (p *maxRectsPacker) insert(w, h int) (image.Point, bool) {
+	best := -1
+	bestShort, bestLong := math.MaxInt, math.MaxInt
+	for i, r := range p.free {
+		if r.Dx() < w || r.Dy() < h {
+			continue
+		}
+		leftX, leftY := r.Dx()-w, r.Dy()-h
+		short, long := min(leftX, leftY), max(leftX, leftY)
+		if short < bestShort || (short == bestShort && long < bestLong) {
+			best = i
+			bestShort, bestLong = short, long
+		}
+	}
+	if best < 0 {
+		return image.Point{}, false
+	}
+
+	pos := p.free[best].Min
+	p.split(image.Rectangle{Min: pos, Max: pos.Add(image.Pt(w, h))})
+	return pos, true
+}
+
+// Splits every free rectangle that overlaps used into the parts that don't, then removes the
+// free rectangles that are inside of other ones
+func (p *maxRectsPacker) split(used image.Rectangle) {
+	var free []image.Rectangle
+	for _, r := range p.free {
+		if !r.Overlaps(used) {
+			free = append(free, r)
+			continue
+		}
+		if used.Min.X > r.Min.X {
+			free = append(free, image.Rect(r.Min.X, r.Min.Y, used.Min.X, r.Max.Y))
+		}
+		if used.Max.X < r.Max.X {
+			free = append(free, image.Rect(used.Max.X, r.Min.Y, r.Max.X, r.Max.Y))
+		}
+		if used.Min.Y > r.Min.Y {
+			free = append(free, image.Rect(r.Min.X, r.Min.Y, r.Max.X, used.Min.Y))
+		}
+		if used.Max.Y < r.Max.Y {
+			free = append(free, image.Rect(r.Min.X, used.Max.Y, r.Max.X, r.Max.Y))
+		}
+	}
+
+	p.free = p.free[:0]
+	for i, r := range free {
+		contained := false
+		for j, o := range free {
+			// Of two equal rectangles, only the first is kept
+			if i != j && r.In(o) && (r != o || j < i) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			p.free = append(p.free, r)
+		}
+	}
+}
+
+// Tracks the top edge of the used area of the bin as a list of horizontal segments
+type skylinePacker struct {
+	width, height int
+	nodes         []skylineNode // Sorted left to right, covering the whole width
+}
+
+type skylineNode struct {
+	x, y, w int
+}
+
+func (p *skylinePacker) insert(w, h int) (image.Point, bool) {
+	best := -1
+	bestBottom, bestWidth := math.MaxInt, math.MaxInt
+	var bestY int
+	for i := range p.nodes {
+		y, ok := p.fit(i, w, h)
+		if !ok {
+			continue
+		}
+		if y+h < bestBottom || (y+h == bestBottom && p.nodes[i].w < bestWidth) {
+			best = i
+			bestBottom, bestWidth = y+h, p.nodes[i].w
+			bestY = y
+		}
+	}
+	if best < 0 {
+		return image.Point{}, false
+	}
+
+	pos := image.Pt(p.nodes[best].x, bestY)
+	p.nodes = slices.Insert(p.nodes, best, skylineNode{pos.X, bestY + h, w})
+
+	// Shrink or remove the nodes that are now covered by the new node
+	for i := best + 1; i < len(p.nodes); {
+		prev, node := p.nodes[i-1], &p.nodes[i]
+		overlap := prev.x + prev.w - node.x
+		if overlap <= 0 {
+			break
+		}
+		node.x += overlap
+		node.w -= overlap
+		if node.w > 0 {
+			break
+		}
+		p.nodes = slices.Delete(p.nodes, i, i+1)
+	}
+
+	// Merge neighbouring nodes at the same height
+	for i := 0; i < len(p.nodes)-1; {
+		if p.nodes[i].y == p.nodes[i+1].y {
+			p.nodes[i].w += p.nodes[i+1].w
+			p.nodes = slices.Delete(p.nodes, i+1, i+2)
+		} else {
+			i++
+		}
+	}
+	return pos, true
+}
+
+// Returns the lowest y that a w x h rectangle can be placed at with its left edge on node i
+func (p *skylinePacker) fit(i, w, h int) (int, bool) {
+	x := p.nodes[i].x
+	if x+w > p.width {
+		return 0, false
+	}
+	y := 0
+	for remaining := w; remaining > 0 && i < len(p.nodes); i++ {
+		y = max(y, p.nodes[i].y)
+		if y+h > p.height {
+			return 0, false
+		}
+		remaining -= p.nodes[i].w
+	}
+	return y, true
+}
@@ -0,0 +1,166 @@
+package glitch
+
+import (
+	"image"
+	"image/color"
+	"math/rand/v2"
+	"testing"
+)
+
+// Returns a w x h image filled with c
+func filledImage(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// Adds images to the packer without uploading any textures
+func placeAll(t *testing.T, p *TexturePacker, imgs []*image.RGBA) {
+	t.Helper()
+	for _, img := range imgs {
+		entry := &packerEntry{img: img}
+		err := p.place(entry, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.entries = append(p.entries, entry)
+	}
+}
+
+// Checks that every entry is on the page image, inside of the page, and that no two entries
+// (with their padding and extrusion) overlap
+func checkLayout(t *testing.T, p *TexturePacker) {
+	t.Helper()
+	c := p.config
+	pageBounds := image.Rect(0, 0, c.Width, c.Height)
+	for i, a := range p.entries {
+		extruded := a.bounds.Inset(-c.Extrude)
+		if !extruded.In(pageBounds) {
+			t.Errorf("entry %d at %v is outside of the page", i, extruded)
+		}
+		for j, b := range p.entries[i+1:] {
+			if a.page != b.page {
+				continue
+			}
+			padded := b.bounds.Inset(-c.Extrude)
+			padded.Max = padded.Max.Add(image.Pt(c.Padding, c.Padding))
+			other := extruded
+			other.Max = other.Max.Add(image.Pt(c.Padding, c.Padding))
+			if other.Overlaps(padded) {
+				t.Errorf("entries %d at %v and %d at %v overlap", i, a.bounds, i+1+j, b.bounds)
+			}
+		}
+
+		page := p.pages[a.page].img
+		for y := range a.bounds.Dy() {
+			for x := range a.bounds.Dx() {
+				got := page.RGBAAt(a.bounds.Min.X+x, a.bounds.Min.Y+y)
+				want := a.img.RGBAAt(x, y)
+				if got != want {
+					t.Fatalf("entry %d: page pixel (%d, %d) = %v, want %v", i, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func randomImages(n, maxSize int) []*image.RGBA {
+	rng := rand.New(rand.NewPCG(1, 1))
+	imgs := make([]*image.RGBA, n)
+	for i := range imgs {
+		c := color.RGBA{uint8(rng.IntN(256)), uint8(rng.IntN(256)), uint8(rng.IntN(256)), 255}
+		imgs[i] = filledImage(1+rng.IntN(maxSize), 1+rng.IntN(maxSize), c)
+	}
+	return imgs
+}
+
+func TestTexturePackerPlacement(t *testing.T) {
+	tests := []struct {
+		name   string
+		config TexturePackerConfig
+	}{
+		{"maxrects", TexturePackerConfig{Width: 64, Height: 64}},
+		{"skyline", TexturePackerConfig{Width: 64, Height: 64, Method: TexturePackSkyline}},
+		{"padding", TexturePackerConfig{Width: 64, Height: 64, Padding: 2}},
+		{"extrude", TexturePackerConfig{Width: 64, Height: 64, Padding: 1, Extrude: 2, Method: TexturePackSkyline}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := NewTexturePacker(test.config)
+			placeAll(t, p, randomImages(40, 20))
+			checkLayout(t, p)
+
+			// Repacking places everything again, usually on fewer pages
+			pages := len(p.pages)
+			err := p.layout()
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkLayout(t, p)
+			if len(p.pages) > pages {
+				t.Errorf("repacking went from %d to %d pages", pages, len(p.pages))
+			}
+		})
+	}
+}
+
+func TestTexturePackerExtrude(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.SetRGBA(0, 0, color.RGBA{255, 0, 0, 255})
+	img.SetRGBA(1, 0, color.RGBA{0, 255, 0, 255})
+	img.SetRGBA(0, 1, color.RGBA{0, 0, 255, 255})
+	img.SetRGBA(1, 1, color.RGBA{255, 255, 255, 255})
+
+	p := NewTexturePacker(TexturePackerConfig{Width: 8, Height: 8, Extrude: 2})
+	placeAll(t, p, []*image.RGBA{img})
+	entry := p.entries[0]
+	if entry.bounds != image.Rect(2, 2, 4, 4) {
+		t.Fatalf("got bounds %v, want the image inset by the extrusion", entry.bounds)
+	}
+
+	// Every pixel around the image repeats the closest edge pixel
+	page := p.pages[0].img
+	for y := range 6 {
+		for x := range 6 {
+			sx, sy := min(max(x-2, 0), 1), min(max(y-2, 0), 1)
+			if got, want := page.RGBAAt(x, y), img.RGBAAt(sx, sy); got != want {
+				t.Errorf("page pixel (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+	if got := page.RGBAAt(6, 6); got != (color.RGBA{}) {
+		t.Errorf("page pixel (6, 6) = %v, want it to be transparent", got)
+	}
+}
+
+func TestTexturePackerPageOverflow(t *testing.T) {
+	p := NewTexturePacker(TexturePackerConfig{Width: 16, Height: 16, Padding: 1})
+
+	// Four 7x7 images with a pixel of padding fill the page exactly, because the padding can
+	// hang off of the edge
+	placeAll(t, p, []*image.RGBA{
+		filledImage(7, 7, color.RGBA{255, 0, 0, 255}),
+		filledImage(7, 7, color.RGBA{0, 255, 0, 255}),
+		filledImage(7, 7, color.RGBA{0, 0, 255, 255}),
+		filledImage(7, 7, color.RGBA{255, 255, 0, 255}),
+	})
+	if len(p.pages) != 1 {
+		t.Fatalf("got %d pages, want 1", len(p.pages))
+	}
+	placeAll(t, p, []*image.RGBA{filledImage(1, 1, color.RGBA{255, 255, 255, 255})})
+	if len(p.pages) != 2 || p.entries[4].page != 1 {
+		t.Errorf("got %d pages with the last image on page %d, want it on a second page", len(p.pages), p.entries[4].page)
+	}
+	checkLayout(t, p)
+
+	for _, size := range []image.Point{{17, 1}, {1, 17}, {0, 4}} {
+		entry := &packerEntry{img: image.NewRGBA(image.Rectangle{Max: size})}
+		if err := p.place(entry, false); err == nil {
+			t.Errorf("placing a %v image on a 16x16 page didn't return an error", size)
+		}
+	}
+}
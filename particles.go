@@ -0,0 +1,341 @@
+package glitch
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// EmitterShape is where an emitter spawns its particles, and which way they move
+type EmitterShape uint8
+
+const (
+	EmitterPoint  EmitterShape = iota // Spawns at the emitter position, moving in every direction
+	EmitterCircle                     // Spawns inside of Radius, moving outwards from the center
+	EmitterRect                       // Spawns inside of Size, moving along Angle give or take Spread
+	EmitterCone                       // Spawns at the emitter position, moving along Angle give or take Spread
+)
+
+// ParticleRange is a range that a particle property is picked from, uniformly
+type ParticleRange struct {
+	Min, Max float64
+}
+
+func (r ParticleRange) sample(rng *rand.Rand) float64 {
+	return r.Min + rng.Float64()*(r.Max-r.Min)
+}
+
+// ParticleEmitter configures how particles are spawned, and how they behave over their lifetime.
+// The fields can be changed between updates.
+type ParticleEmitter struct {
+	Position Vec2    // The position of the emitter, in the local space of the particle system
+	Rate     float64 // The number of particles spawned per second, 0 to only spawn bursts
+
+	Shape  EmitterShape
+	Radius float64 // The radius of EmitterCircle
+	Size   Vec2    // The size of EmitterRect, centered on the position
+	Angle  float64 // The direction of EmitterRect and EmitterCone, in radians
+	Spread float64 // The max angle away from Angle, in radians
+
+	Lifetime        ParticleRange // Seconds
+	Speed           ParticleRange // Units per second
+	Rotation        ParticleRange // The starting rotation, in radians
+	AngularVelocity ParticleRange // Radians per second
+
+	Gravity Vec2    // Acceleration, in units per second per second
+	Drag    float64 // How quickly the velocity decays, it's scaled by e^-Drag every second
+
+	Scale Curve    // The scale of the sprite over the lifetime of a particle, defaults to 1
+	Color Gradient // The color of the particle over its lifetime, defaults to white
+
+	accumulator float64 // Fractional particles carried over to the next update
+}
+
+// Particle is the simulation state of a single particle
+type Particle struct {
+	Position        Vec2
+	Velocity        Vec2
+	Rotation        float64
+	AngularVelocity float64
+	Age, Lifetime   float64 // Seconds
+	Scale           float64
+	Color           RGBA
+
+	emitter *ParticleEmitter
+}
+
+// ParticleSystem simulates particles on the CPU and draws them as sprites. The simulation only
+// depends on the seed and the sequence of updates, so it can be tested without a GPU.
+type ParticleSystem struct {
+	emitters     []*ParticleEmitter
+	particles    []Particle
+	maxParticles int
+	rng          *rand.Rand
+
+	sprite   *Sprite
+	material Material
+	mesh     *Mesh
+}
+
+// NewParticleSystem creates a particle system that draws every particle as sprite, with
+// normal blending. The seed determines every random value of the simulation.
+// If sprite is nil then the system only simulates (eg for tests or a server), it doesn't need a
+// GPU but it can't be drawn.
+func NewParticleSystem(sprite *Sprite, seed uint64) *ParticleSystem {
+	s := &ParticleSystem{
+		rng:    rand.New(rand.NewPCG(seed, seed)),
+		sprite: sprite,
+		mesh:   NewMesh(),
+	}
+	if sprite != nil {
+		s.material = DefaultMaterial(sprite.material.Texture(0))
+		s.material.SetBlendMode(BlendModeNormal)
+	}
+	return s
+}
+
+// AddEmitter adds an emitter to the system. Returns the emitter so that it can be modified later.
+func (s *ParticleSystem) AddEmitter(emitter *ParticleEmitter) *ParticleEmitter {
+	s.emitters = append(s.emitters, emitter)
+	return emitter
+}
+
+// RemoveEmitter stops emitter from spawning, its particles live out the rest of their lifetime
+func (s *ParticleSystem) RemoveEmitter(emitter *ParticleEmitter) {
+	for i, e := range s.emitters {
+		if e == emitter {
+			s.emitters = append(s.emitters[:i], s.emitters[i+1:]...)
+			return
+		}
+	}
+}
+
+// Burst immediately spawns count particles from emitter. The emitter doesn't need to be added.
+func (s *ParticleSystem) Burst(emitter *ParticleEmitter, count int) {
+	for i := 0; i < count; i++ {
+		s.spawn(emitter)
+	}
+}
+
+// SetMaxParticles limits the number of live particles, new particles aren't spawned past the
+// limit. 0 is unlimited.
+func (s *ParticleSystem) SetMaxParticles(maxParticles int) {
+	s.maxParticles = maxParticles
+}
+
+// SetBlendMode sets the blend mode that the particles are drawn with, usually BlendModeNormal
+// or BlendModeAdditive
+func (s *ParticleSystem) SetBlendMode(blend BlendMode) {
+	s.material.SetBlendMode(blend)
+}
+
+func (s *ParticleSystem) Material() *Material {
+	return &s.material
+}
+
+// Particles returns the live particles, oldest first
+func (s *ParticleSystem) Particles() []Particle {
+	return s.particles
+}
+
+// Len returns the number of live particles
+func (s *ParticleSystem) Len() int {
+	return len(s.particles)
+}
+
+// Clear removes every particle
+func (s *ParticleSystem) Clear() {
+	s.particles = s.particles[:0]
+	for _, e := range s.emitters {
+		e.accumulator = 0
+	}
+}
+
+// Update advances the simulation by dt: Existing particles age and move, then the emitters
+// spawn new particles.
+func (s *ParticleSystem) Update(dt time.Duration) {
+	sec := dt.Seconds()
+
+	alive := s.particles[:0]
+	for _, p := range s.particles {
+		p.Age += sec
+		if p.Age >= p.Lifetime {
+			continue
+		}
+		e := p.emitter
+		p.Velocity = p.Velocity.Add(e.Gravity.Scaled(sec)).Scaled(math.Exp(-e.Drag * sec)) // Exact over any dt, so it doesn't depend on the update rate
+		p.Position = p.Position.Add(p.Velocity.Scaled(sec))
+		p.Rotation += p.AngularVelocity * sec
+		s.evaluate(&p)
+		alive = append(alive, p)
+	}
+	s.particles = alive
+
+	for _, e := range s.emitters {
+		e.accumulator += e.Rate * sec
+		n := int(e.accumulator)
+		e.accumulator -= float64(n)
+		s.Burst(e, n)
+	}
+}
+
+// Sets the lifetime driven properties of a particle
+func (s *ParticleSystem) evaluate(p *Particle) {
+	t := p.Age / p.Lifetime
+	p.Scale = p.emitter.Scale.At(t)
+	p.Color = p.emitter.Color.At(t)
+}
+
+func (s *ParticleSystem) spawn(e *ParticleEmitter) {
+	if s.maxParticles > 0 && len(s.particles) >= s.maxParticles {
+		return
+	}
+
+	rng := s.rng
+	pos := e.Position
+	var angle float64
+	switch e.Shape {
+	case EmitterPoint:
+		angle = rng.Float64() * 2 * math.Pi
+	case EmitterCircle:
+		angle = rng.Float64() * 2 * math.Pi
+		r := e.Radius * math.Sqrt(rng.Float64()) // Uniform over the area of the circle
+		pos = pos.Add(Vec2{math.Cos(angle) * r, math.Sin(angle) * r})
+	case EmitterRect:
+		pos = pos.Add(Vec2{(rng.Float64() - 0.5) * e.Size.X, (rng.Float64() - 0.5) * e.Size.Y})
+		angle = e.Angle + (2*rng.Float64()-1)*e.Spread
+	case EmitterCone:
+		angle = e.Angle + (2*rng.Float64()-1)*e.Spread
+	}
+
+	speed := e.Speed.sample(rng)
+	p := Particle{
+		Position:        pos,
+		Velocity:        Vec2{math.Cos(angle) * speed, math.Sin(angle) * speed},
+		Rotation:        e.Rotation.sample(rng),
+		AngularVelocity: e.AngularVelocity.sample(rng),
+		Lifetime:        e.Lifetime.sample(rng),
+		emitter:         e,
+	}
+	if p.Lifetime <= 0 {
+		return
+	}
+	s.evaluate(&p)
+	s.particles = append(s.particles, p)
+}
+
+func (s *ParticleSystem) Draw(target BatchTarget, matrix Mat4) {
+	s.DrawColorMask(target, matrix, White)
+}
+
+// Note: The particles are read when the draw is flushed to the GPU, so don't update the system
+// between drawing it and drawing the target that it was added to.
+func (s *ParticleSystem) DrawColorMask(target BatchTarget, matrix Mat4, mask RGBA) {
+	if s.sprite == nil {
+		panic("ParticleSystem: can't draw a system that was created without a sprite")
+	}
+	if len(s.particles) == 0 {
+		return
+	}
+
+	s.mesh.Clear()
+	uv := s.sprite.uvBounds
+	w, h := s.sprite.bounds.W()/2, s.sprite.bounds.H()/2
+	texCoords := [4]glVec2{
+		{float32(uv.Max.X), float32(uv.Min.Y)},
+		{float32(uv.Max.X), float32(uv.Max.Y)},
+		{float32(uv.Min.X), float32(uv.Max.Y)},
+		{float32(uv.Min.X), float32(uv.Min.Y)},
+	}
+	// The corners in the vertex order of NewQuadMesh
+	corners := [4]Vec2{{w, h}, {w, -h}, {-w, -h}, {-w, h}}
+
+	for i, p := range s.particles {
+		sin, cos := math.Sincos(p.Rotation)
+		color := glc4(p.Color)
+		start := uint32(len(s.mesh.positions))
+		for _, c := range corners {
+			x := (c.X*cos - c.Y*sin) * p.Scale
+			y := (c.X*sin + c.Y*cos) * p.Scale
+			s.mesh.positions = append(s.mesh.positions, glVec3{float32(p.Position.X + x), float32(p.Position.Y + y), 0})
+			s.mesh.colors = append(s.mesh.colors, color)
+		}
+		s.mesh.texCoords = append(s.mesh.texCoords, texCoords[:]...)
+		s.mesh.indices = append(s.mesh.indices, start, start+1, start+3, start+1, start+2, start+3)
+
+		r := math.Max(w, h) * math.Sqrt2 * p.Scale
+		bounds := Rect{
+			Min: Vec2{p.Position.X - r, p.Position.Y - r},
+			Max: Vec2{p.Position.X + r, p.Position.Y + r},
+		}.ToBox()
+		if i == 0 {
+			s.mesh.bounds = bounds
+		} else {
+			s.mesh.bounds = s.mesh.bounds.Union(bounds)
+		}
+	}
+
+	target.Add(s.mesh.g(), glm4(matrix), mask, s.material)
+}
+
+//--------------------------------------------------------------------------------
+
+// CurveKey is a value at position T (0 to 1) along a Curve
+type CurveKey struct {
+	T, Value float64
+}
+
+// Curve is a value that changes over 0 to 1, linearly interpolated between keys that are
+// sorted by T. An empty curve is 1 everywhere.
+type Curve []CurveKey
+
+// At returns the value of the curve at t
+func (c Curve) At(t float64) float64 {
+	if len(c) == 0 {
+		return 1
+	}
+	if t <= c[0].T {
+		return c[0].Value
+	}
+	for i := 1; i < len(c); i++ {
+		if t <= c[i].T {
+			a, b := c[i-1], c[i]
+			f := (t - a.T) / (b.T - a.T)
+			return a.Value + (b.Value-a.Value)*f
+		}
+	}
+	return c[len(c)-1].Value
+}
+
+// GradientKey is a color at position T (0 to 1) along a Gradient
+type GradientKey struct {
+	T     float64
+	Color RGBA
+}
+
+// Gradient is a color that changes over 0 to 1, linearly interpolated between keys that are
+// sorted by T. An empty gradient is white everywhere.
+type Gradient []GradientKey
+
+// At returns the color of the gradient at t
+func (g Gradient) At(t float64) RGBA {
+	if len(g) == 0 {
+		return White
+	}
+	if t <= g[0].T {
+		return g[0].Color
+	}
+	for i := 1; i < len(g); i++ {
+		if t <= g[i].T {
+			a, b := g[i-1], g[i]
+			f := (t - a.T) / (b.T - a.T)
+			return RGBA{
+				R: a.Color.R + (b.Color.R-a.Color.R)*f,
+				G: a.Color.G + (b.Color.G-a.Color.G)*f,
+				B: a.Color.B + (b.Color.B-a.Color.B)*f,
+				A: a.Color.A + (b.Color.A-a.Color.A)*f,
+			}
+		}
+	}
+	return g[len(g)-1].Color
+}
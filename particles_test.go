@@ -0,0 +1,137 @@
+package glitch_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/unitoftime/glitch"
+)
+
+func assertNear(t *testing.T, name string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}
+
+func TestParticlesDeterministic(t *testing.T) {
+	emitter := func() *glitch.ParticleEmitter {
+		return &glitch.ParticleEmitter{
+			Rate:            30,
+			Shape:           glitch.EmitterCircle,
+			Radius:          5,
+			Lifetime:        glitch.ParticleRange{Min: 0.5, Max: 1.5},
+			Speed:           glitch.ParticleRange{Min: 10, Max: 20},
+			Rotation:        glitch.ParticleRange{Min: 0, Max: math.Pi},
+			AngularVelocity: glitch.ParticleRange{Min: -1, Max: 1},
+			Gravity:         glitch.Vec2{0, -9.8},
+			Drag:            0.5,
+		}
+	}
+	dts := []time.Duration{16 * time.Millisecond, 33 * time.Millisecond, 8 * time.Millisecond, 100 * time.Millisecond}
+	run := func(seed uint64) []glitch.Particle {
+		system := glitch.NewParticleSystem(nil, seed)
+		system.AddEmitter(emitter())
+		for range 10 {
+			for _, dt := range dts {
+				system.Update(dt)
+			}
+		}
+		return system.Particles()
+	}
+
+	a, b := run(1), run(1)
+	if len(a) == 0 || len(a) != len(b) {
+		t.Fatalf("got %d and %d particles from the same seed", len(a), len(b))
+	}
+	for i := range a {
+		if a[i].Position != b[i].Position || a[i].Velocity != b[i].Velocity || a[i].Rotation != b[i].Rotation || a[i].Age != b[i].Age {
+			t.Fatalf("particle %d differs between runs with the same seed: %+v and %+v", i, a[i], b[i])
+		}
+	}
+
+	c := run(2)
+	if len(c) > 0 && c[0].Position == a[0].Position {
+		t.Errorf("the first particle is the same for different seeds: %+v", c[0])
+	}
+}
+
+func TestParticlesMotion(t *testing.T) {
+	system := glitch.NewParticleSystem(nil, 1)
+	system.Burst(&glitch.ParticleEmitter{
+		Position: glitch.Vec2{1, 2},
+		Shape:    glitch.EmitterCone,
+		Lifetime: glitch.ParticleRange{Min: 10, Max: 10},
+		Speed:    glitch.ParticleRange{Min: 10, Max: 10},
+		Gravity:  glitch.Vec2{0, -10},
+		Scale:    glitch.Curve{{T: 0, Value: 1}, {T: 1, Value: 0}},
+		Color:    glitch.Gradient{{T: 0, Color: glitch.White}, {T: 1, Color: glitch.RGBA{0, 0, 0, 0}}},
+	}, 1)
+
+	// Velocity is integrated before position, so after n steps y has moved by
+	// -g * dt^2 * n(n+1)/2
+	const n, dt = 5, 0.1
+	for range n {
+		system.Update(100 * time.Millisecond)
+	}
+	p := system.Particles()[0]
+	assertNear(t, "x", p.Position.X, 1+10*n*dt)
+	assertNear(t, "y", p.Position.Y, 2-10*dt*dt*n*(n+1)/2)
+	assertNear(t, "velocity x", p.Velocity.X, 10)
+	assertNear(t, "velocity y", p.Velocity.Y, -10*n*dt)
+	assertNear(t, "age", p.Age, n*dt)
+	assertNear(t, "scale", p.Scale, 1-n*dt/10)
+	assertNear(t, "alpha", p.Color.A, 1-n*dt/10)
+}
+
+func TestParticlesDragIndependentOfUpdateRate(t *testing.T) {
+	emitter := &glitch.ParticleEmitter{
+		Shape:    glitch.EmitterCone,
+		Lifetime: glitch.ParticleRange{Min: 10, Max: 10},
+		Speed:    glitch.ParticleRange{Min: 8, Max: 8},
+		Drag:     2,
+	}
+	velocity := func(steps int) float64 {
+		system := glitch.NewParticleSystem(nil, 1)
+		system.Burst(emitter, 1)
+		for range steps {
+			system.Update(time.Second / time.Duration(steps))
+		}
+		return system.Particles()[0].Velocity.X
+	}
+
+	want := 8 * math.Exp(-2)
+	for _, steps := range []int{1, 10, 50} {
+		assertNear(t, "velocity", velocity(steps), want)
+	}
+}
+
+func TestParticlesLifetimeAndRate(t *testing.T) {
+	system := glitch.NewParticleSystem(nil, 1)
+	system.AddEmitter(&glitch.ParticleEmitter{
+		Rate:     10,
+		Lifetime: glitch.ParticleRange{Min: 1, Max: 1},
+	})
+
+	// Fractions of a particle carry over between updates
+	for range 4 {
+		system.Update(25 * time.Millisecond)
+	}
+	if n := system.Len(); n != 1 {
+		t.Fatalf("got %d particles after 0.1s at 10 per second, want 1", n)
+	}
+	for range 36 {
+		system.Update(25 * time.Millisecond)
+	}
+	if n := system.Len(); n != 10 {
+		t.Errorf("got %d particles after 1s with a 1s lifetime, want 10", n)
+	}
+
+	system.SetMaxParticles(5)
+	system.Clear()
+	system.Update(time.Second)
+	if n := system.Len(); n != 5 {
+		t.Errorf("got %d particles with a max of 5, want 5", n)
+	}
+}
@@ -0,0 +1,269 @@
+package glitch
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/unitoftime/flow/glm"
+	"github.com/unitoftime/glitch/shaders"
+)
+
+// PostPass is one fullscreen pass of a PostProcess. The pass's shader is drawn over the whole
+// target and reads the output of the previous pass through its texture1 sampler, and the
+// source frame of the chain through its sourceTexture sampler. If the shader has a texelSize
+// uniform then it is set to the size of one texel of the input.
+type PostPass struct {
+	material Material
+}
+
+// NewPostPass creates a pass that draws with shader. Usually the shader uses the sprite vertex
+// shader with its own fragment shader (like shaders.BlurShader).
+func NewPostPass(shader *Shader) *PostPass {
+	material := NewMaterial(shader)
+	material.uniforms = &Uniforms{}
+	return &PostPass{
+		material: material,
+	}
+}
+
+// SetUniform sets a uniform that is applied every time the pass is drawn
+func (p *PostPass) SetUniform(name string, val any) *PostPass {
+	p.material.SetUniform(name, val)
+	return p
+}
+
+// Material returns the material that the pass draws with. Texture slots 0 and 1 are replaced
+// with the pass input and the chain source when the pass is drawn.
+func (p *PostPass) Material() *Material {
+	return &p.material
+}
+
+// PostProcess draws a source Frame to a target through an ordered chain of fullscreen passes.
+// The passes ping-pong between two intermediate frames that are sized to the target.
+type PostProcess struct {
+	passes []*PostPass
+	format TextureFormat // The format of the intermediate frames
+	frames [2]*Frame
+	bounds Rect
+	mesh   *Mesh
+	camera *CameraOrtho
+}
+
+func NewPostProcess(passes ...*PostPass) *PostProcess {
+	return &PostProcess{
+		passes: passes,
+		camera: NewCameraOrtho(),
+	}
+}
+
+// Add appends passes to the end of the chain
+func (p *PostProcess) Add(passes ...*PostPass) *PostProcess {
+	p.passes = append(p.passes, passes...)
+	return p
+}
+
+// Passes returns the chain of passes, in the order that they are applied
+func (p *PostProcess) Passes() []*PostPass {
+	return p.passes
+}
+
+// SetFormat sets the format of the intermediate frames (eg TextureFormatRGBA16F for HDR chains).
+// It returns an error if frames can't be created with the format.
+func (p *PostProcess) SetFormat(format TextureFormat) error {
+	err := FrameConfig{Colors: []TextureFormat{format}}.validate()
+	if err != nil {
+		return fmt.Errorf("post process: %w", err)
+	}
+	if p.format != format {
+		p.format = format
+		p.deleteFrames()
+	}
+	return nil
+}
+
+// Delete frees the intermediate frames on the GPU. They're recreated if the chain is drawn again.
+func (p *PostProcess) Delete() {
+	p.deleteFrames()
+}
+
+// Draw applies the chain to source and draws the result over the whole target.
+// If the target doesn't have bounds then it is assumed to be the same size as source.
+// Note: The final pass overwrites the target unless its material sets a blend mode
+func (p *PostProcess) Draw(target Target, source *Frame) {
+	bounds := source.Bounds()
+	if t, ok := target.(interface{ Bounds() Rect }); ok {
+		bounds = t.Bounds()
+	}
+	if bounds != p.bounds || p.mesh == nil {
+		p.bounds = bounds
+		p.mesh = NewQuadMesh(bounds, glm.R(0, 1, 1, 0))
+		p.deleteFrames()
+		p.camera.SetOrtho2D(bounds)
+		p.camera.SetView2D(0, 0, 1, 1)
+	}
+
+	source.Resolve()
+	prevCamera := global.camera
+	SetCamera(p.camera)
+
+	if len(p.passes) == 0 {
+		setTarget(target)
+		global.Add(p.mesh.g(), glMat4Ident, White, source.material)
+	}
+
+	input := source.Texture(0)
+	for i, pass := range p.passes {
+		var out Target = target
+		var frame *Frame
+		if i < len(p.passes)-1 {
+			frame = p.frame(i % 2)
+			Clear(frame, RGBA{})
+			out = frame
+		}
+
+		material := pass.material
+		material.SetTextureSlot(0, "texture1", input)
+		material.SetTextureSlot(1, "sourceTexture", source.Texture(0))
+		if material.shader.hasUniform("texelSize") {
+			material.SetUniform("texelSize", Vec2{1 / float64(input.width), 1 / float64(input.height)})
+		}
+
+		setTarget(out)
+		global.Add(p.mesh.g(), glMat4Ident, White, material)
+
+		if frame != nil {
+			input = frame.Texture(0)
+		}
+	}
+
+	global.flush()
+	SetCameraMaterial(prevCamera)
+}
+
+// Returns intermediate frame i, creating it if it doesn't exist yet
+func (p *PostProcess) frame(i int) *Frame {
+	if p.frames[i] == nil {
+		p.frames[i] = newFrameConfig(p.bounds, FrameConfig{
+			Smooth:  true,
+			Colors:  []TextureFormat{p.format},
+			NoDepth: true,
+		})
+	}
+	return p.frames[i]
+}
+
+func (p *PostProcess) deleteFrames() {
+	for i, frame := range p.frames {
+		if frame != nil {
+			frame.Delete()
+			p.frames[i] = nil
+		}
+	}
+}
+
+//--------------------------------------------------------------------------------
+// Built in passes
+
+var blurShader, bloomThresholdShader, bloomCombineShader, colorGradeShader *Shader
+
+func getPostShader(shader **Shader, cfg shaders.ShaderConfig) (*Shader, error) {
+	if *shader != nil {
+		return *shader, nil
+	}
+
+	s, err := NewShader(cfg)
+	if err != nil {
+		return nil, err
+	}
+	untrackResource(s.leakID) // Owned by glitch
+	*shader = s
+	return s, nil
+}
+
+// NewBlurPasses returns the horizontal and vertical passes of a gaussian blur.
+// Spread is the distance between the blur samples, in texels.
+func NewBlurPasses(spread float64) ([]*PostPass, error) {
+	shader, err := getPostShader(&blurShader, shaders.BlurShader)
+	if err != nil {
+		return nil, fmt.Errorf("blur passes: %w", err)
+	}
+	return []*PostPass{
+		NewPostPass(shader).SetUniform("direction", Vec2{spread, 0}),
+		NewPostPass(shader).SetUniform("direction", Vec2{0, spread}),
+	}, nil
+}
+
+// NewBloomPasses returns the passes of a bloom effect: The parts of the image brighter than
+// threshold (0 to 1) are blurred and added back on top of the source, scaled by intensity.
+// Note: The combine pass reads the source of the chain, so put the bloom passes first
+func NewBloomPasses(threshold, intensity, spread float64) ([]*PostPass, error) {
+	thresholdShader, err := getPostShader(&bloomThresholdShader, shaders.BloomThresholdShader)
+	if err != nil {
+		return nil, fmt.Errorf("bloom passes: %w", err)
+	}
+	combineShader, err := getPostShader(&bloomCombineShader, shaders.BloomCombineShader)
+	if err != nil {
+		return nil, fmt.Errorf("bloom passes: %w", err)
+	}
+	blur, err := NewBlurPasses(spread)
+	if err != nil {
+		return nil, fmt.Errorf("bloom passes: %w", err)
+	}
+
+	passes := []*PostPass{
+		NewPostPass(thresholdShader).SetUniform("threshold", threshold),
+	}
+	passes = append(passes, blur...)
+	passes = append(passes,
+		NewPostPass(combineShader).SetUniform("intensity", intensity),
+	)
+	return passes, nil
+}
+
+// NewColorGradePass returns a pass that remaps colors through the lookup table lut, with
+// intensity (0 to 1) blending between the original and the graded color. The lut is laid out
+// like the image from IdentityLUT, and should be a smooth texture.
+func NewColorGradePass(lut *Texture, intensity float64) (*PostPass, error) {
+	if lut == nil {
+		return nil, fmt.Errorf("color grade pass: missing lut")
+	}
+	if lut.height < 2 || lut.width != lut.height*lut.height {
+		return nil, fmt.Errorf("color grade pass: a %dx%d lut isn't a strip of square slices", lut.width, lut.height)
+	}
+	shader, err := getPostShader(&colorGradeShader, shaders.ColorGradeShader)
+	if err != nil {
+		return nil, fmt.Errorf("color grade pass: %w", err)
+	}
+
+	pass := NewPostPass(shader)
+	pass.material.SetTextureSlot(2, "lutTexture", lut)
+	pass.SetUniform("lutSize", float64(lut.height))
+	pass.SetUniform("intensity", intensity)
+	return pass, nil
+}
+
+// IdentityLUT returns a color lookup table that maps every color to itself, to be edited into a
+// color grade. The table is a strip of size slices, each size x size pixels. Red increases to
+// the right inside each slice, green increases downwards and blue increases from slice to slice.
+// It returns an error if the size is less than 2.
+func IdentityLUT(size int) (*image.RGBA, error) {
+	if size < 2 {
+		return nil, fmt.Errorf("identity lut: size must be at least 2, got %d", size)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, size*size, size))
+	scale := 255 / float64(size-1)
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				img.SetRGBA(b*size+r, g, color.RGBA{
+					uint8(float64(r)*scale + 0.5),
+					uint8(float64(g)*scale + 0.5),
+					uint8(float64(b)*scale + 0.5),
+					255,
+				})
+			}
+		}
+	}
+	return img, nil
+}
@@ -27,8 +27,9 @@ func (s *Sprite) ToQuad() Quad {
 	}
 }
 
+// Bounds returns the bounds of the quad's geometry, offset by Origin
 func (s Quad) Bounds() glm.Box {
-	return s.Frame.WithCenter(glm.Vec2{}).Box()
+	return s.Frame.WithCenter(glm.Vec2{}).Box().Moved(s.Origin.Scaled(-1, -1, -1))
 }
 
 func (s Quad) g() GeometryFiller {
@@ -80,7 +81,7 @@ func (s Quad) Fill(pool *BufferPool, mat glMat4, mask RGBA) *VertexBuffer {
 		// TODO - I'm not sure of a good way to break up this switch statement
 		switch attr.Swizzle {
 		case shaders.PositionXYZ:
-			bounds := s.Bounds().Moved(s.Origin.Scaled(-1, -1, -1))
+			bounds := s.Bounds()
 			min := glv3(bounds.Min)
 			max := glv3(bounds.Max)
 
@@ -104,7 +105,7 @@ func (s Quad) Fill(pool *BufferPool, mat glMat4, mask RGBA) *VertexBuffer {
 			colBuf[2] = color
 			colBuf[3] = color
 		case shaders.TexCoordXY:
-			texture := s.material.texture
+			texture := s.material.textures[0].texture
 			uvBounds := glm.R(
 				s.Frame.Min.X/float64(texture.width),
 				s.Frame.Min.Y/float64(texture.height),
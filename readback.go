@@ -0,0 +1,186 @@
+package glitch
+
+import (
+	"encoding/binary"
+	"image"
+	"math"
+
+	"github.com/unitoftime/glitch/internal/gl"
+	"github.com/unitoftime/glitch/internal/mainthread"
+	"github.com/unitoftime/glitch/shaders"
+)
+
+// Image reads the frame's first color attachment back into an image. Float attachments are
+// clamped to [0, 1].
+// Note: This flushes any pending draws and stalls until the GPU has finished rendering them
+func (f *Frame) Image() *image.RGBA {
+	f.Resolve()
+	t := f.textures[0]
+	return readFramebuffer(f.fbo, t.width, t.height, t.format)
+}
+
+// ImageAsync starts reading the frame's first color attachment back into an image, without
+// waiting for the GPU. The image is sent on the returned channel once it is ready, which is one
+// Window.Update after the call. WebGL1 can't read pixels asynchronously, so there the pixels
+// are read immediately, like Image, and the image is ready as soon as this returns.
+func (f *Frame) ImageAsync() <-chan *image.RGBA {
+	f.Resolve()
+	t := f.textures[0]
+	return readFramebufferAsync(f.fbo, t.width, t.height, t.format)
+}
+
+// Screenshot reads the window's framebuffer back into an image.
+// Note: This flushes any pending draws and stalls until the GPU has finished rendering them
+func (w *Window) Screenshot() *image.RGBA {
+	return readFramebuffer(gl.NoFramebuffer, w.width, w.height, TextureFormatRGBA8)
+}
+
+// ScreenshotAsync starts reading the window's framebuffer back into an image, without waiting
+// for the GPU. The image is sent on the returned channel once it is ready, which is one
+// Window.Update after the call. Like ImageAsync, WebGL1 reads the pixels immediately.
+// Note: Call this before Window.Update, the back buffer is undefined once it is swapped
+func (w *Window) ScreenshotAsync() <-chan *image.RGBA {
+	return readFramebufferAsync(gl.NoFramebuffer, w.width, w.height, TextureFormatRGBA8)
+}
+
+// Reads the framebuffer's color attachment, which is stored in format. The color formats are
+// always read as RGBA, with the type of the format, because that's the only combination that
+// OpenGL ES guarantees for every format.
+func readFramebuffer(fbo gl.Framebuffer, width, height int, format TextureFormat) *image.RGBA {
+	global.flush()
+
+	f := textureFormatLut[format]
+	pix := make([]byte, 4*f.size*width*height)
+	if len(pix) == 0 {
+		return image.NewRGBA(image.Rect(0, 0, width, height))
+	}
+	mainthread.Call(func() {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
+		gl.ReadPixels(pix, 0, 0, width, height, gl.RGBA, f.ty)
+		gl.BindFramebuffer(gl.FRAMEBUFFER, state.fbo)
+	})
+	return pixelsToImage(pixelsToBytes(pix, f.ty), width, height)
+}
+
+// --------------------------------------------------------------------------------
+// Async readback: The pixels are copied into a pixel buffer object on the GPU, and only
+// copied back to the CPU on a later frame, once the GPU has had time to finish the copy.
+type readback struct {
+	pbo           gl.Buffer
+	width, height int
+	format        TextureFormat
+	done          chan *image.RGBA
+}
+
+var readbacks struct {
+	inflight []*readback // Issued this frame
+	ready    []*readback // Issued last frame, resolved on the next update
+}
+
+func readFramebufferAsync(fbo gl.Framebuffer, width, height int, format TextureFormat) <-chan *image.RGBA {
+	global.flush()
+
+	rb := &readback{
+		width:  width,
+		height: height,
+		format: format,
+		done:   make(chan *image.RGBA, 1),
+	}
+	if width*height == 0 {
+		rb.done <- image.NewRGBA(image.Rect(0, 0, width, height))
+		return rb.done
+	}
+
+	// WebGL1 doesn't have pixel buffer objects
+	if ShaderDialect() == shaders.GLSL100 {
+		rb.done <- readFramebuffer(fbo, width, height, format)
+		return rb.done
+	}
+
+	f := textureFormatLut[format]
+	mainthread.Call(func() {
+		rb.pbo = gl.GenBuffers()
+		gl.BindBuffer(gl.PIXEL_PACK_BUFFER, rb.pbo)
+		gl.BufferData(gl.PIXEL_PACK_BUFFER, 4*f.size*width*height, nil, gl.STREAM_READ)
+
+		gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
+		gl.ReadPixelsOffset(0, 0, 0, width, height, gl.RGBA, f.ty)
+		gl.BindFramebuffer(gl.FRAMEBUFFER, state.fbo)
+
+		gl.BindBuffer(gl.PIXEL_PACK_BUFFER, gl.NoBuffer)
+	})
+
+	readbacks.inflight = append(readbacks.inflight, rb)
+	return rb.done
+}
+
+// Resolves the readbacks that were issued before the last update. Called once per Window.Update
+func updateReadbacks() {
+	if len(readbacks.ready) > 0 {
+		mainthread.Call(mainthreadResolveReadbacks)
+	}
+
+	readbacks.ready, readbacks.inflight = readbacks.inflight, readbacks.ready[:0]
+}
+
+func mainthreadResolveReadbacks() {
+	for _, rb := range readbacks.ready {
+		f := textureFormatLut[rb.format]
+		pix := make([]byte, 4*f.size*rb.width*rb.height)
+		gl.BindBuffer(gl.PIXEL_PACK_BUFFER, rb.pbo)
+		gl.GetBufferSubDataByte(gl.PIXEL_PACK_BUFFER, 0, pix)
+		gl.BindBuffer(gl.PIXEL_PACK_BUFFER, gl.NoBuffer)
+		gl.DeleteBuffers(rb.pbo)
+
+		// Do the conversion off of the mainthread
+		go func(rb *readback) {
+			rb.done <- pixelsToImage(pixelsToBytes(pix, f.ty), rb.width, rb.height)
+		}(rb)
+	}
+}
+
+// Converts pixels that were read with type ty to 8 bit channels. Float channels are clamped to
+// [0, 1].
+func pixelsToBytes(pix []byte, ty gl.Enum) []byte {
+	if ty != gl.FLOAT {
+		return pix
+	}
+	bytes := make([]byte, len(pix)/4)
+	for i := range bytes {
+		v := math.Float32frombits(binary.NativeEndian.Uint32(pix[4*i:]))
+		bytes[i] = uint8(min(max(v, 0), 1)*255 + 0.5)
+	}
+	return bytes
+}
+
+// Converts pixels read from opengl into an image. Opengl rows go from bottom to top, and
+// colors are stored with premultiplied alpha, so this flips the rows and unpremultiplies the
+// colors.
+// Note: This means that the image's Pix holds straight alpha colors
+func pixelsToImage(pix []byte, width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	stride := 4 * width
+	for y := 0; y < height; y++ {
+		src := pix[(height-1-y)*stride : (height-y)*stride]
+		dst := img.Pix[y*img.Stride : y*img.Stride+stride]
+		for i := 0; i < len(src); i += 4 {
+			a := uint32(src[i+3])
+			switch a {
+			case 0:
+				dst[i], dst[i+1], dst[i+2] = 0, 0, 0
+			case 255:
+				dst[i], dst[i+1], dst[i+2] = src[i], src[i+1], src[i+2]
+			default:
+				dst[i] = unpremultiply(src[i], a)
+				dst[i+1] = unpremultiply(src[i+1], a)
+				dst[i+2] = unpremultiply(src[i+2], a)
+			}
+			dst[i+3] = src[i+3]
+		}
+	}
+	return img
+}
+
+func unpremultiply(c uint8, a uint32) uint8 {
+	return uint8(min(255, (uint32(c)*255+a/2)/a))
+}
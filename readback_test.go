@@ -0,0 +1,62 @@
+//go:build headless
+
+package glitch
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/unitoftime/flow/glm"
+)
+
+func TestFrameImageFormats(t *testing.T) {
+	// The red channel is clamped when it's read back from the float formats
+	want := color.RGBA{255, 128, 0, 255}
+	check := func(t *testing.T, img *image.RGBA) {
+		t.Helper()
+		if img.Bounds() != image.Rect(0, 0, 4, 2) {
+			t.Fatalf("got image bounds %v", img.Bounds())
+		}
+		for y := range 2 {
+			for x := range 4 {
+				if got := img.RGBAAt(x, y); got != want {
+					t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+				}
+			}
+		}
+	}
+
+	tests := []struct {
+		name   string
+		format TextureFormat
+	}{
+		{"rgba8", TextureFormatRGBA8},
+		{"rgba16f", TextureFormatRGBA16F},
+		{"rgba32f", TextureFormatRGBA32F},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			frame, err := NewFrameConfig(glm.R(0, 0, 4, 2), FrameConfig{Colors: []TextureFormat{test.format}, NoDepth: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer frame.Delete()
+			Clear(frame, RGBA{2, 0.5, 0, 1})
+
+			check(t, frame.Image())
+
+			// The async image is ready after the second update
+			done := frame.ImageAsync()
+			updateReadbacks()
+			updateReadbacks()
+			select {
+			case img := <-done:
+				check(t, img)
+			case <-time.After(time.Second):
+				t.Fatal("the async image wasn't ready after two updates")
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package glitch
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"slices"
+	"sync"
+)
+
+// LiveResource is a GPU resource that hasn't been deleted, see SetLeakTracking
+type LiveResource struct {
+	Kind  string // "texture", "frame", "vertex buffer" or "shader"
+	Stack string // The stack trace of where the resource was created
+	id    uint64
+}
+
+// The debug registry of live GPU resources. Finalizers run on their own goroutine, so it's locked.
+var leaks = struct {
+	sync.Mutex
+	enabled bool
+	lastID  uint64
+	live    map[uint64]LiveResource
+}{
+	live: make(map[uint64]LiveResource),
+}
+
+// SetLeakTracking enables or disables the debug registry of live GPU resources. While enabled,
+// every texture, frame, vertex buffer and shader that is created is recorded with the stack
+// trace of where it was created, until it is deleted. Any resources that are still alive when
+// the function passed to Run returns are reported to stderr.
+// Capturing the stack traces is slow, so this is only meant for debugging.
+func SetLeakTracking(enable bool) {
+	leaks.Lock()
+	defer leaks.Unlock()
+
+	leaks.enabled = enable
+	if !enable {
+		clear(leaks.live)
+	}
+}
+
+// LiveResources returns the tracked resources that haven't been deleted, oldest first
+func LiveResources() []LiveResource {
+	leaks.Lock()
+	defer leaks.Unlock()
+
+	live := make([]LiveResource, 0, len(leaks.live))
+	for _, r := range leaks.live {
+		live = append(live, r)
+	}
+	slices.SortFunc(live, func(a, b LiveResource) int {
+		return cmp.Compare(a.id, b.id)
+	})
+	return live
+}
+
+// ReportLeaks writes every tracked resource that hasn't been deleted to w, along with where it
+// was created. Returns the number of resources that were reported.
+func ReportLeaks(w io.Writer) int {
+	live := LiveResources()
+	for _, r := range live {
+		fmt.Fprintf(w, "glitch: leaked %s, created at:\n%s\n", r.Kind, r.Stack)
+	}
+	return len(live)
+}
+
+// Adds a resource to the registry, returns its id or 0 if leak tracking is disabled
+func trackResource(kind string) uint64 {
+	leaks.Lock()
+	defer leaks.Unlock()
+
+	if !leaks.enabled {
+		return 0
+	}
+	leaks.lastID++
+	leaks.live[leaks.lastID] = LiveResource{
+		Kind:  kind,
+		Stack: string(debug.Stack()),
+		id:    leaks.lastID,
+	}
+	return leaks.lastID
+}
+
+// Removes a resource from the registry, either because it was deleted or because glitch owns
+// it for the whole program (like the default shaders)
+func untrackResource(id uint64) {
+	if id == 0 {
+		return
+	}
+
+	leaks.Lock()
+	defer leaks.Unlock()
+	delete(leaks.live, id)
+}
@@ -0,0 +1,176 @@
+//go:build headless
+
+package glitch_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/unitoftime/flow/glm"
+	"github.com/unitoftime/glitch"
+	"github.com/unitoftime/glitch/glitchtest"
+)
+
+// Returns the number of tracked resources of a kind that haven't been deleted
+func liveResources(kind string) int {
+	n := 0
+	for _, r := range glitch.LiveResources() {
+		if r.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+func TestPostProcessDeletesFrames(t *testing.T) {
+	glitch.SetLeakTracking(true)
+	defer glitch.SetLeakTracking(false)
+
+	source := glitch.NewFrame(glm.R(0, 0, 16, 16), false)
+	defer source.Delete()
+	target := glitch.NewFrame(glm.R(0, 0, 16, 16), false)
+	defer target.Delete()
+
+	blur, err := glitch.NewBlurPasses(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := glitch.NewPostProcess(blur...) // Two passes, so one intermediate frame
+	post.Draw(target, source)
+	if n := liveResources("frame"); n != 3 {
+		t.Fatalf("got %d live frames after drawing, want 3", n)
+	}
+
+	err = post.SetFormat(glitch.TextureFormatRGBA16F)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post.Draw(target, source)
+	if n := liveResources("frame"); n != 3 {
+		t.Errorf("got %d live frames after changing the format, want 3", n)
+	}
+
+	bigger := glitch.NewFrame(glm.R(0, 0, 32, 32), false)
+	defer bigger.Delete()
+	post.Draw(bigger, source)
+	if n := liveResources("frame"); n != 4 {
+		t.Errorf("got %d live frames after changing the bounds, want 4", n)
+	}
+
+	post.Delete()
+	if n := liveResources("frame"); n != 3 {
+		t.Errorf("got %d live frames after deleting, want 3", n)
+	}
+}
+
+func TestRepackDeletesPages(t *testing.T) {
+	glitch.SetLeakTracking(true)
+	defer glitch.SetLeakTracking(false)
+
+	packer := glitch.NewTexturePacker(glitch.TexturePackerConfig{Width: 16, Height: 16})
+	sizes := []image.Point{{8, 12}, {12, 6}, {16, 6}, {2, 6}, {16, 8}, {16, 2}, {14, 16}, {12, 2}, {8, 16}, {12, 10}}
+	for _, size := range sizes {
+		_, err := packer.Add(image.NewRGBA(image.Rectangle{Max: size}))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := len(packer.Pages())
+	live := liveResources("texture")
+
+	err := packer.Repack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := len(packer.Pages())
+	if after >= before {
+		t.Fatalf("repacking went from %d to %d pages, want fewer", before, after)
+	}
+	if n := liveResources("texture"); n != live-(before-after) {
+		t.Errorf("got %d live textures after repacking, want %d", n, live-(before-after))
+	}
+	for _, page := range packer.Pages() {
+		page.Delete()
+	}
+}
+
+func TestRepackSpriteUVs(t *testing.T) {
+	packer := glitch.NewTexturePacker(glitch.TexturePackerConfig{Width: 16, Height: 16, Padding: 1})
+	colors := []color.RGBA{
+		{255, 0, 0, 255},
+		{0, 255, 0, 255},
+		{0, 0, 255, 255},
+		{255, 255, 0, 255},
+		{0, 255, 255, 255},
+	}
+	sizes := []image.Point{{3, 5}, {12, 6}, {6, 12}, {2, 2}, {14, 4}}
+	sprites := make([]*glitch.Sprite, len(colors))
+	for i, c := range colors {
+		img := image.NewRGBA(image.Rectangle{Max: sizes[i]})
+		for j := 0; j < len(img.Pix); j += 4 {
+			copy(img.Pix[j:], []uint8{c.R, c.G, c.B, c.A})
+		}
+		var err error
+		sprites[i], err = packer.Add(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := packer.Repack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, page := range packer.Pages() {
+			page.Delete()
+		}
+	}()
+
+	// Each sprite still draws only its own image
+	for i, sprite := range sprites {
+		scene := glitchtest.NewScene(8, 8)
+		sprite.RectDraw(scene.Sorter, scene.Bounds())
+		img := scene.Render()
+		for _, p := range []image.Point{{0, 0}, {7, 0}, {0, 7}, {7, 7}, {4, 4}} {
+			assertPixel(t, img, p.X, p.Y, colors[i])
+		}
+	}
+}
+
+func TestTilemapDeletesChunks(t *testing.T) {
+	glitch.SetLeakTracking(true)
+	defer glitch.SetLeakTracking(false)
+
+	camera := glitch.NewCameraOrtho()
+	camera.SetOrtho2D(glm.R(0, 0, 64, 64))
+	camera.SetView2D(0, 0, 1, 1)
+	frame := glitch.NewFrame(glm.R(0, 0, 64, 64), false)
+	defer frame.Delete()
+
+	tilemap := glitch.NewTilemap(glitch.WhiteTexture(), glitch.Vec2{8, 8}, [][]int{
+		{0, 0, 0, 0},
+		{0, 0, 0, 0},
+	})
+	tilemap.SetChunkSize(2) // Two chunks
+	tilemap.Draw(frame, camera, glitch.Mat4Ident)
+	live := liveResources("vertex buffer")
+
+	// Changing a tile rebuilds its chunk, which replaces the chunk's buffer
+	tilemap.SetTile(0, 0, glitch.NoTile)
+	tilemap.Draw(frame, camera, glitch.Mat4Ident)
+	if n := liveResources("vertex buffer"); n != live {
+		t.Errorf("got %d live vertex buffers after rebuilding a chunk, want %d", n, live)
+	}
+
+	tilemap.SetChunkSize(4) // One chunk
+	tilemap.Draw(frame, camera, glitch.Mat4Ident)
+	if n := liveResources("vertex buffer"); n != live-1 {
+		t.Errorf("got %d live vertex buffers after changing the chunk size, want %d", n, live-1)
+	}
+
+	tilemap.Delete()
+	if n := liveResources("vertex buffer"); n != live-2 {
+		t.Errorf("got %d live vertex buffers after deleting, want %d", n, live-2)
+	}
+}
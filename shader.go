@@ -2,6 +2,8 @@ package glitch
 
 import (
 	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/unitoftime/glitch/internal/gl"
 	"github.com/unitoftime/glitch/internal/mainthread"
@@ -13,9 +15,16 @@ import (
 type Shader struct {
 	program         gl.Program
 	uniformLocs     map[string]Uniform
-	uniformsMat4    map[string]glMat4 // All uniforms that are glMat4
-	uniforms        map[string]any    // All other uniforms
-	attrFmt         shaders.VertexFormat
+	uniformsMat4    map[string]glMat4    // All uniforms that are glMat4
+	uniforms        map[string]any       // All other uniforms
+	samplers        map[string]int       // The texture unit that each sampler uniform reads from
+	attrFmt         shaders.VertexFormat // The per vertex attributes
+	instanceFmt     shaders.VertexFormat // The per instance attributes, only used by InstancedMesh
+	uniformFmt      shaders.UniformFormat
+	autoUniforms    bool     // True if uniformFmt is filled in from the program, see reflect
+	warnings        []string // The problems that reflect found that don't stop the shader from working
+	vertexSource    string
+	fragmentSource  string
 	tmpBuffers      []any
 	tmpFloat32Slice []float32
 	mainthreadBind  func()
@@ -27,6 +36,11 @@ type Shader struct {
 
 	// This is for manually buffering a mesh into a fixed vertex buffer
 	bufferData *bufferData
+
+	files *shaderFiles // The files that the shader is reloaded from, nil if it was created from sources
+
+	deleted bool
+	leakID  uint64 // The id in the leak registry, see SetLeakTracking
 }
 
 type Uniform struct {
@@ -35,26 +49,99 @@ type Uniform struct {
 	loc gl.Uniform
 }
 
-func NewShader(cfg shaders.ShaderConfig) (*Shader, error) {
+// NewShader compiles a shader from a config. After linking, the formats are checked against the
+// attributes and uniforms that the shader actually uses and an error is returned if any names
+// or types don't match. If the UniformFormat is nil then it is filled in from the shader.
+// Both sources are run through a shaders.Preprocessor first, with includes read from shaders.FS.
+// The defines are added to both sources, so one config can be compiled into several
+// permutations. The sources keep their own #version line, unless cfg.Translate is set, which
+// rewrites them for the dialect of the context. A GLSL 100 context (WebGL1) can't compile any
+// other version, so there the sources are always translated, which lets the GLSL 3.00 ES
+// shaders in the shaders package run on every platform.
+func NewShader(cfg shaders.ShaderConfig, defines ...shaders.Define) (*Shader, error) {
+	dialect := ShaderDialect()
+	p := shaders.Preprocessor{
+		FS:          shaders.FS,
+		Dialect:     dialect,
+		Defines:     defines,
+		KeepVersion: !cfg.Translate && dialect != shaders.GLSL100,
+	}
+	var err error
+	cfg.VertexShader, err = p.Process(shaders.VertexStage, cfg.VertexShader)
+	if err != nil {
+		return nil, err
+	}
+	cfg.FragmentShader, err = p.Process(shaders.FragmentStage, cfg.FragmentShader)
+	if err != nil {
+		return nil, err
+	}
 	return NewShaderExt(cfg.VertexShader, cfg.FragmentShader, cfg.VertexFormat, cfg.UniformFormat)
 }
 
+// The dialect of the context, which is only queried once because GetString is a round trip to
+// the mainthread. NewWindow queries it as soon as the context is created.
+var shaderDialect struct {
+	once    sync.Once
+	dialect shaders.Dialect
+}
+
+// ShaderDialect returns the version of GLSL that the context compiles, for preprocessing shaders
+// (see shaders.Preprocessor)
+func ShaderDialect() shaders.Dialect {
+	shaderDialect.once.Do(func() {
+		var version string
+		mainthread.Call(func() {
+			version = gl.GetString(gl.SHADING_LANGUAGE_VERSION)
+		})
+		switch {
+		case strings.Contains(version, "GLSL ES 1"):
+			shaderDialect.dialect = shaders.GLSL100 // WebGL1 and OpenGL ES 2.0
+		case strings.Contains(version, "GLSL ES"):
+			shaderDialect.dialect = shaders.GLSL300ES
+		default:
+			shaderDialect.dialect = shaders.GLSL330
+		}
+	})
+	return shaderDialect.dialect
+}
+
 func NewShaderExt(vertexSource, fragmentSource string, attrFmt shaders.VertexFormat, uniformFmt shaders.UniformFormat) (*Shader, error) {
+	vertexFmt := make(shaders.VertexFormat, 0, len(attrFmt))
+	instanceFmt := make(shaders.VertexFormat, 0)
+	for _, attr := range attrFmt {
+		if attr.Swizzle.Instanced() {
+			instanceFmt = append(instanceFmt, attr)
+		} else {
+			vertexFmt = append(vertexFmt, attr)
+		}
+	}
+
 	shader := &Shader{
 		uniformLocs:     make(map[string]Uniform),
 		uniformsMat4:    make(map[string]glMat4),
 		uniforms:        make(map[string]any),
-		attrFmt:         attrFmt,
+		samplers:        make(map[string]int),
+		attrFmt:         vertexFmt,
+		instanceFmt:     instanceFmt,
+		uniformFmt:      uniformFmt,
+		autoUniforms:    uniformFmt == nil,
+		vertexSource:    vertexSource,
+		fragmentSource:  fragmentSource,
 		tmpFloat32Slice: make([]float32, 0),
 	}
 	err := mainthread.CallErr(func() error {
 		var err error
-		shader.program, err = createProgram(vertexSource, fragmentSource)
+		shader.program, err = createProgram(vertexSource, fragmentSource, nil)
+		if err != nil {
+			return err
+		}
+		shader.uniformFmt, err = shader.reflect(shader.program, vertexSource, fragmentSource)
 		if err != nil {
+			gl.DeleteProgram(shader.program)
 			return err
 		}
 
-		for _, uniform := range uniformFmt {
+		for _, uniform := range shader.uniformFmt {
 			loc := gl.GetUniformLocation(shader.program, uniform.Name)
 			shader.uniformLocs[uniform.Name] = Uniform{uniform.Name, loc}
 			// fmt.Println("Found uniform: ", uniform)
@@ -77,13 +164,16 @@ func NewShaderExt(vertexSource, fragmentSource string, attrFmt shaders.VertexFor
 	// Loop through and set all matrices to identity matrices
 	// shader.Bind()
 	setShader(shader)
-	for _, uniform := range uniformFmt {
+	for _, uniform := range shader.uniformFmt {
 		// TODO handle other matrices
 		if uniform.Type == shaders.AttrMat4 {
 			// Setting uniform
 			shader.setUniformMat4(uniform.Name, glMat4Ident)
 		}
 	}
+	// The shader stays bound, so put back the camera that setShader applied
+	shader.setUniformMat4("projection", global.camera.Projection)
+	shader.setUniformMat4("view", global.camera.View)
 
 	shader.tmpBuffers = make([]any, len(shader.attrFmt))
 	for i, attr := range shader.attrFmt {
@@ -94,9 +184,39 @@ func NewShaderExt(vertexSource, fragmentSource string, attrFmt shaders.VertexFor
 	defaultBatchSize := 1024 * 8 // 10000 // TODO: arbitrary. make configurable
 	shader.pool = NewBufferPool(shader, defaultBatchSize)
 
+	shader.leakID = trackResource("shader")
 	return shader, nil
 }
 
+// Delete frees the shader program and its batching buffers on the GPU. The deletion is queued
+// onto the mainthread, after anything that has already been drawn with the shader. The shader
+// can't be used afterwards, and neither can any buffers created with BufferMesh.
+func (s *Shader) Delete() {
+	if s.deleted {
+		return
+	}
+	s.deleted = true
+	untrackResource(s.leakID)
+
+	if global.shader == s {
+		global.flush()
+		global.shader = nil
+		global.material = Material{} // The material refers to the shader, so it must be bound again
+	}
+	delete(global.shaderCache, s)
+	unwatchShader(s)
+
+	for _, b := range s.pool.buffers {
+		b.Delete()
+	}
+	s.pool.buffers = nil
+	s.pool.Clear()
+
+	mainthread.CallNonBlock(func() {
+		gl.DeleteProgram(s.program)
+	})
+}
+
 // func (s *Shader) Bind() {
 // 	mainthread.Call(s.mainthreadBind)
 // }
@@ -107,7 +227,8 @@ func NewShaderExt(vertexSource, fragmentSource string, attrFmt shaders.VertexFor
 // 	})
 // }
 
-func createProgram(vertexSrc, fragmentSrc string) (gl.Program, error) {
+// Compiles and links a program. Attribs optionally fixes the locations of attributes by name.
+func createProgram(vertexSrc, fragmentSrc string, attribs map[string]gl.Attrib) (gl.Program, error) {
 	program := gl.CreateProgram()
 	if !program.Valid() {
 		return gl.Program{}, fmt.Errorf("failed createProgram")
@@ -125,6 +246,9 @@ func createProgram(vertexSrc, fragmentSrc string) (gl.Program, error) {
 
 	gl.AttachShader(program, vertexShader)
 	gl.AttachShader(program, fragmentShader)
+	for name, loc := range attribs {
+		gl.BindAttribLocation(program, loc, name)
+	}
 	gl.LinkProgram(program)
 
 	// Flag shaders for deletion when program is unlinked.
@@ -147,16 +271,30 @@ func loadShader(shaderType gl.Enum, src string) (gl.Shader, error) {
 	gl.CompileShader(shader)
 	if gl.GetShaderi(shader, gl.COMPILE_STATUS) == gl.FALSE {
 		defer gl.DeleteShader(shader)
-		shaderLog := gl.GetShaderInfoLog(shader)
-		versionString := gl.GetString(gl.VERSION) + " ||| " + gl.GetString(gl.SHADING_LANGUAGE_VERSION)
-		return gl.Shader{}, fmt.Errorf("loadShader: %s (Version: %s)", shaderLog, versionString)
+		return gl.Shader{}, &shaderCompileError{
+			shaderType: shaderType,
+			log:        gl.GetShaderInfoLog(shader),
+			version:    gl.GetString(gl.VERSION) + " ||| " + gl.GetString(gl.SHADING_LANGUAGE_VERSION),
+		}
 	}
 	return shader, nil
 }
 
+// The error of a shader stage that failed to compile
+type shaderCompileError struct {
+	shaderType gl.Enum // gl.VERTEX_SHADER or gl.FRAGMENT_SHADER
+	log        string  // The info log of the compiler
+	version    string
+}
+
+func (e *shaderCompileError) Error() string {
+	return fmt.Sprintf("loadShader: %s (Version: %s)", e.log, e.version)
+}
+
 // Note: This was me playing around with a way to reduce the amount of memory allocations
 var tmpUniformSetter uniformSetter
 var tmpUniformSetterMat4 uniformSetterMat4
+var tmpSamplerSetter samplerSetter
 
 func init() {
 	tmpUniformSetter.FUNC = func() {
@@ -165,6 +303,9 @@ func init() {
 	tmpUniformSetterMat4.FUNC = func() {
 		tmpUniformSetterMat4.Func()
 	}
+	tmpSamplerSetter.FUNC = func() {
+		tmpSamplerSetter.Func()
+	}
 }
 
 // TODO: Should I use a comparable here? and just force uniforms to be comparable?
@@ -221,6 +362,31 @@ func (s *Shader) setUniformMat4(name string, value glMat4) bool {
 	return true // TODO - wrong
 }
 
+// Returns true if the uniform is a part of the shader's uniform format
+func (s *Shader) hasUniform(name string) bool {
+	for _, uniform := range s.uniformFmt {
+		if uniform.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Points the sampler uniform at a texture unit. The shader must already be bound
+func (s *Shader) setSampler(name string, unit int) {
+	currentUnit, ok := s.samplers[name]
+	if ok && currentUnit == unit {
+		return // Skip because the sampler already reads from this unit
+	}
+	s.samplers[name] = unit
+
+	tmpSamplerSetter.shader = s
+	tmpSamplerSetter.name = name
+	tmpSamplerSetter.unit = unit
+
+	mainthread.Call(tmpSamplerSetter.FUNC)
+}
+
 type uniformSetter struct {
 	shader *Shader
 	name   string
@@ -310,6 +476,26 @@ func (u *uniformSetterMat4) Func() {
 	gl.UniformMatrix4fv(uniform.loc, []float32(u.value[:]))
 }
 
+type samplerSetter struct {
+	shader *Shader
+	name   string
+	unit   int
+	FUNC   func()
+}
+
+// Samplers aren't a part of the uniform format, so their locations are looked up the first
+// time that they are set. If the shader doesn't have the sampler then the location is invalid
+// and setting it does nothing.
+func (u *samplerSetter) Func() {
+	uniform, ok := u.shader.uniformLocs[u.name]
+	if !ok {
+		uniform = Uniform{u.name, gl.GetUniformLocation(u.shader.program, u.name)}
+		u.shader.uniformLocs[u.name] = uniform
+	}
+
+	gl.Uniform1i(uniform.loc, u.unit)
+}
+
 //--------------------------------------------------------------------------------
 
 // TODO: This would be much better if VertexBuffer was more of just a pointer to all of the GPU objects, and the cached data was maintained separately
@@ -334,6 +520,7 @@ func (shader *Shader) BufferMesh(mesh *Mesh) *VertexBuffer {
 	success := vertBuf.Reserve(mesh.indices, numVerts, shader.tmpBuffers)
 	if !success {
 		// If there isn't enough room, then resize up to the new required size
+		vertBuf.Delete()
 		data := NewSubBuffers(shader, numVerts, numIndices)
 		shader.bufferData = &data
 		vertBuf = NewVertexBuffer2(shader, *shader.bufferData)
@@ -0,0 +1,248 @@
+package glitch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/unitoftime/glitch/internal/gl"
+	"github.com/unitoftime/glitch/internal/mainthread"
+	"github.com/unitoftime/glitch/shaders"
+)
+
+// The source files of a shader that is hot reloaded, see NewShaderFiles
+type shaderFiles struct {
+	vertexPath, fragmentPath string
+	vertexMod, fragmentMod   time.Time // The modification times of the files when they were last read
+	onReload                 []func(error)
+}
+
+// The shaders that are checked for changed files on every Window.Update
+var watchedShaders []*Shader
+
+// NewShaderFiles creates a shader from GLSL files on disk, for development. The files are
+// watched, and when either one changes the shader is recompiled on the next Window.Update.
+// If the new sources fail to compile then the shader keeps running the old program, and the
+// error is reported (see Shader.OnReload), with the compiler's line numbers mapped to the files.
+// Ship with embedded sources and NewShader instead, the files can't be read on every platform.
+func NewShaderFiles(vertexPath, fragmentPath string, attrFmt shaders.VertexFormat, uniformFmt shaders.UniformFormat) (*Shader, error) {
+	files := &shaderFiles{
+		vertexPath:   vertexPath,
+		fragmentPath: fragmentPath,
+	}
+	vertexSource, fragmentSource, err := files.read()
+	if err != nil {
+		return nil, err
+	}
+
+	shader, err := NewShaderExt(vertexSource, fragmentSource, attrFmt, uniformFmt)
+	if err != nil {
+		return nil, files.mapError(err)
+	}
+	shader.files = files
+	watchedShaders = append(watchedShaders, shader)
+	return shader, nil
+}
+
+// OnReload adds a callback that is called every time the shader is reloaded after its files
+// changed, with the error if the new sources failed to compile or nil if the reload worked.
+// If a shader doesn't have any callbacks then failed reloads are printed to stderr.
+func (s *Shader) OnReload(fn func(error)) {
+	if s.files == nil {
+		return
+	}
+	s.files.onReload = append(s.files.onReload, fn)
+}
+
+// Reload reads the shader's files and recompiles it now, whether or not they have changed.
+// If compiling fails then the shader keeps running the old program.
+func (s *Shader) Reload() error {
+	if s.files == nil {
+		return fmt.Errorf("shader files: the shader wasn't created from files")
+	}
+
+	vertexSource, fragmentSource, err := s.files.read()
+	if err != nil {
+		return err
+	}
+	err = s.recompile(vertexSource, fragmentSource)
+	if err != nil {
+		return s.files.mapError(err)
+	}
+	s.vertexSource = vertexSource
+	s.fragmentSource = fragmentSource
+	return nil
+}
+
+// Swaps the program of the shader for a new one compiled from the sources. The uniforms that
+// were set on the old program are set again on the new one.
+func (s *Shader) recompile(vertexSource, fragmentSource string) error {
+	if global.shader == s {
+		global.flush() // Draw anything that is batched with the old program
+	}
+
+	err := mainthread.CallErr(func() error {
+		// The vertex buffers were set up with the attribute locations of the old program
+		attribs := make(map[string]gl.Attrib)
+		for _, attr := range slices.Concat(s.attrFmt, s.instanceFmt) {
+			loc := gl.GetAttribLocation(s.program, attr.Name)
+			if loc.Value >= 0 {
+				attribs[attr.Name] = loc
+			}
+		}
+
+		program, err := createProgram(vertexSource, fragmentSource, attribs)
+		if err != nil {
+			return err
+		}
+		uniformFmt, err := s.reflect(program, vertexSource, fragmentSource)
+		if err != nil {
+			gl.DeleteProgram(program)
+			return err
+		}
+		gl.DeleteProgram(s.program)
+		s.program = program
+		s.uniformFmt = uniformFmt
+		for _, uniform := range uniformFmt {
+			s.uniformLocs[uniform.Name] = Uniform{} // Uniforms that were added to the sources get a location below
+		}
+		for name := range s.uniformLocs {
+			s.uniformLocs[name] = Uniform{name, gl.GetUniformLocation(program, name)}
+		}
+		gl.UseProgram(program)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	mat4s, uniforms, samplers := s.uniformsMat4, s.uniforms, s.samplers
+	s.uniformsMat4 = make(map[string]glMat4)
+	s.uniforms = make(map[string]any)
+	s.samplers = make(map[string]int)
+	for name, value := range mat4s {
+		s.setUniformMat4(name, value)
+	}
+	for name, value := range uniforms {
+		s.setUniform(name, value)
+	}
+	for name, unit := range samplers {
+		s.setSampler(name, unit)
+	}
+
+	// The new program was bound to set the uniforms, so put back the shader that is in use
+	if global.shader != nil && global.shader != s {
+		mainthread.Call(global.shader.mainthreadBind)
+	}
+	return nil
+}
+
+// Reloads the watched shaders whose files have changed
+func updateShaderFiles() {
+	for _, s := range watchedShaders {
+		if !s.files.changed() {
+			continue
+		}
+
+		err := s.Reload()
+		if len(s.files.onReload) == 0 {
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			continue
+		}
+		for _, fn := range s.files.onReload {
+			fn(err)
+		}
+	}
+}
+
+// Stops watching a shader's files
+func unwatchShader(s *Shader) {
+	if s.files == nil {
+		return
+	}
+	watchedShaders = slices.DeleteFunc(watchedShaders, func(w *Shader) bool {
+		return w == s
+	})
+}
+
+// Returns true if either file has been modified since it was last read
+func (f *shaderFiles) changed() bool {
+	vertex, err := os.Stat(f.vertexPath)
+	if err != nil {
+		return false // The file may be in the middle of being saved, check again next time
+	}
+	fragment, err := os.Stat(f.fragmentPath)
+	if err != nil {
+		return false
+	}
+	return !vertex.ModTime().Equal(f.vertexMod) || !fragment.ModTime().Equal(f.fragmentMod)
+}
+
+func (f *shaderFiles) read() (string, string, error) {
+	vertex, vertexMod, err := readShaderFile(f.vertexPath)
+	if err != nil {
+		return "", "", err
+	}
+	fragment, fragmentMod, err := readShaderFile(f.fragmentPath)
+	if err != nil {
+		return "", "", err
+	}
+	f.vertexMod = vertexMod
+	f.fragmentMod = fragmentMod
+	return vertex, fragment, nil
+}
+
+func readShaderFile(path string) (string, time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("shader files: %w", err)
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("shader files: %w", err)
+	}
+	return string(src), info.ModTime(), nil
+}
+
+// Matches the locations in the info logs of the common GLSL compilers, where the first number
+// is the index of the source string (always 0) and the second is the line:
+//
+//	0:12(5): error: ...          (Mesa)
+//	0(12) : error C0000: ...     (Nvidia)
+//	ERROR: 0:12: '...' : ...     (AMD, Apple and ANGLE)
+var glslLogLocation = regexp.MustCompile(`^((?:ERROR|WARNING): )?\d+(?::(\d+)(?:\(\d+\))?|\((\d+)\)) ?: ?(.*)$`)
+
+// Rewrites the locations in the log of a compile error to point at the shader's files
+func (f *shaderFiles) mapError(err error) error {
+	var compileErr *shaderCompileError
+	if !errors.As(err, &compileErr) {
+		return fmt.Errorf("shader files: %s, %s: %w", f.vertexPath, f.fragmentPath, err)
+	}
+
+	path := f.fragmentPath
+	if compileErr.shaderType == gl.VERTEX_SHADER {
+		path = f.vertexPath
+	}
+
+	lines := strings.Split(strings.TrimSpace(compileErr.log), "\n")
+	for i, line := range lines {
+		m := glslLogLocation.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		severity := strings.ToLower(strings.TrimSuffix(m[1], ": "))
+		lineNumber := m[2] + m[3]
+		if severity != "" {
+			lines[i] = fmt.Sprintf("%s:%s: %s: %s", path, lineNumber, severity, m[4])
+		} else {
+			lines[i] = fmt.Sprintf("%s:%s: %s", path, lineNumber, m[4])
+		}
+	}
+	return fmt.Errorf("shader files: %s failed to compile (Version: %s):\n%s", path, compileErr.version, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,305 @@
+//go:build !js && headless
+// +build !js,headless
+
+package glitch
+
+import (
+	"math"
+
+	"github.com/unitoftime/glitch/internal/gl"
+	"github.com/unitoftime/glitch/shaders"
+)
+
+// The headless backend can't run GLSL, so these are the Go equivalents of the built in
+// shaders. Custom shaders need their own kernels registered before they are linked.
+
+var spriteVertexKernel = &gl.VertexKernel{
+	Varyings: []gl.Varying{{"ourColor", 4}, {"TexCoord", 2}},
+	Main: func(s *gl.KernelState, out []float32) [4]float32 {
+		pos := s.Attrib("positionIn")
+		color := s.Attrib("colorIn")
+		texCoord := s.Attrib("texCoordIn")
+		copy(out[0:4], color[:])
+		copy(out[4:6], texCoord[:2])
+
+		mvp := kernelMul(kernelMul(s.Mat4("projection"), s.Mat4("view")), s.Mat4("model"))
+		return kernelTransform(mvp, [4]float32{pos[0], pos[1], pos[2], 1})
+	},
+}
+
+var spriteInstancedVertexKernel = &gl.VertexKernel{
+	Varyings: []gl.Varying{{"ourColor", 4}, {"TexCoord", 2}},
+	Main: func(s *gl.KernelState, out []float32) [4]float32 {
+		pos := s.Attrib("positionIn")
+		color := s.Attrib("colorIn")
+		texCoord := s.Attrib("texCoordIn")
+		instanceColor := s.Attrib("instanceColorIn")
+		mask := s.Vec4("mask")
+		for i := range 4 {
+			out[i] = color[i] * instanceColor[i] * mask[i]
+		}
+		copy(out[4:6], texCoord[:2])
+
+		mvp := kernelMul(kernelMul(kernelMul(s.Mat4("projection"), s.Mat4("view")), s.Mat4("model")), s.Mat4Attrib("instanceModelIn"))
+		return kernelTransform(mvp, [4]float32{pos[0], pos[1], pos[2], 1})
+	},
+}
+
+var spriteFragmentKernel = &gl.FragmentKernel{
+	Varyings: []gl.Varying{{"ourColor", 4}, {"TexCoord", 2}},
+	Main: func(s *gl.KernelState, in []float32) ([4]float32, bool) {
+		tex := s.Texture("texture1", in[4], in[5])
+		if tex[3] == 0 {
+			return tex, false
+		}
+
+		color := [4]float32{in[0], in[1], in[2], in[3]}
+		if color[3] > 1.1 {
+			return color, true // Silhouette mode
+		}
+		return [4]float32{color[0] * tex[0], color[1] * tex[1], color[2] * tex[2], color[3] * tex[3]}, true
+	},
+}
+
+var pixelFragmentKernel = &gl.FragmentKernel{
+	Varyings: []gl.Varying{{"ourColor", 4}, {"TexCoord", 2}},
+	Main: func(s *gl.KernelState, in []float32) ([4]float32, bool) {
+		tex := s.Texture("texture1", in[4], in[5])
+		if tex[3] == 0 {
+			return tex, false
+		}
+		return [4]float32{in[0] * tex[0], in[1] * tex[1], in[2] * tex[2], in[3] * tex[3]}, true
+	},
+}
+
+var meshVertexKernel = &gl.VertexKernel{
+	Varyings: []gl.Varying{{"FragPos", 3}, {"Normal", 3}, {"TexCoord", 2}},
+	Main: func(s *gl.KernelState, out []float32) [4]float32 {
+		pos := s.Attrib("positionIn")
+		normal := s.Attrib("normalIn")
+		texCoord := s.Attrib("texCoordIn")
+
+		model := s.Mat4("model")
+		worldPos := kernelTransform(model, [4]float32{pos[0], pos[1], pos[2], 1})
+		copy(out[0:3], worldPos[:3])
+		n := kernelNormalMatrix(model, [3]float32{normal[0], normal[1], normal[2]})
+		copy(out[3:6], n[:])
+		copy(out[6:8], texCoord[:2])
+
+		return kernelTransform(kernelMul(s.Mat4("projection"), s.Mat4("view")), worldPos)
+	},
+}
+
+var flatFragmentKernel = &gl.FragmentKernel{
+	Varyings: []gl.Varying{{"FragPos", 3}, {"Normal", 3}},
+	Main: func(s *gl.KernelState, in []float32) ([4]float32, bool) {
+		fragPos := [3]float32{in[0], in[1], in[2]}
+		norm := kernelNormalize([3]float32{in[3], in[4], in[5]})
+
+		shininess := float32(0)
+		if v := s.Uniform("material.shininess"); len(v) > 0 {
+			shininess = v[0]
+		}
+
+		// ambient
+		ambient := kernelScale(s.Vec3("dirLight.ambient"), s.Vec3("material.ambient"))
+
+		// diffuse
+		lightDir := kernelNormalize(s.Vec3("dirLight.direction"))
+		diff := max(kernelDot(norm, lightDir), 0)
+		diffuse := kernelScale(s.Vec3("dirLight.diffuse"), kernelMulScalar(s.Vec3("material.diffuse"), diff))
+
+		// specular
+		viewPos := s.Vec3("viewPos")
+		viewDir := kernelNormalize([3]float32{viewPos[0] - fragPos[0], viewPos[1] - fragPos[1], viewPos[2] - fragPos[2]})
+		// reflect(-lightDir, norm) = -lightDir - 2 * dot(norm, -lightDir) * norm
+		d := -kernelDot(norm, lightDir)
+		var reflectDir [3]float32
+		for i := range reflectDir {
+			reflectDir[i] = -lightDir[i] - 2*d*norm[i]
+		}
+		spec := float32(math.Pow(float64(max(kernelDot(viewDir, reflectDir), 0)), float64(shininess)))
+		specular := kernelScale(s.Vec3("dirLight.specular"), kernelMulScalar(s.Vec3("material.specular"), spec))
+
+		return [4]float32{
+			ambient[0] + diffuse[0] + specular[0],
+			ambient[1] + diffuse[1] + specular[1],
+			ambient[2] + diffuse[2] + specular[2],
+			1,
+		}, true
+	},
+}
+
+var blurWeights = [5]float32{0.227027, 0.1945946, 0.1216216, 0.054054, 0.016216}
+
+var blurFragmentKernel = &gl.FragmentKernel{
+	Varyings: []gl.Varying{{"ourColor", 4}, {"TexCoord", 2}},
+	Main: func(s *gl.KernelState, in []float32) ([4]float32, bool) {
+		texel := s.Uniform("texelSize")
+		dir := s.Uniform("direction")
+		du, dv := dir[0]*texel[0], dir[1]*texel[1]
+
+		var color [4]float32
+		tex := s.Texture("texture1", in[4], in[5])
+		for c := range color {
+			color[c] = tex[c] * blurWeights[0]
+		}
+		for i := 1; i < len(blurWeights); i++ {
+			f := float32(i)
+			a := s.Texture("texture1", in[4]+du*f, in[5]+dv*f)
+			b := s.Texture("texture1", in[4]-du*f, in[5]-dv*f)
+			for c := range color {
+				color[c] += (a[c] + b[c]) * blurWeights[i]
+			}
+		}
+		return color, true
+	},
+}
+
+var bloomThresholdFragmentKernel = &gl.FragmentKernel{
+	Varyings: []gl.Varying{{"ourColor", 4}, {"TexCoord", 2}},
+	Main: func(s *gl.KernelState, in []float32) ([4]float32, bool) {
+		color := s.Texture("texture1", in[4], in[5])
+		brightness := max(color[0], color[1], color[2])
+		contribution := max(brightness-s.Uniform("threshold")[0], 0) / max(brightness, 0.0001)
+		for c := range color {
+			color[c] *= contribution
+		}
+		return color, true
+	},
+}
+
+var bloomCombineFragmentKernel = &gl.FragmentKernel{
+	Varyings: []gl.Varying{{"ourColor", 4}, {"TexCoord", 2}},
+	Main: func(s *gl.KernelState, in []float32) ([4]float32, bool) {
+		bloom := s.Texture("texture1", in[4], in[5])
+		source := s.Texture("sourceTexture", in[4], in[5])
+		intensity := s.Uniform("intensity")[0]
+		var color [4]float32
+		for c := range color {
+			color[c] = min(source[c]+bloom[c]*intensity, 1)
+		}
+		return color, true
+	},
+}
+
+var colorGradeFragmentKernel = &gl.FragmentKernel{
+	Varyings: []gl.Varying{{"ourColor", 4}, {"TexCoord", 2}},
+	Main: func(s *gl.KernelState, in []float32) ([4]float32, bool) {
+		color := s.Texture("texture1", in[4], in[5])
+		if color[3] <= 0 {
+			return color, true
+		}
+		size := s.Uniform("lutSize")[0]
+		intensity := s.Uniform("intensity")[0]
+
+		var straight [3]float32
+		for c := range straight {
+			straight[c] = color[c] / color[3]
+		}
+		blue := straight[2] * (size - 1)
+		slice0 := float32(math.Floor(float64(blue)))
+		slice1 := min(slice0+1, size-1)
+		x := (straight[0]*(size-1) + 0.5) / (size * size)
+		y := (straight[1]*(size-1) + 0.5) / size
+		a := s.Texture("lutTexture", x+slice0/size, y)
+		b := s.Texture("lutTexture", x+slice1/size, y)
+		for c := range straight {
+			graded := a[c] + (b[c]-a[c])*(blue-slice0)
+			color[c] = (straight[c] + (graded-straight[c])*intensity) * color[3]
+		}
+		return color, true
+	},
+}
+
+func init() {
+	gl.RegisterVertexKernel(shaders.SpriteVertexShader, spriteVertexKernel)
+	gl.RegisterVertexKernel(shaders.PixelArtVert, spriteVertexKernel)
+	gl.RegisterVertexKernel(shaders.SpriteInstancedVertexShader, spriteInstancedVertexKernel)
+	gl.RegisterFragmentKernel(shaders.SpriteFragmentShader, spriteFragmentKernel)
+	gl.RegisterFragmentKernel(shaders.PixelArtFrag, pixelFragmentKernel)
+
+	gl.RegisterVertexKernel(shaders.DiffuseVertexShader, meshVertexKernel)
+	gl.RegisterFragmentKernel(shaders.DiffuseFragmentShader, flatFragmentKernel)
+
+	gl.RegisterFragmentKernel(shaders.BlurFragmentShader, blurFragmentKernel)
+	gl.RegisterFragmentKernel(shaders.BloomThresholdFragmentShader, bloomThresholdFragmentKernel)
+	gl.RegisterFragmentKernel(shaders.BloomCombineFragmentShader, bloomCombineFragmentKernel)
+	gl.RegisterFragmentKernel(shaders.ColorGradeFragmentShader, colorGradeFragmentKernel)
+}
+
+// --------------------------------------------------------------------------------
+// Column major matrix helpers for the kernels
+
+func kernelMul(a, b [16]float32) [16]float32 {
+	var m [16]float32
+	for col := 0; col < 4; col++ {
+		for row := 0; row < 4; row++ {
+			var sum float32
+			for k := 0; k < 4; k++ {
+				sum += a[k*4+row] * b[col*4+k]
+			}
+			m[col*4+row] = sum
+		}
+	}
+	return m
+}
+
+func kernelTransform(m [16]float32, v [4]float32) [4]float32 {
+	var ret [4]float32
+	for row := 0; row < 4; row++ {
+		ret[row] = m[row]*v[0] + m[4+row]*v[1] + m[8+row]*v[2] + m[12+row]*v[3]
+	}
+	return ret
+}
+
+// kernelNormalMatrix returns mat3(transpose(inverse(model))) * n
+func kernelNormalMatrix(model [16]float32, n [3]float32) [3]float32 {
+	// Upper left 3x3, a[row][col]
+	var a [3][3]float64
+	for col := 0; col < 3; col++ {
+		for row := 0; row < 3; row++ {
+			a[row][col] = float64(model[col*4+row])
+		}
+	}
+
+	// The inverse transpose is the cofactor matrix divided by the determinant
+	var cof [3][3]float64
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			r0, r1 := (row+1)%3, (row+2)%3
+			c0, c1 := (col+1)%3, (col+2)%3
+			cof[row][col] = a[r0][c0]*a[r1][c1] - a[r0][c1]*a[r1][c0]
+		}
+	}
+	det := a[0][0]*cof[0][0] + a[0][1]*cof[0][1] + a[0][2]*cof[0][2]
+	if det == 0 {
+		return [3]float32{}
+	}
+
+	var ret [3]float32
+	for row := 0; row < 3; row++ {
+		ret[row] = float32((cof[row][0]*float64(n[0]) + cof[row][1]*float64(n[1]) + cof[row][2]*float64(n[2])) / det)
+	}
+	return ret
+}
+
+func kernelDot(a, b [3]float32) float32 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func kernelNormalize(v [3]float32) [3]float32 {
+	l := float32(math.Sqrt(float64(kernelDot(v, v))))
+	if l == 0 {
+		return v
+	}
+	return [3]float32{v[0] / l, v[1] / l, v[2] / l}
+}
+
+func kernelScale(a, b [3]float32) [3]float32 {
+	return [3]float32{a[0] * b[0], a[1] * b[1], a[2] * b[2]}
+}
+
+func kernelMulScalar(a [3]float32, s float32) [3]float32 {
+	return [3]float32{a[0] * s, a[1] * s, a[2] * s}
+}
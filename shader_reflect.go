@@ -0,0 +1,201 @@
+package glitch
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/unitoftime/glitch/internal/gl"
+	"github.com/unitoftime/glitch/shaders"
+)
+
+// An attribute or uniform that is active in a linked program, as reported by the driver
+type activeVar struct {
+	name string
+	ty   gl.Enum
+	size int // Array length
+}
+
+// The GL types that can be a part of a VertexFormat or UniformFormat
+var attrTypes = map[gl.Enum]shaders.AttrType{
+	gl.INT:          shaders.AttrInt,
+	gl.FLOAT:        shaders.AttrFloat,
+	gl.FLOAT_VEC2:   shaders.AttrVec2,
+	gl.FLOAT_VEC3:   shaders.AttrVec3,
+	gl.FLOAT_VEC4:   shaders.AttrVec4,
+	gl.FLOAT_MAT2:   shaders.AttrMat2,
+	gl.FLOAT_MAT2x3: shaders.AttrMat23,
+	gl.FLOAT_MAT2x4: shaders.AttrMat24,
+	gl.FLOAT_MAT3:   shaders.AttrMat3,
+	gl.FLOAT_MAT3x2: shaders.AttrMat32,
+	gl.FLOAT_MAT3x4: shaders.AttrMat34,
+	gl.FLOAT_MAT4:   shaders.AttrMat4,
+	gl.FLOAT_MAT4x2: shaders.AttrMat42,
+	gl.FLOAT_MAT4x3: shaders.AttrMat43,
+}
+
+// The GLSL names of the other GL types, for errors
+var glslTypeNames = map[gl.Enum]string{
+	gl.INT_VEC2:     "ivec2",
+	gl.INT_VEC3:     "ivec3",
+	gl.INT_VEC4:     "ivec4",
+	gl.BOOL:         "bool",
+	gl.BOOL_VEC2:    "bvec2",
+	gl.BOOL_VEC3:    "bvec3",
+	gl.BOOL_VEC4:    "bvec4",
+	gl.SAMPLER_2D:   "sampler2D",
+	gl.SAMPLER_CUBE: "samplerCube",
+}
+
+func glslTypeName(ty gl.Enum) string {
+	if t, ok := attrTypes[ty]; ok {
+		return t.String()
+	}
+	if name, ok := glslTypeNames[ty]; ok {
+		return name
+	}
+	return fmt.Sprintf("type 0x%X", uint32(ty))
+}
+
+// Returns the active attributes of a linked program. Must be called on the mainthread
+func activeAttribs(program gl.Program) []activeVar {
+	n := gl.GetProgrami(program, gl.ACTIVE_ATTRIBUTES)
+	ret := make([]activeVar, 0, n)
+	for i := range n {
+		name, size, ty := gl.GetActiveAttrib(program, uint32(i))
+		if strings.HasPrefix(name, "gl_") {
+			continue // Some drivers list builtins, like gl_VertexID
+		}
+		ret = append(ret, activeVar{name, ty, size})
+	}
+	return ret
+}
+
+// Returns the active uniforms of a linked program. Must be called on the mainthread
+func activeUniforms(program gl.Program) []activeVar {
+	n := gl.GetProgrami(program, gl.ACTIVE_UNIFORMS)
+	ret := make([]activeVar, 0, n)
+	for i := range n {
+		name, size, ty := gl.GetActiveUniform(program, uint32(i))
+		if strings.HasPrefix(name, "gl_") {
+			continue
+		}
+		name = strings.TrimSuffix(name, "[0]") // Arrays are listed by their first element
+		ret = append(ret, activeVar{name, ty, size})
+	}
+	return ret
+}
+
+var identifierRegexp = regexp.MustCompile(`[A-Za-z_]\w*`)
+
+// Returns the set of identifiers in a shader source
+func identifiers(src string) map[string]bool {
+	ret := make(map[string]bool)
+	for _, ident := range identifierRegexp.FindAllString(src, -1) {
+		ret[ident] = true
+	}
+	return ret
+}
+
+// Returns true if the variable of a name is one of the identifiers of a source. For struct
+// members and array elements (eg "light.color" or "lights[1].color") only the variable has to
+// appear.
+func declaredIn(idents map[string]bool, name string) bool {
+	if i := strings.IndexAny(name, ".["); i >= 0 {
+		name = name[:i]
+	}
+	return idents[name]
+}
+
+func findActive(vars []activeVar, name string) (activeVar, bool) {
+	i := slices.IndexFunc(vars, func(v activeVar) bool {
+		return v.name == name
+	})
+	if i < 0 {
+		return activeVar{}, false
+	}
+	return vars[i], true
+}
+
+// Warnings returns the problems with the shader's formats that don't stop it from working, like
+// uniforms that the compiler removed because they're unused. They're found when the shader is
+// linked, so they're worth checking in debug builds when a shader doesn't draw what you expect.
+func (s *Shader) Warnings() []string {
+	return s.warnings
+}
+
+// Checks the formats of the shader against the attributes and uniforms that are active in the
+// linked program, so that a typo in a format fails here instead of silently drawing nothing.
+// Names that aren't in the sources and types that don't match are errors. Entries that are
+// declared but unused (so the compiler removed them) and active uniforms that can't be set
+// because they aren't in the format are stored as warnings (see Shader.Warnings).
+// If the shader was created without a uniform format then it is filled in from the program.
+// Returns the uniform format. Must be called on the mainthread.
+func (s *Shader) reflect(program gl.Program, vertexSource, fragmentSource string) (shaders.UniformFormat, error) {
+	problems := make([]string, 0)
+	warnings := make([]string, 0)
+	vertexIdents := identifiers(vertexSource)
+	fragmentIdents := identifiers(fragmentSource)
+
+	attribs := activeAttribs(program)
+	attrFmt := slices.Concat(s.attrFmt, s.instanceFmt)
+	for _, attr := range attrFmt {
+		active, ok := findActive(attribs, attr.Name)
+		if !ok {
+			if declaredIn(vertexIdents, attr.Name) {
+				warnings = append(warnings, fmt.Sprintf("attribute %q is unused, so the compiler removed it", attr.Name))
+			} else {
+				problems = append(problems, fmt.Sprintf("attribute %q isn't in the vertex shader", attr.Name))
+			}
+			continue
+		}
+		if ty, ok := attrTypes[active.ty]; !ok || ty != attr.Type {
+			problems = append(problems, fmt.Sprintf("attribute %q is a %s in the vertex shader, but a %s in the VertexFormat", attr.Name, glslTypeName(active.ty), attr.Type))
+		}
+	}
+	for _, active := range attribs {
+		if !slices.ContainsFunc(attrFmt, func(attr shaders.VertexAttr) bool { return attr.Name == active.name }) {
+			warnings = append(warnings, fmt.Sprintf("attribute %q isn't in the VertexFormat, so it is never filled", active.name))
+		}
+	}
+
+	uniforms := activeUniforms(program)
+	uniformFmt := s.uniformFmt
+	if s.autoUniforms {
+		uniformFmt = make(shaders.UniformFormat, 0, len(uniforms))
+		for _, active := range uniforms {
+			if ty, ok := attrTypes[active.ty]; ok {
+				uniformFmt = append(uniformFmt, shaders.Attr{Name: active.name, Type: ty})
+			}
+		}
+	}
+	for _, uniform := range uniformFmt {
+		active, ok := findActive(uniforms, uniform.Name)
+		if !ok {
+			if declaredIn(vertexIdents, uniform.Name) || declaredIn(fragmentIdents, uniform.Name) {
+				warnings = append(warnings, fmt.Sprintf("uniform %q is unused, so the compiler removed it", uniform.Name))
+			} else {
+				problems = append(problems, fmt.Sprintf("uniform %q isn't in the shader", uniform.Name))
+			}
+			continue
+		}
+		if ty, ok := attrTypes[active.ty]; !ok || ty != uniform.Type {
+			problems = append(problems, fmt.Sprintf("uniform %q is a %s in the shader, but a %s in the UniformFormat", uniform.Name, glslTypeName(active.ty), uniform.Type))
+		}
+	}
+	for _, active := range uniforms {
+		if active.ty == gl.SAMPLER_2D || active.ty == gl.SAMPLER_CUBE {
+			continue // Samplers aren't a part of the format, they're set by the material's textures
+		}
+		if !slices.ContainsFunc(uniformFmt, func(uniform shaders.Attr) bool { return uniform.Name == active.name }) {
+			warnings = append(warnings, fmt.Sprintf("uniform %q isn't in the UniformFormat, so it can't be set", active.name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("shader reflection: the formats don't match the shader:\n%s", strings.Join(problems, "\n"))
+	}
+	s.warnings = warnings
+	return uniformFmt, nil
+}
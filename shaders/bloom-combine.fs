@@ -0,0 +1,24 @@
+#version 300 es
+
+// Required for webgl
+#ifdef GL_ES
+precision highp float;
+#endif
+
+// Adds the blurred bright parts (texture1) back on top of the original image (sourceTexture)
+
+out vec4 FragColor;
+
+in vec4 ourColor;
+in vec2 TexCoord;
+
+uniform sampler2D texture1;
+uniform sampler2D sourceTexture;
+uniform float intensity;
+
+void main()
+{
+  vec4 bloom = texture(texture1, TexCoord) * intensity;
+  vec4 source = texture(sourceTexture, TexCoord);
+  FragColor = min(source + bloom, vec4(1.0));
+}
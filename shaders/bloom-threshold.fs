@@ -0,0 +1,24 @@
+#version 300 es
+
+// Required for webgl
+#ifdef GL_ES
+precision highp float;
+#endif
+
+// Keeps the part of each color that is brighter than the threshold
+
+out vec4 FragColor;
+
+in vec4 ourColor;
+in vec2 TexCoord;
+
+uniform sampler2D texture1;
+uniform float threshold;
+
+void main()
+{
+  vec4 color = texture(texture1, TexCoord);
+  float brightness = max(color.r, max(color.g, color.b));
+  float contribution = max(brightness - threshold, 0.0) / max(brightness, 0.0001);
+  FragColor = color * contribution;
+}
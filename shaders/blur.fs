@@ -0,0 +1,36 @@
+#version 300 es
+
+// Required for webgl
+#ifdef GL_ES
+precision highp float;
+#endif
+
+// One direction of a separable gaussian blur
+
+out vec4 FragColor;
+
+in vec4 ourColor;
+in vec2 TexCoord;
+
+uniform sampler2D texture1;
+uniform vec2 texelSize;
+uniform vec2 direction; // The blur axis, scaled by the spread in texels
+
+// Samples the texels offset away on either side, scaled by their gaussian weight. The weights
+// are written out in main rather than stored in an array because GLSL 100 (WebGL1) doesn't
+// have array constructors.
+vec4 taps(vec2 offset, float weight)
+{
+  return (texture(texture1, TexCoord + offset) + texture(texture1, TexCoord - offset)) * weight;
+}
+
+void main()
+{
+  vec2 offset = direction * texelSize;
+  vec4 color = texture(texture1, TexCoord) * 0.227027;
+  color += taps(offset, 0.1945946);
+  color += taps(2.0 * offset, 0.1216216);
+  color += taps(3.0 * offset, 0.054054);
+  color += taps(4.0 * offset, 0.016216);
+  FragColor = color;
+}
@@ -0,0 +1,44 @@
+#version 300 es
+
+// Required for webgl
+#ifdef GL_ES
+precision highp float;
+#endif
+
+// Remaps colors through a lookup table. The table is a strip of lutSize slices, each
+// lutSize x lutSize, where red increases to the right inside a slice, green increases down and
+// blue selects the slice.
+
+out vec4 FragColor;
+
+in vec4 ourColor;
+in vec2 TexCoord;
+
+uniform sampler2D texture1;
+uniform sampler2D lutTexture;
+uniform float lutSize;
+uniform float intensity;
+
+vec3 lookup(vec3 color)
+{
+  float blue = color.b * (lutSize - 1.0);
+  float slice0 = floor(blue);
+  float slice1 = min(slice0 + 1.0, lutSize - 1.0);
+
+  float x = (color.r * (lutSize - 1.0) + 0.5) / (lutSize * lutSize);
+  float y = (color.g * (lutSize - 1.0) + 0.5) / lutSize;
+  vec3 a = texture(lutTexture, vec2(x + slice0 / lutSize, y)).rgb;
+  vec3 b = texture(lutTexture, vec2(x + slice1 / lutSize, y)).rgb;
+  return mix(a, b, blue - slice0);
+}
+
+void main()
+{
+  vec4 color = texture(texture1, TexCoord);
+  if (color.a > 0.0) {
+    // Colors are premultiplied, so grade the straight color
+    vec3 straight = color.rgb / color.a;
+    color.rgb = mix(straight, lookup(straight), intensity) * color.a;
+  }
+  FragColor = color;
+}
@@ -0,0 +1,353 @@
+package shaders
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FS holds the sources of the shaders in this package, so they can be included by other shaders
+// (see Preprocessor)
+//
+//go:embed *.vs *.fs
+var FS embed.FS
+
+// Stage is the stage of the pipeline that a shader source is for
+type Stage uint8
+
+const (
+	VertexStage Stage = iota
+	FragmentStage
+)
+
+// Dialect is a version of GLSL that the Preprocessor can write a shader for
+type Dialect uint8
+
+const (
+	GLSL330   Dialect = iota // Desktop OpenGL 3.3 core: #version 330 core
+	GLSL300ES                // OpenGL ES 3.0 and WebGL2: #version 300 es
+	GLSL100                  // OpenGL ES 2.0 and WebGL1: #version 100
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case GLSL330:
+		return "330 core"
+	case GLSL300ES:
+		return "300 es"
+	case GLSL100:
+		return "100"
+	default:
+		return fmt.Sprintf("Dialect(%d)", uint8(d))
+	}
+}
+
+// Define is a macro that the Preprocessor adds to the top of a shader, as #define Name Value.
+// The value can be empty, for flags that are only checked with #ifdef.
+type Define struct {
+	Name, Value string
+}
+
+// Preprocessor builds variants of a shader from one source. Sources are written in GLSL 3.00 ES,
+// like the rest of the shaders in this package, and the preprocessor:
+//   - Replaces #include "path" lines with the file, read from FS. Paths are relative to the
+//     file that includes them, or to the root of FS for the shader itself. Includes are
+//     resolved before the GLSL preprocessor runs, so an #include inside of an #ifdef is always
+//     read, and files that are included twice must have include guards.
+//   - Adds Defines to the top of the shader.
+//   - Replaces the #version line and any precision statements with the header of Dialect, and
+//     moves #extension directives (from the shader and its includes) up to just after #version,
+//     because they have to come before any other code. They're moved out of any #ifdef too.
+//     For GLSL100 the body is also translated: layout qualifiers are removed, in/out become
+//     attribute/varying, the fragment output becomes gl_FragColor and texture() becomes
+//     texture2D(), so only shaders that sample 2D textures can be translated. An error is
+//     returned for anything else that GLSL 1.00 doesn't have and can't be rewritten (eg array
+//     constructors, integer types or functions like textureSize).
+//
+// If KeepVersion is set then the shader keeps its own #version line and precision statements
+// and isn't translated, so only the includes, defines and #extension directives are processed.
+//
+// The processed shader has #line directives so the compiler's errors still point at the
+// original lines. Source string 0 is the shader itself, and the included files are numbered
+// from 1 in the order that they're first included.
+type Preprocessor struct {
+	FS          fs.FS // Where includes are read from, nil if the shaders don't include anything
+	Dialect     Dialect
+	Defines     []Define
+	KeepVersion bool
+
+	ownLine bool // Set if a #line directive numbers its own line, rather than the next one
+}
+
+var (
+	versionRegexp   = regexp.MustCompile(`^\s*#\s*version\b\s*(\d*)`)
+	extensionRegexp = regexp.MustCompile(`^\s*#\s*extension\b`)
+	includeRegexp   = regexp.MustCompile(`^\s*#\s*include\s+["<]([^">]+)[">]\s*$`)
+	precisionRegexp = regexp.MustCompile(`^\s*precision\s+\w+\s+\w+\s*;\s*$`)
+	defineRegexp    = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+
+	layoutRegexp    = regexp.MustCompile(`layout\s*\([^)]*\)\s*`)
+	inRegexp        = regexp.MustCompile(`^(\s*)in\s+`)
+	outRegexp       = regexp.MustCompile(`^(\s*)out\s+`)
+	fragOutRegexp   = regexp.MustCompile(`^\s*out\s+(?:(?:lowp|mediump|highp)\s+)?vec4\s+(\w+)\s*;`)
+	textureRegexp   = regexp.MustCompile(`\btexture\s*\(`)
+	directiveRegexp = regexp.MustCompile(`^\s*#`)
+	lineRegexp      = regexp.MustCompile(`^\s*#\s*line\s+(\d+)(?:\s+(\d+))?`)
+)
+
+// The parts of GLSL 3.00 ES that GLSL 1.00 doesn't have, and that translate100 can't rewrite.
+// If what is empty then the match itself is reported.
+var untranslatable100 = []struct {
+	regexp *regexp.Regexp
+	what   string
+}{
+	{regexp.MustCompile(`\b\w+\s*\[\s*\w*\s*\]\s*\(`), "array constructors"},
+	{regexp.MustCompile(`\b(?:texelFetch|textureSize|textureLod|textureGrad|textureOffset|textureProj|transpose|inverse|determinant|round|trunc)\b`), ""},
+	{regexp.MustCompile(`\b(?:uint|uvec[234]|[iu]sampler\w+|sampler3D|sampler2DArray|sampler2DShadow|samplerCube)\b`), ""},
+	{regexp.MustCompile(`\b(?:flat|switch)\b`), ""},
+}
+
+// Process preprocesses the source of a shader for a stage
+func (p Preprocessor) Process(stage Stage, src string) (string, error) {
+	for _, define := range p.Defines {
+		if !defineRegexp.MatchString(define.Name) {
+			return "", fmt.Errorf("shaders: invalid define name %q", define.Name)
+		}
+	}
+
+	header := fmt.Sprintf("#version %s", p.Dialect)
+	p.ownLine = p.Dialect == GLSL100
+	if p.KeepVersion {
+		header = ""
+		p.ownLine = true // GLSL before 3.30, which is the default without a #version line
+		for _, line := range strings.Split(src, "\n") {
+			if m := versionRegexp.FindStringSubmatch(line); m != nil {
+				header = strings.TrimSpace(line)
+				version, _ := strconv.Atoi(m[1])
+				p.ownLine = version < 300 // GLSL ES 1.00 and desktop GLSL before 3.30
+				break
+			}
+		}
+	}
+
+	files := []string{""}
+	extensions := make([]string, 0)
+	body, err := p.include(&files, &extensions, nil, "", src)
+	if err != nil {
+		return "", err
+	}
+
+	if p.Dialect == GLSL100 && !p.KeepVersion {
+		body, err = translate100(stage, files, body)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var b strings.Builder
+	if header != "" {
+		b.WriteString(header)
+		b.WriteByte('\n')
+	}
+	for _, extension := range extensions {
+		b.WriteString(extension)
+		b.WriteByte('\n')
+	}
+	if stage == FragmentStage && !p.KeepVersion {
+		switch p.Dialect {
+		case GLSL300ES:
+			b.WriteString("precision highp float;\n")
+		case GLSL100:
+			b.WriteString("#ifdef GL_FRAGMENT_PRECISION_HIGH\nprecision highp float;\n#else\nprecision mediump float;\n#endif\n")
+		}
+	}
+	for _, define := range p.Defines {
+		if define.Value == "" {
+			fmt.Fprintf(&b, "#define %s\n", define.Name)
+		} else {
+			fmt.Fprintf(&b, "#define %s %s\n", define.Name, define.Value)
+		}
+	}
+	b.WriteString(p.line(1, 0))
+	b.WriteString(body)
+	return b.String(), nil
+}
+
+// Returns a #line directive that numbers the next line as line of the source string
+func (p Preprocessor) line(line, source int) string {
+	if p.ownLine {
+		line-- // The directive sets the number of its own line
+	}
+	return fmt.Sprintf("#line %d %d\n", line, source)
+}
+
+// Returns the source with its includes expanded, and the #version line and precision
+// statements (unless KeepVersion is set) blanked out. The #extension directives are blanked out too and appended to
+// extensions instead. Stack is the files that are currently being included, for cycles.
+func (p Preprocessor) include(files, extensions *[]string, stack []string, name, src string) (string, error) {
+	source := slices.Index(*files, name)
+
+	var b strings.Builder
+	lines := strings.Split(src, "\n")
+	for i, line := range lines {
+		if extensionRegexp.MatchString(line) {
+			extension := strings.TrimSpace(line)
+			if !slices.Contains(*extensions, extension) {
+				*extensions = append(*extensions, extension)
+			}
+			line = ""
+		}
+		if versionRegexp.MatchString(line) || (!p.KeepVersion && precisionRegexp.MatchString(line)) {
+			line = "" // Blanked so that the line numbers stay the same
+		}
+
+		m := includeRegexp.FindStringSubmatch(line)
+		if m == nil {
+			b.WriteString(line)
+			if i < len(lines)-1 {
+				b.WriteByte('\n')
+			}
+			continue
+		}
+
+		included := path.Clean(m[1])
+		if name != "" {
+			included = path.Join(path.Dir(name), m[1])
+		}
+		if p.FS == nil {
+			return "", fmt.Errorf("shaders: %s:%d: can't include %q without a Preprocessor.FS", displayName(name), i+1, m[1])
+		}
+		if included == name || slices.Contains(stack, included) {
+			return "", fmt.Errorf("shaders: %s:%d: %q includes itself", displayName(name), i+1, included)
+		}
+		data, err := fs.ReadFile(p.FS, included)
+		if err != nil {
+			return "", fmt.Errorf("shaders: %s:%d: %w", displayName(name), i+1, err)
+		}
+		if !slices.Contains(*files, included) {
+			*files = append(*files, included)
+		}
+		expanded, err := p.include(files, extensions, append(stack, name), included, string(data))
+		if err != nil {
+			return "", err
+		}
+
+		b.WriteString(p.line(1, slices.Index(*files, included)))
+		b.WriteString(strings.TrimSuffix(expanded, "\n"))
+		b.WriteByte('\n')
+		b.WriteString(p.line(i+2, source))
+	}
+	return b.String(), nil
+}
+
+func displayName(name string) string {
+	if name == "" {
+		return "<shader>"
+	}
+	return name
+}
+
+// Translates the body of a GLSL 3.00 ES shader to GLSL 1.00. Files are the names of the source
+// strings, for errors.
+func translate100(stage Stage, files []string, body string) (string, error) {
+	lines := strings.Split(body, "\n")
+
+	fragOut := ""
+	lineNum, source := 1, 0 // Where the current line came from, tracked through the #line directives
+	inComment := false
+	for i, line := range lines {
+		if m := lineRegexp.FindStringSubmatch(line); m != nil {
+			lineNum, _ = strconv.Atoi(m[1])
+			lineNum++ // In GLSL ES 1.00 the directive sets the number of its own line
+			if m[2] != "" {
+				source, _ = strconv.Atoi(m[2])
+			}
+			continue
+		}
+		if directiveRegexp.MatchString(line) {
+			lineNum++
+			continue
+		}
+
+		code := stripComments(line, &inComment)
+		for _, u := range untranslatable100 {
+			match := u.regexp.FindString(code)
+			if match == "" {
+				continue
+			}
+			what := u.what
+			if what == "" {
+				what = match
+			}
+			name := ""
+			if source < len(files) {
+				name = files[source]
+			}
+			return "", fmt.Errorf("shaders: %s:%d: GLSL 100 doesn't have %s", displayName(name), lineNum, what)
+		}
+		lineNum++
+
+		line = layoutRegexp.ReplaceAllString(line, "")
+		if stage == VertexStage {
+			line = inRegexp.ReplaceAllString(line, "${1}attribute ")
+			line = outRegexp.ReplaceAllString(line, "${1}varying ")
+		} else {
+			if m := fragOutRegexp.FindStringSubmatch(line); m != nil {
+				if fragOut != "" {
+					return "", fmt.Errorf("shaders: GLSL 100 only has one fragment output, but the shader writes to %s and %s", fragOut, m[1])
+				}
+				fragOut = m[1]
+				line = ""
+			}
+			line = inRegexp.ReplaceAllString(line, "${1}varying ")
+		}
+		lines[i] = textureRegexp.ReplaceAllString(line, "texture2D(")
+	}
+
+	if fragOut != "" {
+		fragOutName := regexp.MustCompile(`\b` + regexp.QuoteMeta(fragOut) + `\b`)
+		for i, line := range lines {
+			if !directiveRegexp.MatchString(line) {
+				lines[i] = fragOutName.ReplaceAllString(line, "gl_FragColor")
+			}
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Returns the line without its comments. InComment is whether the line starts inside of a
+// block comment, and it's updated for the next line.
+func stripComments(line string, inComment *bool) string {
+	var b strings.Builder
+	for len(line) > 0 {
+		if *inComment {
+			end := strings.Index(line, "*/")
+			if end < 0 {
+				return b.String()
+			}
+			line = line[end+2:]
+			*inComment = false
+			continue
+		}
+		lineComment := strings.Index(line, "//")
+		blockComment := strings.Index(line, "/*")
+		if lineComment >= 0 && (blockComment < 0 || lineComment < blockComment) {
+			b.WriteString(line[:lineComment])
+			return b.String()
+		}
+		if blockComment < 0 {
+			b.WriteString(line)
+			return b.String()
+		}
+		b.WriteString(line[:blockComment])
+		b.WriteByte(' ')
+		line = line[blockComment+2:]
+		*inComment = true
+	}
+	return b.String()
+}
@@ -0,0 +1,167 @@
+package shaders
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestPreprocessDialects(t *testing.T) {
+	src := "#version 300 es\nprecision highp float;\nout vec4 FragColor;\nin vec2 TexCoord;\nuniform sampler2D texture1;\nvoid main() {\n  FragColor = texture(texture1, TexCoord);\n}"
+
+	tests := []struct {
+		name string
+		p    Preprocessor
+		want string
+	}{
+		{"330", Preprocessor{Dialect: GLSL330}, "#version 330 core\n#line 1 0\n\n\nout vec4 FragColor;\nin vec2 TexCoord;\nuniform sampler2D texture1;\nvoid main() {\n  FragColor = texture(texture1, TexCoord);\n}"},
+		{"300es", Preprocessor{Dialect: GLSL300ES}, "#version 300 es\nprecision highp float;\n#line 1 0\n\n\nout vec4 FragColor;\nin vec2 TexCoord;\nuniform sampler2D texture1;\nvoid main() {\n  FragColor = texture(texture1, TexCoord);\n}"},
+		{"100", Preprocessor{Dialect: GLSL100}, "#version 100\n#ifdef GL_FRAGMENT_PRECISION_HIGH\nprecision highp float;\n#else\nprecision mediump float;\n#endif\n#line 0 0\n\n\n\nvarying vec2 TexCoord;\nuniform sampler2D texture1;\nvoid main() {\n  gl_FragColor = texture2D(texture1, TexCoord);\n}"},
+		{"keep version", Preprocessor{Dialect: GLSL330, KeepVersion: true}, "#version 300 es\n#line 1 0\n\nprecision highp float;\nout vec4 FragColor;\nin vec2 TexCoord;\nuniform sampler2D texture1;\nvoid main() {\n  FragColor = texture(texture1, TexCoord);\n}"},
+		{"defines", Preprocessor{Dialect: GLSL330, Defines: []Define{{"FLAG", ""}, {"COUNT", "4"}}}, "#version 330 core\n#define FLAG\n#define COUNT 4\n#line 1 0\n\n\nout vec4 FragColor;\nin vec2 TexCoord;\nuniform sampler2D texture1;\nvoid main() {\n  FragColor = texture(texture1, TexCoord);\n}"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.p.Process(FragmentStage, src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPreprocessVertex100(t *testing.T) {
+	src := "#version 300 es\nlayout (location = 0) in vec3 positionIn;\nout vec2 TexCoord;\nvoid main() {\n  gl_Position = vec4(positionIn, 1.0);\n}"
+	got, err := Preprocessor{Dialect: GLSL100}.Process(VertexStage, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "#version 100\n#line 0 0\n\nattribute vec3 positionIn;\nvarying vec2 TexCoord;\nvoid main() {\n  gl_Position = vec4(positionIn, 1.0);\n}"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPreprocessIncludes(t *testing.T) {
+	files := fstest.MapFS{
+		"lib/color.glsl": {Data: []byte("#extension GL_OES_standard_derivatives : enable\n#include \"math.glsl\"\nvec4 tint(vec4 c) { return c; }")},
+		"lib/math.glsl":  {Data: []byte("float sq(float x) { return x * x; }")},
+		"cycle/a.glsl":   {Data: []byte("#include \"b.glsl\"")},
+		"cycle/b.glsl":   {Data: []byte("#include \"a.glsl\"")},
+	}
+	src := "#version 300 es\n#extension GL_OES_standard_derivatives : enable\n#include \"lib/color.glsl\"\nvoid main() {}"
+
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		// The included files are numbered in the order they're first included, and the line after
+		// each include goes back to the including file
+		{GLSL330, "#version 330 core\n#extension GL_OES_standard_derivatives : enable\n#line 1 0\n\n\n#line 1 1\n\n#line 1 2\nfloat sq(float x) { return x * x; }\n#line 3 1\nvec4 tint(vec4 c) { return c; }\n#line 4 0\nvoid main() {}"},
+		// In GLSL 100 the directive numbers its own line
+		{GLSL100, "#version 100\n#extension GL_OES_standard_derivatives : enable\n#line 0 0\n\n\n#line 0 1\n\n#line 0 2\nfloat sq(float x) { return x * x; }\n#line 2 1\nvec4 tint(vec4 c) { return c; }\n#line 3 0\nvoid main() {}"},
+	}
+	for _, test := range tests {
+		t.Run(test.dialect.String(), func(t *testing.T) {
+			got, err := Preprocessor{FS: files, Dialect: test.dialect}.Process(VertexStage, src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+
+	errors := []struct {
+		name string
+		p    Preprocessor
+		src  string
+		want string
+	}{
+		{"cycle", Preprocessor{FS: files}, "#include \"cycle/a.glsl\"", `cycle/b.glsl:1: "cycle/a.glsl" includes itself`},
+		{"self", Preprocessor{FS: fstest.MapFS{"a.glsl": {Data: []byte("#include \"a.glsl\"")}}}, "#include \"a.glsl\"", `a.glsl:1: "a.glsl" includes itself`},
+		{"missing file", Preprocessor{FS: files}, "\n#include \"missing.glsl\"", "<shader>:2: open missing.glsl"},
+		{"no fs", Preprocessor{}, "#include \"lib/math.glsl\"", "<shader>:1: can't include"},
+		{"define", Preprocessor{Defines: []Define{{"1BAD", ""}}}, "", `invalid define name "1BAD"`},
+	}
+	for _, test := range errors {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.p.Process(VertexStage, test.src)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestPreprocessUntranslatable100(t *testing.T) {
+	files := fstest.MapFS{
+		"lib.glsl": {Data: []byte("float f() { return 1.0; }\nuint g() { return 1u; }")},
+	}
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"array constructor", "const float w[2] = float[](0.5, 0.5);", "<shader>:1: GLSL 100 doesn't have array constructors"},
+		{"function", "void main() {\n  vec2 s = vec2(textureSize(t, 0));\n}", "<shader>:2: GLSL 100 doesn't have textureSize"},
+		{"integer type", "\n\nuvec2 v;", "<shader>:3: GLSL 100 doesn't have uvec2"},
+		{"sampler", "uniform samplerCube sky;", "<shader>:1: GLSL 100 doesn't have samplerCube"},
+		{"qualifier", "flat in vec4 color;", "<shader>:1: GLSL 100 doesn't have flat"},
+		{"switch", "void main() {\n  switch (1) {}\n}", "<shader>:2: GLSL 100 doesn't have switch"},
+		{"include", "void main() {}\n#include \"lib.glsl\"", "lib.glsl:2: GLSL 100 doesn't have uint"},
+		{"two outputs", "out vec4 a;\nout vec4 b;", "GLSL 100 only has one fragment output"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Preprocessor{FS: files, Dialect: GLSL100}.Process(FragmentStage, test.src)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one containing %q", err, test.want)
+			}
+
+			// Only GLSL 100 needs the translation
+			_, err = Preprocessor{FS: files, Dialect: GLSL300ES}.Process(FragmentStage, test.src)
+			if err != nil {
+				t.Errorf("got error %v for GLSL 300 es", err)
+			}
+		})
+	}
+
+	// Comments aren't code
+	src := "// float[](1.0)\n/* textureSize\n uint */ void main() {}"
+	_, err := Preprocessor{Dialect: GLSL100}.Process(FragmentStage, src)
+	if err != nil {
+		t.Errorf("got error %v for constructs inside of comments", err)
+	}
+}
+
+// Every shipped shader can be translated, except the ones that are documented not to be
+func TestPreprocessShippedShaders(t *testing.T) {
+	untranslatable := map[string]bool{"mesh.vs": true, "msdf.fs": true, "subPixel.fs": true}
+
+	names, err := fs.Glob(FS, "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		src, err := fs.ReadFile(FS, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stage := FragmentStage
+		if strings.HasSuffix(name, ".vs") {
+			stage = VertexStage
+		}
+		for _, dialect := range []Dialect{GLSL330, GLSL300ES, GLSL100} {
+			_, err := Preprocessor{FS: FS, Dialect: dialect}.Process(stage, string(src))
+			if (err != nil) != (dialect == GLSL100 && untranslatable[name]) {
+				t.Errorf("%s for %s: got error %v", name, dialect, err)
+			}
+		}
+	}
+}
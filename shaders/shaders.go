@@ -9,6 +9,10 @@ type ShaderConfig struct {
 	VertexShader, FragmentShader string
 	VertexFormat                 VertexFormat
 	UniformFormat                UniformFormat
+
+	// If set, the sources are rewritten for the GLSL dialect of the context, rather than
+	// keeping their own #version (see glitch.NewShader and Preprocessor)
+	Translate bool
 }
 
 // TODO - right now we only support floats (for simplicity)
@@ -82,6 +86,42 @@ const (
 	AttrMat43
 )
 
+// Returns the GLSL name of the type
+func (t AttrType) String() string {
+	switch t {
+	case AttrInt:
+		return "int"
+	case AttrFloat:
+		return "float"
+	case AttrVec2:
+		return "vec2"
+	case AttrVec3:
+		return "vec3"
+	case AttrVec4:
+		return "vec4"
+	case AttrMat2:
+		return "mat2"
+	case AttrMat23:
+		return "mat2x3"
+	case AttrMat24:
+		return "mat2x4"
+	case AttrMat3:
+		return "mat3"
+	case AttrMat32:
+		return "mat3x2"
+	case AttrMat34:
+		return "mat3x4"
+	case AttrMat4:
+		return "mat4"
+	case AttrMat42:
+		return "mat4x2"
+	case AttrMat43:
+		return "mat4x3"
+	default:
+		return fmt.Sprintf("AttrType(%d)", uint8(t))
+	}
+}
+
 // This type is used to define how generic meshes map into specific shader buffers
 type SwizzleType uint8
 
@@ -96,8 +136,19 @@ const (
 	ColorRGBA
 	TexCoordXY
 	// TexCoordXYZ // Is this a thing?
+
+	// Instance attributes: These advance once per instance instead of once per vertex, and are
+	// only filled for instanced draws (see glitch.InstancedMesh)
+	InstanceMatrix    // The model matrix of the instance (AttrMat4)
+	InstanceColorRGBA // The color mask of the instance (AttrVec4)
+	InstanceCustom    // User data, passed per instance to InstancedMesh.Add (AttrFloat to AttrVec4)
 )
 
+// Returns true if the attribute advances once per instance, rather than once per vertex
+func (s SwizzleType) Instanced() bool {
+	return s >= InstanceMatrix
+}
+
 func VertexAttribute(name string, Type AttrType, swizzle SwizzleType) VertexAttr {
 	return VertexAttr{
 		Attr: Attr{
@@ -108,27 +159,8 @@ func VertexAttribute(name string, Type AttrType, swizzle SwizzleType) VertexAttr
 	}
 }
 
-// Commented out: This was for webgl1 mode
-// //go:embed sprite_100.vs
-// var SpriteVertexShaderWebGL1 string;
-
-// //go:embed sprite_100.fs
-// var SpriteFragmentShaderWebGL1 string;
-
-// var SpriteShader = ShaderConfig{
-// 	VertexShader: SpriteVertexShaderWebGL1,
-// 	FragmentShader: SpriteFragmentShaderWebGL1,
-// 	VertexFormat: VertexFormat{
-// 		VertexAttribute("positionIn", AttrVec2, PositionXY),
-// 		VertexAttribute("colorIn", AttrVec4, ColorRGBA),
-// 		VertexAttribute("texCoordIn", AttrVec2, TexCoordXY),
-// 	},
-// 	UniformFormat: UniformFormat{
-// 		Attr{"projection", AttrMat4},
-// 		Attr{"view", AttrMat4},
-// 	},
-// }
-
+// The sprite shader is also the WebGL1 sprite shader, NewShader translates it to GLSL 100 there
+//
 //go:embed sprite.vs
 var SpriteVertexShader string
 
@@ -151,9 +183,34 @@ var SpriteShader = ShaderConfig{
 	},
 }
 
+//go:embed sprite-instanced.vs
+var SpriteInstancedVertexShader string
+
+// The sprite shader, but every vertex is also transformed by the instance's matrix and
+// multiplied by the instance's color mask. The mask uniform is the color mask of the whole draw
+// (see glitch.InstancedMesh.DrawColorMask).
+var SpriteInstancedShader = ShaderConfig{
+	VertexShader:   SpriteInstancedVertexShader,
+	FragmentShader: SpriteFragmentShader,
+	VertexFormat: VertexFormat{
+		VertexAttribute("positionIn", AttrVec3, PositionXYZ),
+		VertexAttribute("colorIn", AttrVec4, ColorRGBA),
+		VertexAttribute("texCoordIn", AttrVec2, TexCoordXY),
+		VertexAttribute("instanceModelIn", AttrMat4, InstanceMatrix),
+		VertexAttribute("instanceColorIn", AttrVec4, InstanceColorRGBA),
+	},
+	UniformFormat: UniformFormat{
+		Attr{"model", AttrMat4},
+		Attr{"projection", AttrMat4},
+		Attr{"view", AttrMat4},
+		Attr{"mask", AttrVec4},
+	},
+}
+
 //go:embed msdf.fs
 var MSDFFragmentShader string
 
+// Note: Uses textureSize, so it can't be translated to GLSL 100 (WebGL1)
 var MSDFShader = ShaderConfig{
 	VertexShader:   SpriteVertexShader,
 	FragmentShader: MSDFFragmentShader,
@@ -212,6 +269,7 @@ var MinimapShader = ShaderConfig{
 //go:embed subPixel.fs
 var SubPixelAntiAliased string
 
+// Note: Uses textureSize, so it can't be translated to GLSL 100 (WebGL1)
 var PixelArtShader = ShaderConfig{
 	VertexShader:   PixelArtVert,
 	FragmentShader: SubPixelAntiAliased,
@@ -256,6 +314,7 @@ var DiffuseVertexShader string
 //go:embed flat.fs
 var DiffuseFragmentShader string
 
+// Note: Uses inverse and transpose, so it can't be translated to GLSL 100 (WebGL1)
 var DiffuseShader = ShaderConfig{
 	VertexShader:   DiffuseVertexShader,
 	FragmentShader: DiffuseFragmentShader,
@@ -329,3 +388,84 @@ var SpriteWaterShader = ShaderConfig{
 		Attr{"moveBias", AttrVec2},
 	},
 }
+
+// Post processing passes (see glitch.PostProcess). Each pass reads the previous pass from
+// texture1 and the source of the chain from sourceTexture.
+
+//go:embed blur.fs
+var BlurFragmentShader string
+
+var BlurShader = ShaderConfig{
+	VertexShader:   SpriteVertexShader,
+	FragmentShader: BlurFragmentShader,
+	VertexFormat: VertexFormat{
+		VertexAttribute("positionIn", AttrVec3, PositionXYZ),
+		VertexAttribute("colorIn", AttrVec4, ColorRGBA),
+		VertexAttribute("texCoordIn", AttrVec2, TexCoordXY),
+	},
+	UniformFormat: UniformFormat{
+		Attr{"model", AttrMat4},
+		Attr{"projection", AttrMat4},
+		Attr{"view", AttrMat4},
+		Attr{"texelSize", AttrVec2},
+		Attr{"direction", AttrVec2},
+	},
+}
+
+//go:embed bloom-threshold.fs
+var BloomThresholdFragmentShader string
+
+var BloomThresholdShader = ShaderConfig{
+	VertexShader:   SpriteVertexShader,
+	FragmentShader: BloomThresholdFragmentShader,
+	VertexFormat: VertexFormat{
+		VertexAttribute("positionIn", AttrVec3, PositionXYZ),
+		VertexAttribute("colorIn", AttrVec4, ColorRGBA),
+		VertexAttribute("texCoordIn", AttrVec2, TexCoordXY),
+	},
+	UniformFormat: UniformFormat{
+		Attr{"model", AttrMat4},
+		Attr{"projection", AttrMat4},
+		Attr{"view", AttrMat4},
+		Attr{"threshold", AttrFloat},
+	},
+}
+
+//go:embed bloom-combine.fs
+var BloomCombineFragmentShader string
+
+var BloomCombineShader = ShaderConfig{
+	VertexShader:   SpriteVertexShader,
+	FragmentShader: BloomCombineFragmentShader,
+	VertexFormat: VertexFormat{
+		VertexAttribute("positionIn", AttrVec3, PositionXYZ),
+		VertexAttribute("colorIn", AttrVec4, ColorRGBA),
+		VertexAttribute("texCoordIn", AttrVec2, TexCoordXY),
+	},
+	UniformFormat: UniformFormat{
+		Attr{"model", AttrMat4},
+		Attr{"projection", AttrMat4},
+		Attr{"view", AttrMat4},
+		Attr{"intensity", AttrFloat},
+	},
+}
+
+//go:embed color-grade.fs
+var ColorGradeFragmentShader string
+
+var ColorGradeShader = ShaderConfig{
+	VertexShader:   SpriteVertexShader,
+	FragmentShader: ColorGradeFragmentShader,
+	VertexFormat: VertexFormat{
+		VertexAttribute("positionIn", AttrVec3, PositionXYZ),
+		VertexAttribute("colorIn", AttrVec4, ColorRGBA),
+		VertexAttribute("texCoordIn", AttrVec2, TexCoordXY),
+	},
+	UniformFormat: UniformFormat{
+		Attr{"model", AttrMat4},
+		Attr{"projection", AttrMat4},
+		Attr{"view", AttrMat4},
+		Attr{"lutSize", AttrFloat},
+		Attr{"intensity", AttrFloat},
+	},
+}
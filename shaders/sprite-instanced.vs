@@ -0,0 +1,24 @@
+#version 300 es
+
+layout (location = 0) in vec3 positionIn;
+layout (location = 1) in vec4 colorIn;
+layout (location = 2) in vec2 texCoordIn;
+layout (location = 3) in mat4 instanceModelIn; // Takes locations 3 to 6
+layout (location = 7) in vec4 instanceColorIn;
+
+out vec4 ourColor;
+out vec2 TexCoord;
+
+uniform mat4 model;
+uniform vec4 mask; // The color mask of the whole draw, on top of each instance's
+uniform mat4 projection;
+uniform mat4 view;
+
+void main()
+{
+  gl_Position = projection * view * model * instanceModelIn * vec4(positionIn, 1.0);
+
+  ourColor = colorIn * instanceColorIn * mask;
+
+  TexCoord = vec2(texCoordIn.x, texCoordIn.y);
+}
@@ -12,8 +12,10 @@ type Sorter struct {
 	DepthTest    bool
 	SoftwareSort SoftwareSortMode
 	DepthBump    bool
+	Cull         bool // Skips commands that are outside of the camera's view, see Draw
 	depthBump    float32
 	currentLayer int8
+	clips        []clipRect // The clip stack, see PushClip
 
 	// States that are used for forming the draw command
 	// blendMode BlendMode
@@ -39,6 +41,29 @@ func (s *Sorter) Layer() int8 {
 	return s.currentLayer
 }
 
+// PushClip clips everything that is added to the sorter until the matching PopClip to rect.
+// The rect is in camera space (ie the same space that the geometry is drawn in), it is
+// projected through the camera that is set when the commands are drawn. Nested clips are
+// intersected with the clips around them.
+func (s *Sorter) PushClip(rect Rect) {
+	s.clips = append(s.clips, s.currentClip().intersect(rect))
+}
+
+// PopClip removes the clip that was added by the last PushClip
+func (s *Sorter) PopClip() {
+	if len(s.clips) == 0 {
+		return
+	}
+	s.clips = s.clips[:len(s.clips)-1]
+}
+
+func (s *Sorter) currentClip() clipRect {
+	if len(s.clips) == 0 {
+		return clipRect{}
+	}
+	return s.clips[len(s.clips)-1]
+}
+
 func (s *Sorter) Clear() {
 	s.depthBump = 0
 
@@ -60,8 +85,16 @@ func (s *Sorter) Clear() {
 // 	s.camera = camera
 // }
 
+// Draw sorts the commands and adds them to target, then clears the sorter. If Cull is set then
+// the commands whose bounds are entirely outside of the view of the camera that is currently set
+// are dropped before sorting, so they are never filled or uploaded.
 func (s *Sorter) Draw(target BatchTarget) {
+	start := global.statsTime()
+	if s.Cull {
+		s.cull()
+	}
 	s.sort()
+	global.statsSince(&global.stats.SortTime, start)
 
 	if s.DepthTest {
 		// Opaque goes front to back (0 to 255)
@@ -94,6 +127,27 @@ func (s *Sorter) Draw(target BatchTarget) {
 	s.Clear()
 }
 
+// Removes the commands that can't be seen by the current camera
+func (s *Sorter) cull() {
+	camera := global.camera
+	culled := func(c drawCommand) bool {
+		return !camera.visible(c.filler.Bounds(), c.matrix)
+	}
+
+	for l := range s.commands {
+		list := &s.commands[l]
+		n := len(list.Opaque) + len(list.Translucent)
+		if n == 0 {
+			continue
+		}
+		list.Opaque = slices.DeleteFunc(list.Opaque, culled)
+		list.Translucent = slices.DeleteFunc(list.Translucent, culled)
+		removed := n - len(list.Opaque) - len(list.Translucent)
+		global.metric.culled += removed
+		global.stats.Culled += removed
+	}
+}
+
 func (s *Sorter) applyDrawCommand(target BatchTarget, c drawCommand) {
 	target.Add(c.filler, c.matrix, c.mask, c.material)
 }
@@ -117,6 +171,14 @@ func (s *Sorter) Add(filler GeometryFiller, mat glMat4, mask RGBA, material Mate
 		translucent = true
 	}
 
+	if clip := s.currentClip(); clip.enabled {
+		if material.clip.enabled {
+			material.clip = material.clip.intersect(clip.rect)
+		} else {
+			material.clip = clip
+		}
+	}
+
 	if s.DepthTest {
 		// If we are doing depth testing, then use the s.CurrentLayer field to determine the depth (normalizing from (0 to 1). Notably the standard ortho cam is (-1, 1) which this range fits into but is easier to normalize to // TODO - make that depth range tweakable?
 		// TODO - hardcoded because layer is a uint8. You probably want to make layer an int and then just set depth based on that
@@ -156,6 +218,10 @@ func (s *Sorter) Add(filler GeometryFiller, mat glMat4, mask RGBA, material Mate
 	})
 }
 
+// Sorts the commands of every layer. With depth testing the depth test decides what ends up in
+// front of the opaque commands, so they're grouped by their batch key instead (see
+// compareBatchKey). Note: Opaque commands at exactly the same depth can then draw in a different
+// order, use DepthBump to give each one its own depth.
 func (s *Sorter) sort() {
 	if s.DepthTest {
 		// TODO - do special sort function for depth test code:
@@ -166,6 +232,7 @@ func (s *Sorter) sort() {
 		// Sort translucent buffer
 		for l := range s.commands {
 			s.commands[l].SortTranslucent(s.SoftwareSort)
+			s.commands[l].SortOpaque(SoftwareSortTexture)
 		}
 
 		return
@@ -210,13 +277,16 @@ func (c *cmdList) Clear() {
 
 type SoftwareSortMode uint8
 
+// The position sorts break ties with the batch key (see SoftwareSortTexture), so commands at the
+// same position that can batch together end up next to each other.
 const (
-	SoftwareSortNone      SoftwareSortMode = iota
-	SoftwareSortX                          // Sort based on the X position
-	SoftwareSortY                          // Sort based on the Y position
-	SoftwareSortZ                          // Sort based on the Z position
-	SoftwareSortZNegative                  // Opposite order to SoftwareSortZ
-	SoftwareSortCommand                    // Sort by the computed drawCommand.command
+	SoftwareSortNone      SoftwareSortMode = iota // Keep the order that the commands were added in, so only neighbours batch
+	SoftwareSortX                                 // Sort based on the X position
+	SoftwareSortY                                 // Sort based on the Y position
+	SoftwareSortZ                                 // Sort based on the Z position
+	SoftwareSortZNegative                         // Opposite order to SoftwareSortZ
+	SoftwareSortCommand                           // Sort by the computed drawCommand.command
+	SoftwareSortTexture                           // Group commands that bind the same set of textures and clip rect, so they batch together
 )
 
 type drawCommand struct {
@@ -233,19 +303,23 @@ func SortDrawCommands(buf []drawCommand, sortMode SoftwareSortMode) {
 
 	if sortMode == SoftwareSortX {
 		slices.SortStableFunc(buf, func(a, b drawCommand) int {
-			return -cmp.Compare(a.matrix[i4_3_0], b.matrix[i4_3_0]) // sort by x
+			return cmp.Or(-cmp.Compare(a.matrix[i4_3_0], b.matrix[i4_3_0]), compareBatchKey(&a, &b)) // sort by x
 		})
 	} else if sortMode == SoftwareSortY {
 		slices.SortStableFunc(buf, func(a, b drawCommand) int {
-			return -cmp.Compare(a.matrix[i4_3_1], b.matrix[i4_3_1]) // sort by y
+			return cmp.Or(-cmp.Compare(a.matrix[i4_3_1], b.matrix[i4_3_1]), compareBatchKey(&a, &b)) // sort by y
 		})
 	} else if sortMode == SoftwareSortZ {
 		slices.SortStableFunc(buf, func(a, b drawCommand) int {
-			return cmp.Compare(a.matrix[i4_3_2], b.matrix[i4_3_2]) // sort by z
+			return cmp.Or(cmp.Compare(a.matrix[i4_3_2], b.matrix[i4_3_2]), compareBatchKey(&a, &b)) // sort by z
 		})
 	} else if sortMode == SoftwareSortZNegative {
 		slices.SortStableFunc(buf, func(a, b drawCommand) int {
-			return -cmp.Compare(a.matrix[i4_3_2], b.matrix[i4_3_2]) // sort by z
+			return cmp.Or(-cmp.Compare(a.matrix[i4_3_2], b.matrix[i4_3_2]), compareBatchKey(&a, &b)) // sort by z
+		})
+	} else if sortMode == SoftwareSortTexture {
+		slices.SortStableFunc(buf, func(a, b drawCommand) int {
+			return compareBatchKey(&a, &b)
 		})
 	} //  else if sortMode == SoftwareSortCommand {
 	// 	slices.SortStableFunc(buf, func(a, b drawCommand) int {
@@ -253,3 +327,43 @@ func SortDrawCommands(buf []drawCommand, sortMode SoftwareSortMode) {
 	// 	})
 	// }
 }
+
+// Orders commands by what decides whether they can batch together. This is the texture set and
+// the clip rect, because any difference in the bound textures or the scissor breaks a batch.
+func compareBatchKey(a, b *drawCommand) int {
+	return cmp.Or(
+		compareTextureSets(&a.material, &b.material),
+		compareClips(a.material.clip, b.material.clip),
+	)
+}
+
+// Orders materials by the textures (and samplers) in every slot. Any difference in the texture
+// set breaks a batch, so every slot is part of the key, not just the first one.
+func compareTextureSets(a, b *Material) int {
+	for i := range a.textures {
+		ta, tb := a.textures[i], b.textures[i]
+		if c := cmp.Compare(ta.texture.sortID(), tb.texture.sortID()); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(ta.sampler, tb.sampler); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// Orders clip rects so that commands with the same clip are next to each other
+func compareClips(a, b clipRect) int {
+	if a.enabled != b.enabled {
+		if a.enabled {
+			return 1
+		}
+		return -1
+	}
+	return cmp.Or(
+		cmp.Compare(a.rect.Min.X, b.rect.Min.X),
+		cmp.Compare(a.rect.Min.Y, b.rect.Min.Y),
+		cmp.Compare(a.rect.Max.X, b.rect.Max.X),
+		cmp.Compare(a.rect.Max.Y, b.rect.Max.Y),
+	)
+}
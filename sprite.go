@@ -99,7 +99,7 @@ func (s *Sprite) SetTextureBounds(bounds Rect) {
 	s.frame = bounds
 	s.bounds = bounds.Moved(bounds.Center().Scaled(-1))
 
-	tex := s.material.texture
+	tex := s.material.textures[0].texture
 	s.uvBounds = glm.R(
 		bounds.Min.X/float64(tex.width),
 		bounds.Min.Y/float64(tex.height),
@@ -115,6 +115,7 @@ func (s *Sprite) SetTextureBounds(bounds Rect) {
 	s.mesh.positions[1] = glVec3{float32(meshBounds.Max.X), float32(meshBounds.Min.Y), float32(0.0)}
 	s.mesh.positions[2] = glVec3{float32(meshBounds.Min.X), float32(meshBounds.Min.Y), float32(0.0)}
 	s.mesh.positions[3] = glVec3{float32(meshBounds.Min.X), float32(meshBounds.Max.Y), float32(0.0)}
+	s.mesh.bounds = meshBounds.ToBox()
 
 	s.mesh.texCoords[0] = glVec2{float32(s.uvBounds.Max.X), float32(s.uvBounds.Min.Y)}
 	s.mesh.texCoords[1] = glVec2{float32(s.uvBounds.Max.X), float32(s.uvBounds.Max.Y)}
@@ -187,7 +188,7 @@ type NinePanelSprite struct {
 }
 
 func SpriteToNinePanel(sprite *Sprite, border Rect) *NinePanelSprite {
-	return NewNinePanelSprite(sprite.material.texture, sprite.frame, border)
+	return NewNinePanelSprite(sprite.material.textures[0].texture, sprite.frame, border)
 }
 
 func NewNinePanelSprite(texture *Texture, bounds Rect, border Rect) *NinePanelSprite {
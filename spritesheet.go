@@ -0,0 +1,293 @@
+package glitch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/unitoftime/flow/glm"
+)
+
+// Spritesheet is a set of named sprites that share one texture, along with the animation tags
+// of the sheet. Load one with NewSpritesheetAseprite or NewSpritesheetTexturePacker.
+type Spritesheet struct {
+	texture *Texture
+	frames  []SpritesheetFrame
+	names   map[string]int // Maps frame names to frame indices
+	tags    []SpritesheetTag
+}
+
+// SpritesheetFrame is a single named sprite of a Spritesheet
+type SpritesheetFrame struct {
+	Name     string
+	Sprite   *Sprite
+	Duration time.Duration // How long the frame is shown for in an animation, 0 if the sheet doesn't say
+}
+
+// SpritesheetTag is a named range of frames that make up an animation
+type SpritesheetTag struct {
+	Name      string
+	From, To  int // The indices of the first and last frames of the animation, inclusive
+	Direction AnimationDirection
+
+	Repeat int // The number of times the animation plays, 0 to loop forever
+}
+
+// AnimationDirection is the order that the frames of an animation are played in
+type AnimationDirection uint8
+
+const (
+	AnimationForward         AnimationDirection = iota // First to last
+	AnimationReverse                                   // Last to first
+	AnimationPingPong                                  // First to last, then back to the first
+	AnimationPingPongReverse                           // Last to first, then back to the last
+)
+
+var animationDirectionLut = map[string]AnimationDirection{
+	"":                 AnimationForward,
+	"forward":          AnimationForward,
+	"reverse":          AnimationReverse,
+	"pingpong":         AnimationPingPong,
+	"pingpong_reverse": AnimationPingPongReverse,
+}
+
+// NewSpritesheetAseprite loads a sheet exported from Aseprite with either the hash or the
+// array json layout. The frame durations and frame tags are loaded too.
+// Texture is the sheet image, the image path in the json metadata is ignored.
+func NewSpritesheetAseprite(data []byte, texture *Texture) (*Spritesheet, error) {
+	sheet, err := parseSpritesheet(data, texture)
+	if err != nil {
+		return nil, fmt.Errorf("aseprite spritesheet: %w", err)
+	}
+	return sheet, nil
+}
+
+// NewSpritesheetTexturePacker loads a sheet exported from TexturePacker with either the
+// JSON (Hash) or the JSON (Array) data format. Trimmed and rotated frames are supported.
+// Texture is the sheet image, the image path in the json metadata is ignored.
+func NewSpritesheetTexturePacker(data []byte, texture *Texture) (*Spritesheet, error) {
+	sheet, err := parseSpritesheet(data, texture)
+	if err != nil {
+		return nil, fmt.Errorf("texturepacker spritesheet: %w", err)
+	}
+	return sheet, nil
+}
+
+// Texture returns the texture that every sprite of the sheet draws from
+func (s *Spritesheet) Texture() *Texture {
+	return s.texture
+}
+
+// Frames returns every frame of the sheet, in the order of the json file
+func (s *Spritesheet) Frames() []SpritesheetFrame {
+	return s.frames
+}
+
+// Get returns the sprite of the frame named name
+func (s *Spritesheet) Get(name string) (*Sprite, bool) {
+	i, ok := s.names[name]
+	if !ok {
+		return nil, false
+	}
+	return s.frames[i].Sprite, true
+}
+
+// Tags returns every animation tag of the sheet
+func (s *Spritesheet) Tags() []SpritesheetTag {
+	return s.tags
+}
+
+// Tag returns the animation tag named name
+func (s *Spritesheet) Tag(name string) (SpritesheetTag, bool) {
+	for _, tag := range s.tags {
+		if tag.Name == name {
+			return tag, true
+		}
+	}
+	return SpritesheetTag{}, false
+}
+
+// TagFrames returns the frames of the animation tag named name, from tag.From to tag.To
+func (s *Spritesheet) TagFrames(name string) ([]SpritesheetFrame, bool) {
+	tag, ok := s.Tag(name)
+	if !ok {
+		return nil, false
+	}
+	return s.frames[tag.From : tag.To+1], true
+}
+
+//--------------------------------------------------------------------------------
+// Json layout, shared by Aseprite and TexturePacker
+
+type sheetJson struct {
+	Frames json.RawMessage // Either an object mapping names to frames, or an array of frames
+	Meta   struct {
+		Size      sheetSize
+		FrameTags []struct {
+			Name      string
+			From, To  int
+			Direction string
+			Repeat    json.Number // Aseprite writes this as a string
+		}
+	}
+}
+
+type sheetFrameJson struct {
+	Filename         string
+	Frame            sheetRect // The size of the frame before it was rotated
+	Rotated          bool      // Set if the frame was rotated 90 degrees clockwise in the sheet
+	Trimmed          bool
+	SpriteSourceSize sheetRect // The bounds of the trimmed frame inside of the original image
+	SourceSize       sheetSize // The size of the original image
+	Duration         int       // Milliseconds
+}
+
+type sheetRect struct {
+	X, Y, W, H int
+}
+
+type sheetSize struct {
+	W, H int
+}
+
+func parseSpritesheet(data []byte, texture *Texture) (*Spritesheet, error) {
+	var sheetData sheetJson
+	err := json.Unmarshal(data, &sheetData)
+	if err != nil {
+		return nil, err
+	}
+
+	size := sheetData.Meta.Size
+	if size != (sheetSize{}) && (size.W != texture.width || size.H != texture.height) {
+		return nil, fmt.Errorf("sheet is %dx%d but the texture is %dx%d", size.W, size.H, texture.width, texture.height)
+	}
+
+	frames, err := decodeSheetFrames(sheetData.Frames)
+	if err != nil {
+		return nil, err
+	}
+
+	sheet := &Spritesheet{
+		texture: texture,
+		frames:  make([]SpritesheetFrame, len(frames)),
+		names:   make(map[string]int, len(frames)),
+	}
+	for i, f := range frames {
+		sheet.frames[i] = SpritesheetFrame{
+			Name:     f.Filename,
+			Sprite:   newSheetSprite(texture, f),
+			Duration: time.Duration(f.Duration) * time.Millisecond,
+		}
+		sheet.names[f.Filename] = i
+	}
+
+	for _, t := range sheetData.Meta.FrameTags {
+		direction, ok := animationDirectionLut[t.Direction]
+		if !ok {
+			return nil, fmt.Errorf("tag %s: unknown direction %q", t.Name, t.Direction)
+		}
+		if t.From < 0 || t.To >= len(frames) || t.From > t.To {
+			return nil, fmt.Errorf("tag %s: frames %d to %d are out of range", t.Name, t.From, t.To)
+		}
+		var repeat int64
+		if t.Repeat != "" {
+			repeat, err = t.Repeat.Int64()
+			if err != nil {
+				return nil, fmt.Errorf("tag %s: repeat: %w", t.Name, err)
+			}
+		}
+		sheet.tags = append(sheet.tags, SpritesheetTag{
+			Name:      t.Name,
+			From:      t.From,
+			To:        t.To,
+			Direction: direction,
+			Repeat:    int(repeat),
+		})
+	}
+
+	return sheet, nil
+}
+
+// Decodes the frames of either layout. The hash layout is decoded key by key, because the
+// frame tags refer to the frames by their order in the file.
+func decodeSheetFrames(raw json.RawMessage) ([]sheetFrameJson, error) {
+	var frames []sheetFrameJson
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("missing frames")
+	}
+	if raw[0] == '[' {
+		err := json.Unmarshal(raw, &frames)
+		return frames, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('{') {
+		return nil, fmt.Errorf("frames must be an object or an array")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var frame sheetFrameJson
+		err = dec.Decode(&frame)
+		if err != nil {
+			return nil, err
+		}
+		frame.Filename = tok.(string)
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// Creates the sprite of a frame. The sprite has the bounds of the original untrimmed image,
+// with the trimmed quad offset inside of it.
+func newSheetSprite(texture *Texture, f sheetFrameJson) *Sprite {
+	x, y := float64(f.Frame.X), float64(f.Frame.Y)
+	w, h := float64(f.Frame.W), float64(f.Frame.H)
+
+	frame := glm.R(x, y, x+w, y+h)
+	if f.Rotated {
+		frame = glm.R(x, y, x+h, y+w)
+	}
+	uvBounds := glm.R(
+		frame.Min.X/float64(texture.width),
+		frame.Min.Y/float64(texture.height),
+		frame.Max.X/float64(texture.width),
+		frame.Max.Y/float64(texture.height),
+	)
+
+	srcW, srcH := w, h
+	var offX, offY float64
+	if f.SourceSize != (sheetSize{}) {
+		srcW, srcH = float64(f.SourceSize.W), float64(f.SourceSize.H)
+		offX, offY = float64(f.SpriteSourceSize.X), float64(f.SpriteSourceSize.Y)
+	}
+
+	// The offset is measured from the top left of the image, but the mesh is y up
+	minX := offX - srcW/2
+	maxY := srcH/2 - offY
+	mesh := NewQuadMesh(glm.R(minX, maxY-h, minX+w, maxY), uvBounds)
+
+	if f.Rotated {
+		// The frame is rotated clockwise in the sheet, so its top edge is on the right
+		mesh.texCoords[0] = glVec2{float32(uvBounds.Max.X), float32(uvBounds.Max.Y)}
+		mesh.texCoords[1] = glVec2{float32(uvBounds.Min.X), float32(uvBounds.Max.Y)}
+		mesh.texCoords[2] = glVec2{float32(uvBounds.Min.X), float32(uvBounds.Min.Y)}
+		mesh.texCoords[3] = glVec2{float32(uvBounds.Max.X), float32(uvBounds.Min.Y)}
+	}
+
+	return &Sprite{
+		mesh:     mesh,
+		bounds:   glm.R(-srcW/2, -srcH/2, srcW/2, srcH/2),
+		frame:    frame,
+		uvBounds: uvBounds,
+		material: DefaultMaterial(texture),
+	}
+}
@@ -0,0 +1,238 @@
+//go:build headless
+
+package glitch_test
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/unitoftime/flow/glm"
+	"github.com/unitoftime/glitch"
+	"github.com/unitoftime/glitch/glitchtest"
+)
+
+var (
+	red    = color.RGBA{255, 0, 0, 255}
+	green  = color.RGBA{0, 255, 0, 255}
+	blue   = color.RGBA{0, 0, 255, 255}
+	yellow = color.RGBA{255, 255, 0, 255}
+	black  = color.RGBA{0, 0, 0, 255}
+)
+
+// Returns the 16x8 texture that the testdata sheets describe. The image is top down:
+//   - (0, 0, 4, 4) is red on top and green on the bottom
+//   - (4, 0, 8, 4) is blue
+//   - (8, 0, 12, 2) is a 2x4 image, red on top and blue on the bottom, rotated clockwise
+//   - (12, 0, 16, 4) is yellow
+func sheetTexture(t *testing.T) *glitch.Texture {
+	t.Helper()
+	fill := func(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	fill(img, image.Rect(0, 0, 4, 2), red)
+	fill(img, image.Rect(0, 2, 4, 4), green)
+	fill(img, image.Rect(4, 0, 8, 4), blue)
+	fill(img, image.Rect(8, 0, 10, 2), blue)
+	fill(img, image.Rect(10, 0, 12, 2), red)
+	fill(img, image.Rect(12, 0, 16, 4), yellow)
+	texture := glitch.NewTexture(img, false)
+	t.Cleanup(texture.Delete)
+	return texture
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// Draws the sprite over a width x height scene with a black background
+func renderSprite(sprite *glitch.Sprite, width, height int) *image.RGBA {
+	scene := glitchtest.NewScene(width, height)
+	scene.Clear = glitch.Black
+	sprite.RectDraw(scene.Sorter, scene.Bounds())
+	return scene.Render()
+}
+
+// Asserts that the w x h block of pixels at x, y (counting up from the bottom) is c
+func assertBlock(t *testing.T, img *image.RGBA, x, y, w, h int, c color.RGBA) {
+	t.Helper()
+	for dy := range h {
+		for dx := range w {
+			assertPixel(t, img, x+dx, y+dy, c)
+		}
+	}
+}
+
+func TestSpritesheetAseprite(t *testing.T) {
+	texture := sheetTexture(t)
+
+	// Both layouts load the same sheet, and the hash layout keeps the order of the file
+	for _, name := range []string{"aseprite_hash.json", "aseprite_array.json"} {
+		t.Run(strings.TrimSuffix(name, ".json"), func(t *testing.T) {
+			sheet, err := glitch.NewSpritesheetAseprite(readFixture(t, name), texture)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			frames := sheet.Frames()
+			if len(frames) != 2 {
+				t.Fatalf("got %d frames, want 2", len(frames))
+			}
+			wantFrames := []struct {
+				name     string
+				frame    glm.Rect
+				duration time.Duration
+			}{
+				{"knight 0.aseprite", glm.R(0, 0, 4, 4), 100 * time.Millisecond},
+				{"knight 1.aseprite", glm.R(4, 0, 8, 4), 150 * time.Millisecond},
+			}
+			for i, want := range wantFrames {
+				f := frames[i]
+				if f.Name != want.name || f.Duration != want.duration {
+					t.Errorf("frame %d is %q for %v, want %q for %v", i, f.Name, f.Duration, want.name, want.duration)
+				}
+				if f.Sprite.Frame() != want.frame || f.Sprite.Bounds() != glm.R(-2, -2, 2, 2) {
+					t.Errorf("frame %d has frame %v and bounds %v", i, f.Sprite.Frame(), f.Sprite.Bounds())
+				}
+				sprite, ok := sheet.Get(want.name)
+				if !ok || sprite != f.Sprite {
+					t.Errorf("Get(%q) didn't return frame %d", want.name, i)
+				}
+			}
+
+			wantTags := []glitch.SpritesheetTag{
+				{Name: "walk", From: 0, To: 1, Direction: glitch.AnimationPingPong, Repeat: 3},
+				{Name: "idle", From: 1, To: 1, Direction: glitch.AnimationForward},
+			}
+			tags := sheet.Tags()
+			if len(tags) != len(wantTags) {
+				t.Fatalf("got tags %v, want %v", tags, wantTags)
+			}
+			for i := range tags {
+				if tags[i] != wantTags[i] {
+					t.Errorf("got tag %v, want %v", tags[i], wantTags[i])
+				}
+			}
+			idle, ok := sheet.TagFrames("idle")
+			if !ok || len(idle) != 1 || idle[0].Name != "knight 1.aseprite" {
+				t.Errorf("got idle frames %v", idle)
+			}
+
+			// The top of the first frame is red
+			img := renderSprite(frames[0].Sprite, 8, 8)
+			assertBlock(t, img, 0, 4, 8, 4, red)
+			assertBlock(t, img, 0, 0, 8, 4, green)
+		})
+	}
+}
+
+func TestSpritesheetTexturePacker(t *testing.T) {
+	texture := sheetTexture(t)
+	sheet, err := glitch.NewSpritesheetTexturePacker(readFixture(t, "texturepacker.json"), texture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames := sheet.Frames()
+	if len(frames) != 3 || frames[0].Name != "sword.png" || frames[1].Name != "potion.png" || frames[2].Name != "coin.png" {
+		t.Fatalf("got frames %v", frames)
+	}
+	if frames[0].Duration != 0 || len(sheet.Tags()) != 0 {
+		t.Errorf("got duration %v and tags %v, want none", frames[0].Duration, sheet.Tags())
+	}
+
+	t.Run("rotated", func(t *testing.T) {
+		potion, _ := sheet.Get("potion.png")
+		if potion.Frame() != glm.R(8, 0, 12, 2) || potion.Bounds() != glm.R(-1, -2, 1, 2) {
+			t.Errorf("got frame %v and bounds %v", potion.Frame(), potion.Bounds())
+		}
+
+		// The sprite is upright again
+		img := renderSprite(potion, 8, 16)
+		assertBlock(t, img, 0, 8, 8, 8, red)
+		assertBlock(t, img, 0, 0, 8, 8, blue)
+	})
+
+	t.Run("trimmed", func(t *testing.T) {
+		coin, _ := sheet.Get("coin.png")
+		if coin.Frame() != glm.R(12, 0, 16, 4) || coin.Bounds() != glm.R(-4, -4, 4, 4) {
+			t.Errorf("got frame %v and bounds %v", coin.Frame(), coin.Bounds())
+		}
+
+		// The sprite has the size of the original image, with the trimmed pixels in its top right
+		img := renderSprite(coin, 8, 8)
+		assertBlock(t, img, 4, 4, 4, 4, yellow)
+		assertBlock(t, img, 0, 0, 4, 8, black)
+		assertBlock(t, img, 4, 0, 4, 4, black)
+	})
+}
+
+func TestSpritesheetErrors(t *testing.T) {
+	texture := sheetTexture(t)
+	frame := `{"frame": {"x": 0, "y": 0, "w": 4, "h": 4}}`
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"texture size", `{"frames": [` + frame + `], "meta": {"size": {"w": 32, "h": 8}}}`, "sheet is 32x8 but the texture is 16x8"},
+		{"missing frames", `{"meta": {}}`, "missing frames"},
+		{"frames type", `{"frames": "a.png"}`, "frames must be an object or an array"},
+		{"tag range", `{"frames": [` + frame + `], "meta": {"frameTags": [{"name": "walk", "from": 0, "to": 1}]}}`, "tag walk: frames 0 to 1 are out of range"},
+		{"tag direction", `{"frames": [` + frame + `], "meta": {"frameTags": [{"name": "walk", "direction": "sideways"}]}}`, `tag walk: unknown direction "sideways"`},
+		{"tag repeat", `{"frames": [` + frame + `], "meta": {"frameTags": [{"name": "walk", "repeat": "1.5"}]}}`, "tag walk: repeat"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := glitch.NewSpritesheetAseprite([]byte(test.data), texture)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestAnimatedSpriteTag(t *testing.T) {
+	texture := sheetTexture(t)
+	sheet, err := glitch.NewSpritesheetAseprite(readFixture(t, "aseprite_hash.json"), texture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The walk tag ping pongs three times over both frames, with the durations of the sheet
+	anim, err := glitch.NewAnimatedSpriteTag(sheet, "walk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if anim.Mode() != glitch.AnimationModePingPong || len(anim.Frames()) != 2 {
+		t.Fatalf("got mode %v with %d frames", anim.Mode(), len(anim.Frames()))
+	}
+	finished := 0
+	anim.OnFinish(func() { finished++ })
+	anim.Update(100 * time.Millisecond)
+	if anim.Frame() != 1 || anim.Sprite() != sheet.Frames()[1].Sprite {
+		t.Errorf("got frame %d after the first frame's duration, want 1", anim.Frame())
+	}
+	anim.Update(time.Second)
+	if finished != 1 || anim.Frame() != 0 {
+		t.Errorf("finished %d times on frame %d, want to finish once on frame 0", finished, anim.Frame())
+	}
+
+	_, err = glitch.NewAnimatedSpriteTag(sheet, "run")
+	if err == nil || !strings.Contains(err.Error(), "missing tag run") {
+		t.Errorf("got error %v for a missing tag", err)
+	}
+}
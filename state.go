@@ -23,7 +23,8 @@ type stateTracker struct {
 	depthModeBinder func()
 
 	// Texture
-	texture       *Texture
+	textures      [MaxTextureSlots]*Texture // The texture bound to each texture unit
+	textureUnit   int                       // The active texture unit
 	textureBinder func()
 
 	// BlendFunc
@@ -36,6 +37,16 @@ type stateTracker struct {
 	cullMode       CullMode
 	cullModeBinder func()
 
+	// Stencil
+	stencilMode       StencilMode
+	stencilModeBinder func()
+
+	// Scissor
+	clip           clipRect
+	scissorEnabled bool
+	scissorBox     [4]int32 // The clip rect projected into framebuffer pixels (x, y, w, h)
+	scissorBinder  func()
+
 	// Vert Buffer
 	vertBuf       *VertexBuffer
 	vertBufDrawer func()
@@ -69,7 +80,8 @@ func init() {
 		gl.Enable(gl.BLEND) // TODO: This only needs to run if it was disabled previously
 
 		data := blendModeLut[state.blendMode]
-		gl.BlendFunc(data.src, data.dst)
+		gl.BlendFuncSeparate(data.srcRGB, data.dstRGB, data.srcAlpha, data.dstAlpha)
+		gl.BlendEquationSeparate(data.eqRGB, data.eqAlpha)
 	}
 
 	state.cullModeBinder = func() {
@@ -83,6 +95,31 @@ func init() {
 		}
 	}
 
+	state.stencilModeBinder = func() {
+		mode := state.stencilMode
+		if mode.Func == StencilFuncNone {
+			gl.Disable(gl.STENCIL_TEST)
+			gl.ColorMask(true, true, true, true)
+			return
+		}
+
+		gl.Enable(gl.STENCIL_TEST)
+		gl.StencilFunc(stencilFuncLut[mode.Func], int(mode.Ref), uint32(mode.ReadMask))
+		gl.StencilMask(uint32(mode.WriteMask))
+		gl.StencilOp(stencilOpLut[mode.Fail], stencilOpLut[mode.DepthFail], stencilOpLut[mode.Pass])
+		gl.ColorMask(!mode.SkipColor, !mode.SkipColor, !mode.SkipColor, !mode.SkipColor)
+	}
+
+	state.scissorBinder = func() {
+		if !state.scissorEnabled {
+			gl.Disable(gl.SCISSOR_TEST)
+			return
+		}
+		gl.Enable(gl.SCISSOR_TEST)
+		box := state.scissorBox
+		gl.Scissor(box[0], box[1], box[2], box[3])
+	}
+
 	// state.enableDepthFunc = func() {
 	// 	if state.depthTest {
 	// 		gl.Enable(gl.DEPTH_TEST)
@@ -102,15 +139,8 @@ func init() {
 	}
 
 	state.textureBinder = func() {
-		gl.ActiveTexture(gl.TEXTURE0)
-		// gl.ActiveTexture(gl.TEXTURE0 + position); // TODO - include position
-
-		if state.texture == nil {
-			gl.BindTexture(gl.TEXTURE_2D, gl.NoTexture)
-		} else {
-			gl.BindTexture(gl.TEXTURE_2D, state.texture.texture)
-		}
-		// gl.BindTexture(gl.TEXTURE_2D, state.texture.texture)
+		gl.ActiveTexture(gl.Enum(gl.TEXTURE0 + state.textureUnit))
+		state.mainthreadRestoreTexture()
 	}
 
 	// state.blendFuncBinder = func() {
@@ -134,15 +164,47 @@ func init() {
 	state.clearFunc = func() {
 		gl.ClearColor(float32(state.clearColor.R), float32(state.clearColor.G), float32(state.clearColor.B), float32(state.clearColor.A))
 		// gl.Clear(gl.COLOR_BUFFER_BIT) // Make configurable?
-		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+		// The write masks and scissor test also apply to clears, so clear everything then put back
+		// the stencil mode's masks and the scissor test
+		gl.ColorMask(true, true, true, true)
+		gl.StencilMask(0xFF)
+		if state.scissorEnabled {
+			gl.Disable(gl.SCISSOR_TEST)
+		}
+		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT | gl.STENCIL_BUFFER_BIT)
+		if state.scissorEnabled {
+			gl.Enable(gl.SCISSOR_TEST)
+		}
+		if state.stencilMode.Func != StencilFuncNone {
+			skip := state.stencilMode.SkipColor
+			gl.ColorMask(!skip, !skip, !skip, !skip)
+			gl.StencilMask(uint32(state.stencilMode.WriteMask))
+		}
 	}
 }
 
-// TODO - maybe allow for more than 15 if the platform supports it? TODO - max texture units
-func (s *stateTracker) bindTexture(texture *Texture) {
-	// TODO: Check if changed
-	s.texture = texture
-	mainthread.Call(state.textureBinder)
+// TODO - maybe allow for more than MaxTextureSlots if the platform supports it? TODO - max texture units
+func (s *stateTracker) bindTexture(unit int, texture *Texture) {
+	if s.textures[unit] == texture {
+		return // Skip: State already matches
+	}
+	s.textures[unit] = texture
+	s.textureUnit = unit
+
+	mainthread.Call(s.textureBinder)
+	global.stats.TextureSwitches++
+}
+
+// Rebinds the texture that the tracker expects on the active texture unit. Call this on the
+// mainthread after binding a texture directly with gl.BindTexture (eg to upload pixels)
+func (s *stateTracker) mainthreadRestoreTexture() {
+	texture := s.textures[s.textureUnit]
+	if texture == nil {
+		gl.BindTexture(gl.TEXTURE_2D, gl.NoTexture)
+	} else {
+		gl.BindTexture(gl.TEXTURE_2D, texture.texture)
+	}
 }
 
 func (s *stateTracker) bindFramebuffer(fbo gl.Framebuffer, bounds Rect) {
@@ -153,6 +215,8 @@ func (s *stateTracker) bindFramebuffer(fbo gl.Framebuffer, bounds Rect) {
 	state.fboBounds = bounds
 
 	mainthread.Call(s.fboBinder)
+	global.stats.FramebufferSwitches++
+	s.updateScissor()
 }
 
 func (s *stateTracker) setDepthMode(depth DepthMode) {
@@ -171,6 +235,7 @@ func (s *stateTracker) setBlendMode(blend BlendMode) {
 	s.blendMode = blend
 
 	mainthread.Call(s.blendModeBinder)
+	global.stats.BlendSwitches++
 }
 
 func (s *stateTracker) setCullMode(cull CullMode) {
@@ -205,6 +270,40 @@ func (s *stateTracker) setCullMode(cull CullMode) {
 // 	mainthread.Call(s.blendFuncBinder)
 // }
 
+func (s *stateTracker) setStencilMode(stencil StencilMode) {
+	if s.stencilMode == stencil {
+		return // Skip: State already matches
+	}
+	s.stencilMode = stencil
+
+	mainthread.Call(s.stencilModeBinder)
+}
+
+func (s *stateTracker) setClip(clip clipRect) {
+	if s.clip == clip {
+		return // Skip: State already matches
+	}
+	s.clip = clip
+	s.updateScissor()
+}
+
+// Projects the clip rect into the bound framebuffer with the current camera. This needs to
+// rerun whenever the clip, camera or framebuffer changes.
+func (s *stateTracker) updateScissor() {
+	enabled := s.clip.enabled
+	var box [4]int32
+	if enabled {
+		box = s.clip.scissorBox(global.camera, s.fboBounds)
+	}
+	if s.scissorEnabled == enabled && s.scissorBox == box {
+		return // Skip: State already matches
+	}
+	s.scissorEnabled = enabled
+	s.scissorBox = box
+
+	mainthread.Call(s.scissorBinder)
+}
+
 func (s *stateTracker) drawVertBuffer(vb *VertexBuffer) {
 	s.vertBuf = vb
 	mainthread.Call(s.vertBufDrawer)
@@ -0,0 +1,195 @@
+package glitch
+
+import (
+	"time"
+)
+
+// FrameStats is a breakdown of the rendering work done in a single frame. The stats are
+// counted on the CPU as the commands are submitted, so they can be read without a GPU.
+type FrameStats struct {
+	DrawCalls          int
+	DrawCallsPerTarget map[TargetID]int // The draw calls made into each target (eg a Window or a Frame), see DrawCallsInto
+
+	// The number of times the GL state actually changed, state changes that are skipped
+	// because they match the current state aren't counted
+	ShaderSwitches      int
+	TextureSwitches     int
+	BlendSwitches       int
+	FramebufferSwitches int
+
+	VerticesUploaded int // The vertices written to the GPU, buffered meshes only count when they're first drawn
+	IndicesUploaded  int
+
+	BuffersInUse     int // The peak number of BufferPool buffers that were filled, across every shader
+	BuffersAllocated int // The number of BufferPool buffers that exist, across every shader
+
+	Culled int // The commands that were skipped because they were outside of the camera
+
+	// The time spent sorting (and culling) in Sorter.Draw, filling vertex buffers and submitting
+	// draw calls. These are only measured while stats are enabled, see EnableStats.
+	SortTime   time.Duration
+	FillTime   time.Duration
+	SubmitTime time.Duration
+}
+
+// DrawCallsInto returns the number of draw calls that were made into target
+func (s FrameStats) DrawCallsInto(target Target) int {
+	return s.DrawCallsPerTarget[TargetIDOf(target)]
+}
+
+// TargetID identifies a Window or a Frame in FrameStats. The stats hold ids rather than the
+// targets, so that the recorded history doesn't keep deleted frames alive.
+type TargetID uint64
+
+var lastTargetID TargetID
+
+func nextTargetID() TargetID {
+	lastTargetID++
+	return lastTargetID
+}
+
+// TargetIDOf returns the id of a Window or a Frame, or 0 for any other target
+func TargetIDOf(target Target) TargetID {
+	switch t := target.(type) {
+	case *Window:
+		return t.targetID
+	case *Frame:
+		return t.targetID
+	}
+	return 0
+}
+
+// RenderStats is a rolling history of the stats of the last N frames
+type RenderStats struct {
+	frames []FrameStats
+	start  int // The index of the oldest frame
+	count  int
+}
+
+// NewRenderStats creates an empty history that holds the stats of up to frames frames
+func NewRenderStats(frames int) *RenderStats {
+	if frames <= 0 {
+		panic("NewRenderStats: the history must hold at least one frame")
+	}
+	return &RenderStats{
+		frames: make([]FrameStats, frames),
+	}
+}
+
+// Record adds the stats of a frame, dropping the oldest frame if the history is full
+func (s *RenderStats) Record(frame FrameStats) {
+	if s.count < len(s.frames) {
+		s.frames[(s.start+s.count)%len(s.frames)] = frame
+		s.count++
+		return
+	}
+	s.frames[s.start] = frame
+	s.start = (s.start + 1) % len(s.frames)
+}
+
+// Len returns the number of recorded frames
+func (s *RenderStats) Len() int {
+	return s.count
+}
+
+// Cap returns the maximum number of frames that the history holds
+func (s *RenderStats) Cap() int {
+	return len(s.frames)
+}
+
+// Frame returns the stats of the i'th recorded frame, where 0 is the oldest
+func (s *RenderStats) Frame(i int) FrameStats {
+	if i < 0 || i >= s.count {
+		panic("RenderStats.Frame: index out of range")
+	}
+	return s.frames[(s.start+i)%len(s.frames)]
+}
+
+// Last returns the stats of the newest frame, or empty stats if nothing has been recorded
+func (s *RenderStats) Last() FrameStats {
+	if s.count == 0 {
+		return FrameStats{}
+	}
+	return s.Frame(s.count - 1)
+}
+
+// Frames returns a copy of every recorded frame, oldest first
+func (s *RenderStats) Frames() []FrameStats {
+	frames := make([]FrameStats, s.count)
+	for i := range frames {
+		frames[i] = s.Frame(i)
+	}
+	return frames
+}
+
+// Clear removes every recorded frame
+func (s *RenderStats) Clear() {
+	clear(s.frames)
+	s.start = 0
+	s.count = 0
+}
+
+//--------------------------------------------------------------------------------
+
+// EnableStats starts recording the stats of each frame into a history of the last frames
+// frames, including the sort, fill and submit timings. Pass 0 to stop recording.
+func EnableStats(frames int) {
+	if frames <= 0 {
+		global.history = nil
+		return
+	}
+	global.history = NewRenderStats(frames)
+}
+
+// Stats returns the history of recorded frames, or nil if stats aren't enabled
+func Stats() *RenderStats {
+	return global.history
+}
+
+// CurrentStats returns the stats of the frame so far
+func CurrentStats() FrameStats {
+	stats := global.stats
+	stats.BuffersInUse = max(stats.BuffersInUse, global.poolBuffers(false))
+	stats.BuffersAllocated = global.poolBuffers(true)
+	return stats
+}
+
+// EndStatsFrame ends the current frame, adding its stats to the history and starting a new
+// frame. Window.Update calls this, it only needs to be called when rendering without a window.
+func EndStatsFrame() {
+	stats := CurrentStats()
+	if global.history != nil {
+		global.history.Record(stats)
+	}
+	global.stats = FrameStats{}
+}
+
+// Returns the number of buffers that were filled this frame, or the total number of buffers
+func (g *globalBatcher) poolBuffers(allocated bool) int {
+	n := 0
+	for shader := range g.shaderCache {
+		if allocated {
+			n += len(shader.pool.buffers)
+		} else {
+			n += shader.pool.nextClean
+		}
+	}
+	return n
+}
+
+// Returns the current time if the stats are timed, so that timings cost nothing when they
+// aren't enabled
+func (g *globalBatcher) statsTime() time.Time {
+	if g.history == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// Adds the time since start to dur, if start was set by statsTime
+func (g *globalBatcher) statsSince(dur *time.Duration, start time.Time) {
+	if start.IsZero() {
+		return
+	}
+	*dur += time.Since(start)
+}
@@ -0,0 +1,172 @@
+//go:build headless
+
+package glitch_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/unitoftime/flow/glm"
+	"github.com/unitoftime/glitch"
+	"github.com/unitoftime/glitch/glitchtest"
+)
+
+// Returns a 1x1 texture of a color
+func colorTexture(t *testing.T, c color.RGBA) *glitch.Texture {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.SetRGBA(0, 0, c)
+	texture := glitch.NewTexture(img, false)
+	t.Cleanup(texture.Delete)
+	return texture
+}
+
+// Renders the scene once to bind its shader, target and a blend mode of none, then starts a
+// new stats frame so that only the next render is counted
+func warmUp(scene *glitchtest.Scene) {
+	whiteSprite().RectDraw(scene.Sorter, scene.Bounds())
+	scene.Render()
+	glitch.EndStatsFrame()
+}
+
+func TestStatsBatchedDraw(t *testing.T) {
+	scene := glitchtest.NewScene(16, 16)
+	warmUp(scene)
+
+	sprite := whiteSprite()
+	for range 3 {
+		sprite.RectDraw(scene.Sorter, scene.Bounds())
+	}
+	scene.Render()
+	stats := glitch.CurrentStats()
+
+	if stats.DrawCalls != 1 {
+		t.Errorf("got %d draw calls for sprites that batch together, want 1", stats.DrawCalls)
+	}
+	if n := stats.DrawCallsInto(scene.Frame); n != 1 {
+		t.Errorf("got %d draw calls into the frame, want 1", n)
+	}
+	if stats.VerticesUploaded != 3*4 || stats.IndicesUploaded != 3*6 {
+		t.Errorf("uploaded %d vertices and %d indices, want %d and %d", stats.VerticesUploaded, stats.IndicesUploaded, 3*4, 3*6)
+	}
+	if stats.ShaderSwitches != 0 || stats.FramebufferSwitches != 0 || stats.BlendSwitches != 0 {
+		t.Errorf("got %d shader, %d framebuffer and %d blend switches, want none", stats.ShaderSwitches, stats.FramebufferSwitches, stats.BlendSwitches)
+	}
+}
+
+func TestStatsStateSwitches(t *testing.T) {
+	scene := glitchtest.NewScene(16, 16)
+	warmUp(scene)
+
+	red := glitch.NewSprite(colorTexture(t, color.RGBA{255, 0, 0, 255}), glm.R(0, 0, 1, 1))
+	blue := glitch.NewSprite(colorTexture(t, color.RGBA{0, 0, 255, 255}), glm.R(0, 0, 1, 1))
+	blended := glitch.NewSprite(colorTexture(t, color.RGBA{0, 0, 255, 255}), glm.R(0, 0, 1, 1))
+	blended.Material().SetBlendMode(glitch.BlendModeAdditive)
+
+	// Every draw changes the texture, and the last one changes the blend mode too
+	red.RectDraw(scene.Sorter, scene.Bounds())
+	blue.RectDraw(scene.Sorter, scene.Bounds())
+	red.RectDraw(scene.Sorter, scene.Bounds())
+	blended.RectDraw(scene.Sorter, scene.Bounds())
+	scene.Render()
+	stats := glitch.CurrentStats()
+
+	if stats.DrawCalls != 4 {
+		t.Errorf("got %d draw calls, want 4", stats.DrawCalls)
+	}
+	if stats.TextureSwitches != 4 {
+		t.Errorf("got %d texture switches, want 4", stats.TextureSwitches)
+	}
+	if stats.BlendSwitches != 1 {
+		t.Errorf("got %d blend switches, want 1", stats.BlendSwitches)
+	}
+	if stats.ShaderSwitches != 0 {
+		t.Errorf("got %d shader switches, want 0", stats.ShaderSwitches)
+	}
+	if stats.VerticesUploaded != 4*4 || stats.IndicesUploaded != 4*6 {
+		t.Errorf("uploaded %d vertices and %d indices, want %d and %d", stats.VerticesUploaded, stats.IndicesUploaded, 4*4, 4*6)
+	}
+}
+
+func TestStatsPerTarget(t *testing.T) {
+	a := glitchtest.NewScene(16, 16)
+	b := glitchtest.NewScene(16, 16)
+	warmUp(a)
+
+	whiteSprite().RectDraw(a.Sorter, a.Bounds())
+	a.Render()
+	whiteSprite().RectDraw(b.Sorter, b.Bounds())
+	b.Render()
+	stats := glitch.CurrentStats()
+
+	if stats.FramebufferSwitches != 1 {
+		t.Errorf("got %d framebuffer switches, want 1", stats.FramebufferSwitches)
+	}
+	if n := stats.DrawCallsInto(a.Frame); n != 1 {
+		t.Errorf("got %d draw calls into the first frame, want 1", n)
+	}
+	if n := stats.DrawCallsInto(b.Frame); n != 1 {
+		t.Errorf("got %d draw calls into the second frame, want 1", n)
+	}
+
+	glitch.EndStatsFrame()
+	if n := glitch.CurrentStats().DrawCalls; n != 0 {
+		t.Errorf("got %d draw calls after ending the stats frame, want 0", n)
+	}
+}
+
+func TestSortBatchesTextureSets(t *testing.T) {
+	tests := []struct {
+		name string
+		sort func(*glitch.Sorter)
+	}{
+		{"depth tested opaque", func(s *glitch.Sorter) { s.DepthTest = true }},
+		{"position tie", func(s *glitch.Sorter) { s.SoftwareSort = glitch.SoftwareSortY }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scene := glitchtest.NewScene(16, 16)
+			warmUp(scene)
+			test.sort(scene.Sorter)
+
+			red := glitch.NewSprite(colorTexture(t, color.RGBA{255, 0, 0, 255}), glm.R(0, 0, 1, 1))
+			blue := glitch.NewSprite(colorTexture(t, color.RGBA{0, 0, 255, 255}), glm.R(0, 0, 1, 1))
+			red.Material().SetBlendMode(glitch.BlendModeNone)
+			blue.Material().SetBlendMode(glitch.BlendModeNone)
+
+			// The textures alternate, but the sort groups them
+			for range 2 {
+				red.RectDraw(scene.Sorter, scene.Bounds())
+				blue.RectDraw(scene.Sorter, scene.Bounds())
+			}
+			scene.Render()
+			if n := glitch.CurrentStats().DrawCalls; n != 2 {
+				t.Errorf("got %d draw calls, want 2", n)
+			}
+		})
+	}
+}
+
+func TestSortBatchesClips(t *testing.T) {
+	scene := glitchtest.NewScene(16, 16)
+	warmUp(scene)
+	scene.Sorter.SoftwareSort = glitch.SoftwareSortY
+
+	sprite := whiteSprite()
+	sprite.Material().SetBlendMode(glitch.BlendModeNone)
+
+	// The clips alternate, but the sort groups them
+	for range 2 {
+		scene.Sorter.PushClip(glm.R(0, 0, 8, 16))
+		sprite.RectDraw(scene.Sorter, scene.Bounds())
+		scene.Sorter.PopClip()
+		scene.Sorter.PushClip(glm.R(8, 0, 16, 16))
+		sprite.RectDraw(scene.Sorter, scene.Bounds())
+		scene.Sorter.PopClip()
+	}
+	scene.Render()
+	if n := glitch.CurrentStats().DrawCalls; n != 2 {
+		t.Errorf("got %d draw calls, want 2", n)
+	}
+}
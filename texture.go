@@ -1,14 +1,17 @@
 package glitch
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
 	"runtime"
+	"unsafe"
 
 	"github.com/unitoftime/flow/glm"
 	"github.com/unitoftime/glitch/internal/gl"
 	"github.com/unitoftime/glitch/internal/mainthread"
+	"github.com/unitoftime/glitch/shaders"
 )
 
 // TODO - Should I use this as default? Or is there a way to do null textures for textureless things?
@@ -22,6 +25,7 @@ func WhiteTexture() *Texture {
 
 	whiteColor := color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
 	whiteTexture = NewRGBATexture(max, max, whiteColor, true)
+	untrackResource(whiteTexture.leakID)
 	return whiteTexture
 }
 
@@ -52,8 +56,201 @@ func NewTransparentTexture64() *Texture {
 
 type Texture struct {
 	texture       gl.Texture
+	id            uint32 // Unique per texture, used to sort draw commands by texture
 	width, height int
-	smooth        bool
+	config        TextureConfig
+	format        TextureFormat
+	deleted       bool
+	leakID        uint64 // The id in the leak registry, see SetLeakTracking
+}
+
+// TextureConfig is the sampler state of a texture
+type TextureConfig struct {
+	WrapU, WrapV TextureWrap // How the texture is sampled outside of the 0 to 1 range, per axis
+
+	MinFilter TextureFilter // The filter used when the texture is drawn smaller than its size
+	MagFilter TextureFilter // The filter used when the texture is drawn larger, Nearest or Linear
+
+	// Indicates mipmaps should be generated whenever the pixels change. The mipmap min filters
+	// need this, otherwise the texture samples as black.
+	Mipmaps bool
+
+	// The maximum anisotropic filtering level, clamped to what the GPU supports. Values of 1 or
+	// less disable it, and it does nothing if the anisotropic filtering extension isn't available.
+	Anisotropy float32
+}
+
+func (c TextureConfig) check() {
+	if c.MagFilter != TextureFilterNearest && c.MagFilter != TextureFilterLinear {
+		panic("texture config: MagFilter must be TextureFilterNearest or TextureFilterLinear")
+	}
+}
+
+// Returns the config that the smooth bool of NewTexture maps to
+func smoothTextureConfig(smooth bool) TextureConfig {
+	if smooth {
+		return TextureConfig{
+			MinFilter: TextureFilterLinear,
+			MagFilter: TextureFilterLinear,
+		}
+	}
+	return TextureConfig{}
+}
+
+type TextureWrap uint8
+
+const (
+	TextureWrapClamp  TextureWrap = iota // Clamps to the edge texels
+	TextureWrapRepeat                    // Tiles the texture
+	TextureWrapMirror                    // Tiles the texture, mirroring every other tile
+)
+
+var textureWrapLut = []gl.Enum{
+	TextureWrapClamp:  gl.CLAMP_TO_EDGE,
+	TextureWrapRepeat: gl.REPEAT,
+	TextureWrapMirror: gl.MIRRORED_REPEAT,
+}
+
+type TextureFilter uint8
+
+const (
+	TextureFilterNearest              TextureFilter = iota // Nearest texel
+	TextureFilterLinear                                    // Blends the nearest 4 texels
+	TextureFilterNearestMipmapNearest                      // Nearest texel of the nearest mipmap
+	TextureFilterLinearMipmapNearest                       // Linear filtering of the nearest mipmap
+	TextureFilterNearestMipmapLinear                       // Nearest texel, blended between mipmaps
+	TextureFilterLinearMipmapLinear                        // Linear filtering, blended between mipmaps (trilinear)
+)
+
+var textureFilterLut = []gl.Enum{
+	TextureFilterNearest:              gl.NEAREST,
+	TextureFilterLinear:               gl.LINEAR,
+	TextureFilterNearestMipmapNearest: gl.NEAREST_MIPMAP_NEAREST,
+	TextureFilterLinearMipmapNearest:  gl.LINEAR_MIPMAP_NEAREST,
+	TextureFilterNearestMipmapLinear:  gl.NEAREST_MIPMAP_LINEAR,
+	TextureFilterLinearMipmapLinear:   gl.LINEAR_MIPMAP_LINEAR,
+}
+
+// The max anisotropic filtering level supported by the GPU, 0 if unsupported, or -1 if it
+// hasn't been queried yet
+var maxAnisotropy float32 = -1
+
+// TextureFormat is the format that a texture's pixels are stored in on the GPU. Each format
+// is read and written with a matching Go pixel type (see TexturePixel).
+// Formats without some of the RGBA channels sample as 0 for the missing colors and 1 for the
+// missing alpha, so an R8 texture samples as (r, 0, 0, 1).
+// WebGL1 (and OpenGL ES 2.0) only supports TextureFormatRGBA8, the constructors that take a
+// format return an error for the others.
+type TextureFormat uint8
+
+const (
+	TextureFormatRGBA8    TextureFormat = iota // 8 bit normalized RGBA, the default. Pixel type color.RGBA
+	TextureFormatR8                            // 8 bit normalized red channel. Pixel type color.Gray
+	TextureFormatRG8                           // 8 bit normalized red and green channels. Pixel type [2]uint8
+	TextureFormatR16F                          // 16 bit float red channel. Pixel type float32
+	TextureFormatRG16F                         // 16 bit float red and green channels. Pixel type [2]float32
+	TextureFormatRGBA16F                       // 16 bit float RGBA. Pixel type [4]float32
+	TextureFormatR32F                          // 32 bit float red channel. Pixel type float32
+	TextureFormatRG32F                         // 32 bit float red and green channels. Pixel type [2]float32
+	TextureFormatRGBA32F                       // 32 bit float RGBA. Pixel type [4]float32
+	TextureFormatDepth24                       // 24 bit normalized depth. Pixel type uint32, where math.MaxUint32 is the far plane
+	TextureFormatDepth32F                      // 32 bit float depth. Pixel type float32
+)
+
+type textureFormatData struct {
+	internalFormat gl.Enum // Passed to TexImage2D
+	sizedFormat    gl.Enum // Passed to RenderbufferStorage, which only takes sized formats
+	format         gl.Enum
+	ty             gl.Enum
+	channels       int // The number of channels per pixel, in the Go pixel type
+	size           int // The number of bytes per channel, in the Go pixel type
+}
+
+// Note: The 16 bit float formats are uploaded as 32 bit floats, GL converts them to the internal format.
+// Note: Webgl can only render into the float formats if EXT_color_buffer_float is available,
+// and can only linearly filter the 32 bit float formats if OES_texture_float_linear is available.
+// Note: The depth formats can't be linearly filtered or mipmapped
+// Note: The default format is uploaded with the unsized RGBA internal format, because WebGL1
+// requires the internal format to match the format.
+var textureFormatLut = []textureFormatData{
+	TextureFormatRGBA8:    {gl.RGBA, gl.RGBA8, gl.RGBA, gl.UNSIGNED_BYTE, 4, 1},
+	TextureFormatR8:       {gl.R8, gl.R8, gl.RED, gl.UNSIGNED_BYTE, 1, 1},
+	TextureFormatRG8:      {gl.RG8, gl.RG8, gl.RG, gl.UNSIGNED_BYTE, 2, 1},
+	TextureFormatR16F:     {gl.R16F, gl.R16F, gl.RED, gl.FLOAT, 1, 4},
+	TextureFormatRG16F:    {gl.RG16F, gl.RG16F, gl.RG, gl.FLOAT, 2, 4},
+	TextureFormatRGBA16F:  {gl.RGBA16F, gl.RGBA16F, gl.RGBA, gl.FLOAT, 4, 4},
+	TextureFormatR32F:     {gl.R32F, gl.R32F, gl.RED, gl.FLOAT, 1, 4},
+	TextureFormatRG32F:    {gl.RG32F, gl.RG32F, gl.RG, gl.FLOAT, 2, 4},
+	TextureFormatRGBA32F:  {gl.RGBA32F, gl.RGBA32F, gl.RGBA, gl.FLOAT, 4, 4},
+	TextureFormatDepth24:  {gl.DEPTH_COMPONENT24, gl.DEPTH_COMPONENT24, gl.DEPTH_COMPONENT, gl.UNSIGNED_INT, 1, 4},
+	TextureFormatDepth32F: {gl.DEPTH_COMPONENT32F, gl.DEPTH_COMPONENT32F, gl.DEPTH_COMPONENT, gl.FLOAT, 1, 4},
+}
+
+// Returns true if the format holds depth values rather than colors
+func (f TextureFormat) depth() bool {
+	return textureFormatLut[f].format == gl.DEPTH_COMPONENT
+}
+
+// Returns an error if the context can't store textures in the format. WebGL1 only has the
+// unsized formats, where the internal format has to match the format, so it can only store
+// the default format.
+func (f TextureFormat) supported() error {
+	if f == TextureFormatRGBA8 || ShaderDialect() != shaders.GLSL100 {
+		return nil
+	}
+	return fmt.Errorf("texture format %d isn't supported by WebGL1 or OpenGL ES 2.0", f)
+}
+
+// TexturePixel is the set of Go types that hold a single pixel of a TextureFormat.
+// The pixel type of each format is listed next to the format.
+type TexturePixel interface {
+	color.RGBA | color.Gray | [2]uint8 | uint32 | float32 | [2]float32 | [4]float32
+}
+
+// Returns the number of channels and the gl type of each channel of pixel type P
+func texturePixelLayout[P TexturePixel]() (int, gl.Enum) {
+	var p P
+	switch any(p).(type) {
+	case color.RGBA:
+		return 4, gl.UNSIGNED_BYTE
+	case color.Gray:
+		return 1, gl.UNSIGNED_BYTE
+	case [2]uint8:
+		return 2, gl.UNSIGNED_BYTE
+	case uint32:
+		return 1, gl.UNSIGNED_INT
+	case float32:
+		return 1, gl.FLOAT
+	case [2]float32:
+		return 2, gl.FLOAT
+	case [4]float32:
+		return 4, gl.FLOAT
+	}
+	panic("unreachable")
+}
+
+// Reinterprets the pixels as bytes, panicking if P isn't the pixel type of the format
+func texturePixelBytes[P TexturePixel](format TextureFormat, pixels []P) []byte {
+	channels, ty := texturePixelLayout[P]()
+	f := textureFormatLut[format]
+	if channels != f.channels || ty != f.ty {
+		var p P
+		panic(fmt.Sprintf("texture pixels: %T is not the pixel type of the texture format", p))
+	}
+	if len(pixels) == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(&pixels[0])), len(pixels)*f.channels*f.size)
+}
+
+var lastTextureID uint32
+
+// Returns the texture's id, or 0 for a nil texture
+func (t *Texture) sortID() uint32 {
+	if t == nil {
+		return 0
+	}
+	return t.id
 }
 
 func toRgba(img image.Image) *image.RGBA {
@@ -69,18 +266,96 @@ func toRgba(img image.Image) *image.RGBA {
 	return rgba
 }
 
+func toGray(img image.Image) *image.Gray {
+	gray, isGray := img.(*image.Gray)
+	if isGray {
+		return gray
+	}
+
+	gray = image.NewGray(img.Bounds())
+	draw.Draw(gray, gray.Bounds(), img, img.Bounds().Min, draw.Src)
+	return gray
+}
+
+// Returns the pixels of img as tightly packed rows
+func grayPixels(img *image.Gray) []color.Gray {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	pix := img.Pix
+	if img.Stride != width {
+		pix = make([]uint8, 0, width*height)
+		for y := 0; y < height; y++ {
+			i := y * img.Stride
+			pix = append(pix, img.Pix[i:i+width]...)
+		}
+	}
+	return unsafe.Slice((*color.Gray)(unsafe.Pointer(unsafe.SliceData(pix))), len(pix))
+}
+
 func NewEmptyTexture(width, height int, smooth bool) *Texture {
+	return NewEmptyTextureConfig(width, height, smoothTextureConfig(smooth))
+}
+
+func NewEmptyTextureConfig(width, height int, config TextureConfig) *Texture {
+	return newEmptyTexture(width, height, config, TextureFormatRGBA8)
+}
+
+// NewEmptyTextureFormat creates a texture stored in format, with every pixel set to zero.
+// Returns an error if the context doesn't support the format.
+func NewEmptyTextureFormat(width, height int, config TextureConfig, format TextureFormat) (*Texture, error) {
+	if err := format.supported(); err != nil {
+		return nil, err
+	}
+	return newEmptyTexture(width, height, config, format), nil
+}
+
+func newEmptyTexture(width, height int, config TextureConfig, format TextureFormat) *Texture {
 	t := &Texture{
 		width:  width,
 		height: height,
-		smooth: smooth,
+		config: config,
+		format: format,
 	}
 
 	t.initialize(nil)
 	return t
 }
 
+// NewTexturePixels creates a texture stored in format from pixels, which must be of the format's
+// pixel type. The pixels are ordered row by row, in the same order as the pixels of an image
+// passed to NewTexture. Returns an error if the context doesn't support the format.
+func NewTexturePixels[P TexturePixel](width, height int, config TextureConfig, format TextureFormat, pixels []P) (*Texture, error) {
+	if len(pixels) != width*height {
+		panic("new texture pixels: wrong number of pixels")
+	}
+	data := texturePixelBytes(format, pixels)
+	if err := format.supported(); err != nil {
+		return nil, err
+	}
+
+	t := &Texture{
+		width:  width,
+		height: height,
+		config: config,
+		format: format,
+	}
+
+	t.initialize(data)
+	return t, nil
+}
+
+// NewTextureGray creates a single channel TextureFormatR8 texture from the luminance of img.
+// Useful for masks and single channel SDF atlases, which then take a quarter of the memory.
+// Returns an error on WebGL1, which doesn't support TextureFormatR8.
+func NewTextureGray(img image.Image, config TextureConfig) (*Texture, error) {
+	gray := toGray(img)
+	return NewTexturePixels(gray.Bounds().Dx(), gray.Bounds().Dy(), config, TextureFormatR8, grayPixels(gray))
+}
+
 func NewTexture(img image.Image, smooth bool) *Texture {
+	return NewTextureConfig(img, smoothTextureConfig(smooth))
+}
+
+func NewTextureConfig(img image.Image, config TextureConfig) *Texture {
 	// We can only use RGBA images right now.
 	rgba := toRgba(img)
 
@@ -89,7 +364,7 @@ func NewTexture(img image.Image, smooth bool) *Texture {
 	t := &Texture{
 		width:  width,
 		height: height,
-		smooth: smooth,
+		config: config,
 	}
 
 	t.initialize(rgba.Pix)
@@ -98,47 +373,82 @@ func NewTexture(img image.Image, smooth bool) *Texture {
 }
 
 func (t *Texture) initialize(pixels []uint8) {
+	t.config.check()
+	lastTextureID++
+	t.id = lastTextureID
+
 	mainthread.Call(func() {
 		t.texture = gl.CreateTexture()
 		gl.BindTexture(gl.TEXTURE_2D, t.texture)
 
-		gl.TexImage2DFull(gl.TEXTURE_2D, 0, gl.RGBA, t.width, t.height, gl.RGBA, gl.UNSIGNED_BYTE, pixels)
+		f := textureFormatLut[t.format]
+		gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1) // Rows of the smaller formats aren't 4 byte aligned
+		gl.TexImage2DFull(gl.TEXTURE_2D, 0, f.internalFormat, t.width, t.height, f.format, f.ty, pixels)
+		gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
 
-		// TODO - webgl doesn't support CLAMP_TO_BORDER
-		// GL_CLAMP_TO_EDGE: The coordinate will simply be clamped between 0 and 1.
-		// GL_CLAMP_TO_BORDER: The coordinates that fall outside the range will be given a specified border color.
-
-		// TODO - Do I need this all the time? Probably not, especially when I'm doing texture atlases
-		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
-		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
-
-		// gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
-		// gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
-
-		// TODO - pass smooth in as a parameter
-		if t.smooth {
-			gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
-			gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-		} else {
-			gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
-			gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+		t.mainthreadApplyConfig()
+		if t.config.Mipmaps {
+			gl.GenerateMipmap(gl.TEXTURE_2D)
 		}
+
+		state.mainthreadRestoreTexture()
 	})
 
+	t.leakID = trackResource("texture")
 	runtime.SetFinalizer(t, (*Texture).delete)
 }
 
-// TODO: This needs to be combined into the NewTexture function, or this needs to bind the texture
-func (t *Texture) GenerateMipmap() {
+// Sets the sampler state of the bound texture to match the texture's config
+func (t *Texture) mainthreadApplyConfig() {
+	c := t.config
+
+	// TODO - webgl doesn't support CLAMP_TO_BORDER
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, int(textureWrapLut[c.WrapU]))
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, int(textureWrapLut[c.WrapV]))
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, int(textureFilterLut[c.MinFilter]))
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, int(textureFilterLut[c.MagFilter]))
+
+	if maxAnisotropy < 0 {
+		maxAnisotropy = gl.MaxAnisotropy()
+	}
+	if maxAnisotropy > 0 {
+		gl.TexParameterf(gl.TEXTURE_2D, gl.TEXTURE_MAX_ANISOTROPY, min(max(c.Anisotropy, 1), maxAnisotropy))
+	}
+}
+
+// Config returns the sampler state of the texture
+func (t *Texture) Config() TextureConfig {
+	return t.config
+}
+
+// SetConfig changes the sampler state of the texture. If mipmaps are enabled then they are
+// generated again.
+func (t *Texture) SetConfig(config TextureConfig) {
+	config.check()
+	t.config = config
+
 	mainthread.Call(func() {
-		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
-		gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-		gl.GenerateMipmap(gl.TEXTURE_2D)
+		gl.BindTexture(gl.TEXTURE_2D, t.texture)
+		t.mainthreadApplyConfig()
+		if t.config.Mipmaps {
+			gl.GenerateMipmap(gl.TEXTURE_2D)
+		}
+		state.mainthreadRestoreTexture()
 	})
 }
 
+// GenerateMipmap enables mipmaps with trilinear filtering
+func (t *Texture) GenerateMipmap() {
+	config := t.config
+	config.MinFilter = TextureFilterLinearMipmapLinear
+	config.MagFilter = TextureFilterLinear
+	config.Mipmaps = true
+	t.SetConfig(config)
+}
+
 // Sets the texture to be this image.
 // Texture size must match img size or this will panic!
+// The texture format must be TextureFormatRGBA8 or TextureFormatR8.
 // TODO - Should I just try and set it? or do nothing?
 func (t *Texture) SetImage(img image.Image) {
 	if img == nil {
@@ -149,23 +459,47 @@ func (t *Texture) SetImage(img image.Image) {
 		panic("SetImage: img bounds are not equal to texture bounds!")
 	}
 
+	if t.format == TextureFormatR8 {
+		SetTexturePixels(t, 0, 0, t.width, t.height, grayPixels(toGray(img)))
+		return
+	}
+
 	rgba := toRgba(img)
 	pixels := rgba.Pix
 	t.SetPixels(0, 0, t.width, t.height, pixels)
 }
 
-// Sets the pixels of a section of a texture
+// Sets the pixels of a section of a TextureFormatRGBA8 texture
 func (t *Texture) SetPixels(x, y, w, h int, pixels []uint8) {
+	if t.format != TextureFormatRGBA8 {
+		panic("set pixels: texture format must be TextureFormatRGBA8, use SetTexturePixels instead")
+	}
 	if len(pixels) != w*h*4 {
 		panic("set pixels: wrong number of pixels")
 	}
 
-	// TODO: This is a little inefficient. But I can't messup the global bound texture state
-	lastTexture := state.texture
-	defer state.bindTexture(lastTexture)
-	state.bindTexture(t)
+	t.setPixels(x, y, w, h, pixels)
+}
+
+// SetTexturePixels sets the pixels of a section of a texture. The pixels must be of the pixel
+// type of the texture's format, ordered like the pixels of NewTexturePixels.
+func SetTexturePixels[P TexturePixel](t *Texture, x, y, w, h int, pixels []P) {
+	if len(pixels) != w*h {
+		panic("set texture pixels: wrong number of pixels")
+	}
+
+	t.setPixels(x, y, w, h, texturePixelBytes(t.format, pixels))
+}
+
+func (t *Texture) setPixels(x, y, w, h int, pixels []uint8) {
+	if len(pixels) == 0 {
+		return
+	}
 
 	mainthread.Call(func() {
+		f := textureFormatLut[t.format]
+		gl.BindTexture(gl.TEXTURE_2D, t.texture)
+		gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
 		gl.TexSubImage2D(
 			gl.TEXTURE_2D,
 			0,
@@ -173,13 +507,23 @@ func (t *Texture) SetPixels(x, y, w, h int, pixels []uint8) {
 			y,
 			w,
 			h,
-			gl.RGBA,
-			gl.UNSIGNED_BYTE,
+			f.format,
+			f.ty,
 			pixels,
 		)
+		gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
+		if t.config.Mipmaps {
+			gl.GenerateMipmap(gl.TEXTURE_2D)
+		}
+		state.mainthreadRestoreTexture() // Don't mess up the global bound texture state
 	})
 }
 
+// Format returns the format that the texture is stored in
+func (t *Texture) Format() TextureFormat {
+	return t.format
+}
+
 func (t *Texture) Bounds() Rect {
 	return glm.R(0, 0, float64(t.width), float64(t.height))
 }
@@ -189,7 +533,30 @@ func (t *Texture) Bounds() Rect {
 // 	state.bindTexture(t)
 // }
 
+// Delete frees the texture on the GPU. The deletion is queued onto the mainthread, after
+// anything that has already been drawn with the texture. The texture can't be used afterwards.
+func (t *Texture) Delete() {
+	if t.deleted {
+		return
+	}
+	runtime.SetFinalizer(t, nil)
+
+	global.flush() // Draw anything that is still batched with the texture
+	for unit := range state.textures {
+		if state.textures[unit] == t {
+			state.textures[unit] = nil // GL unbinds deleted textures
+		}
+	}
+	t.delete()
+}
+
 func (t *Texture) delete() {
+	if t.deleted {
+		return
+	}
+	t.deleted = true
+	untrackResource(t.leakID)
+
 	mainthread.CallNonBlock(func() {
 		gl.DeleteTexture(t.texture)
 	})
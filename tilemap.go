@@ -0,0 +1,322 @@
+package glitch
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/unitoftime/flow/glm"
+)
+
+// Tile is a single cell of a Tilemap
+type Tile struct {
+	ID    int // The index of the tile in the tileset, left to right then top to bottom. Negative for no tile
+	Flags TileFlags
+}
+
+// NoTile is an empty cell
+var NoTile = Tile{ID: -1}
+
+// TileFlags orient a tile. The rotation is applied first, then the flips, so that every one of
+// the 8 orientations can be reached.
+type TileFlags uint8
+
+const (
+	TileFlipX    TileFlags = 1 << iota // Mirrors the tile horizontally
+	TileFlipY                          // Mirrors the tile vertically
+	TileRotate90                       // Rotates the tile 90 degrees clockwise
+)
+
+// Tilemap draws a grid of tiles from a tileset texture. The tiles are built into static meshes,
+// one per chunk of tiles, which are only rebuilt when one of their tiles changes.
+// Tile (0, 0) is in the bottom left, and each tile is tileSize units wide.
+type Tilemap struct {
+	tileset    *Texture
+	material   Material
+	tileSize   Vec2
+	columns    int // The number of tiles in each row of the tileset
+	width      int // The size of the grid, in tiles
+	height     int
+	tiles      []Tile
+	chunkSize  int
+	chunksWide int
+	chunks     []*tileChunk
+
+	animations map[int]*tileAnimation
+}
+
+type tileChunk struct {
+	mesh     *Mesh // The buffered mesh, nil if the chunk is empty
+	bounds   Rect  // The bounds of the chunk in local space
+	dirty    bool
+	animated map[int]bool // The animated tile ids in the chunk
+}
+
+// Frees the chunk's buffered mesh on the GPU
+func (c *tileChunk) deleteMesh() {
+	if c.mesh == nil {
+		return
+	}
+	c.mesh.buffer.Delete()
+	c.mesh = nil
+}
+
+// The frames that a tile id cycles through
+type tileAnimation struct {
+	frames   []int
+	duration time.Duration // The duration of each frame
+	elapsed  time.Duration
+	current  int
+}
+
+// DefaultTilemapChunkSize is the width and height of each chunk, in tiles
+const DefaultTilemapChunkSize = 16
+
+// NewTilemap creates a tilemap of tileset, which is cut into tiles of tileSize pixels.
+// The grid is indexed as ids[y][x], so ids[0] is the bottom row. Negative ids are empty.
+func NewTilemap(tileset *Texture, tileSize Vec2, ids [][]int) *Tilemap {
+	height := len(ids)
+	width := 0
+	for _, row := range ids {
+		width = max(width, len(row))
+	}
+
+	t := NewEmptyTilemap(tileset, tileSize, width, height)
+	for y, row := range ids {
+		for x, id := range row {
+			t.tiles[y*width+x] = Tile{ID: id}
+		}
+	}
+	return t
+}
+
+// NewEmptyTilemap creates a width x height tilemap without any tiles
+func NewEmptyTilemap(tileset *Texture, tileSize Vec2, width, height int) *Tilemap {
+	if tileSize.X <= 0 || tileSize.Y <= 0 {
+		panic("NewTilemap: the tile size must be positive")
+	}
+
+	t := &Tilemap{
+		tileset:    tileset,
+		material:   DefaultMaterial(tileset),
+		tileSize:   tileSize,
+		columns:    max(int(float64(tileset.width)/tileSize.X), 1),
+		width:      width,
+		height:     height,
+		tiles:      make([]Tile, width*height),
+		animations: make(map[int]*tileAnimation),
+	}
+	for i := range t.tiles {
+		t.tiles[i] = NoTile
+	}
+	t.SetChunkSize(DefaultTilemapChunkSize)
+	return t
+}
+
+// SetChunkSize sets the width and height of each chunk, in tiles. Larger chunks mean fewer draw
+// calls, but more tiles to rebuild when one of them changes and less precise culling.
+func (t *Tilemap) SetChunkSize(size int) {
+	if size <= 0 {
+		panic("SetChunkSize: the chunk size must be positive")
+	}
+
+	for _, c := range t.chunks {
+		c.deleteMesh()
+	}
+
+	t.chunkSize = size
+	t.chunksWide = (t.width + size - 1) / size
+	chunksHigh := (t.height + size - 1) / size
+	t.chunks = make([]*tileChunk, t.chunksWide*chunksHigh)
+	for i := range t.chunks {
+		cx, cy := i%t.chunksWide, i/t.chunksWide
+		t.chunks[i] = &tileChunk{
+			bounds: glm.R(
+				float64(cx*size)*t.tileSize.X,
+				float64(cy*size)*t.tileSize.Y,
+				float64(min((cx+1)*size, t.width))*t.tileSize.X,
+				float64(min((cy+1)*size, t.height))*t.tileSize.Y,
+			),
+			dirty: true,
+		}
+	}
+}
+
+// Delete frees the chunk meshes on the GPU. The tileset isn't deleted, because it isn't owned
+// by the tilemap. The chunks are rebuilt if the tilemap is drawn again.
+func (t *Tilemap) Delete() {
+	for _, c := range t.chunks {
+		c.deleteMesh()
+		c.dirty = true
+	}
+}
+
+// Size returns the width and height of the grid, in tiles
+func (t *Tilemap) Size() (int, int) {
+	return t.width, t.height
+}
+
+// Bounds returns the bounds of the whole grid in local space
+func (t *Tilemap) Bounds() Rect {
+	return glm.R(0, 0, float64(t.width)*t.tileSize.X, float64(t.height)*t.tileSize.Y)
+}
+
+func (t *Tilemap) Material() *Material {
+	return &t.material
+}
+
+// Tile returns the tile at x, y. Out of bounds tiles are empty.
+func (t *Tilemap) Tile(x, y int) Tile {
+	if x < 0 || y < 0 || x >= t.width || y >= t.height {
+		return NoTile
+	}
+	return t.tiles[y*t.width+x]
+}
+
+// SetTile sets the tile at x, y and marks its chunk to be rebuilt
+func (t *Tilemap) SetTile(x, y int, tile Tile) {
+	if x < 0 || y < 0 || x >= t.width || y >= t.height {
+		panic(fmt.Sprintf("SetTile: %d, %d is outside of the %dx%d tilemap", x, y, t.width, t.height))
+	}
+	i := y*t.width + x
+	if t.tiles[i] == tile {
+		return
+	}
+	t.tiles[i] = tile
+	t.chunks[(y/t.chunkSize)*t.chunksWide+x/t.chunkSize].dirty = true
+}
+
+// SetTileAnimation makes every tile with id cycle through the tile ids of frames, showing each
+// one for frameDuration. Pass no frames to remove the animation. Call Update to advance them.
+func (t *Tilemap) SetTileAnimation(id int, frames []int, frameDuration time.Duration) {
+	if len(frames) == 0 {
+		delete(t.animations, id)
+	} else {
+		if frameDuration <= 0 {
+			panic("SetTileAnimation: the frame duration must be positive")
+		}
+		t.animations[id] = &tileAnimation{
+			frames:   frames,
+			duration: frameDuration,
+		}
+	}
+
+	for _, c := range t.chunks {
+		c.dirty = true
+	}
+}
+
+// Update advances the tile animations by dt. Only the chunks with animated tiles whose frame
+// changed are rebuilt.
+func (t *Tilemap) Update(dt time.Duration) {
+	for id, anim := range t.animations {
+		anim.elapsed += dt
+		if anim.elapsed < anim.duration {
+			continue
+		}
+		steps := int(anim.elapsed / anim.duration)
+		anim.elapsed -= time.Duration(steps) * anim.duration
+		next := (anim.current + steps) % len(anim.frames)
+		if anim.frames[next] == anim.frames[anim.current] {
+			anim.current = next
+			continue
+		}
+		anim.current = next
+
+		for _, c := range t.chunks {
+			if c.animated[id] {
+				c.dirty = true
+			}
+		}
+	}
+}
+
+// Draw draws every chunk that is visible to camera, with matrix applied to the whole tilemap.
+// Chunks that have changed are rebuilt before they are drawn.
+func (t *Tilemap) Draw(target BatchTarget, camera *CameraOrtho, matrix Mat4) {
+	t.DrawColorMask(target, camera, matrix, White)
+}
+
+func (t *Tilemap) DrawColorMask(target BatchTarget, camera *CameraOrtho, matrix Mat4, mask RGBA) {
+	bounds := camera.Bounds()
+	lo := camera.Unproject(bounds.Min.Vec3())
+	hi := camera.Unproject(bounds.Max.Vec3())
+	view := glm.R(lo.X, lo.Y, hi.X, hi.Y).Norm()
+
+	for _, c := range t.chunks {
+		bounds := c.bounds.ToBox().Apply(matrix).Rect().Norm()
+		if !bounds.Intersects(view) {
+			continue
+		}
+		if c.dirty {
+			t.rebuild(c)
+		}
+		if c.mesh == nil {
+			continue
+		}
+		target.Add(c.mesh.g(), glm4(matrix), mask, t.material)
+	}
+}
+
+// Rebuilds the mesh of a chunk from its tiles
+func (t *Tilemap) rebuild(c *tileChunk) {
+	c.dirty = false
+	c.deleteMesh()
+	c.animated = make(map[int]bool)
+
+	minX, minY := int(c.bounds.Min.X/t.tileSize.X+0.5), int(c.bounds.Min.Y/t.tileSize.Y+0.5)
+	mesh := NewMesh()
+	for y := minY; y < min(minY+t.chunkSize, t.height); y++ {
+		for x := minX; x < min(minX+t.chunkSize, t.width); x++ {
+			tile := t.tiles[y*t.width+x]
+			if tile.ID < 0 {
+				continue
+			}
+			id := tile.ID
+			if anim, ok := t.animations[id]; ok {
+				c.animated[id] = true
+				id = anim.frames[anim.current]
+			}
+			bounds := glm.R(
+				float64(x)*t.tileSize.X,
+				float64(y)*t.tileSize.Y,
+				float64(x+1)*t.tileSize.X,
+				float64(y+1)*t.tileSize.Y,
+			)
+			t.appendTile(mesh, bounds, id, tile.Flags)
+		}
+	}
+
+	if len(mesh.positions) > 0 {
+		c.mesh = mesh.Buffer(t.material.shader)
+	}
+}
+
+// The uv corners of a tile in the vertex order of AppendQuadMesh (top right, bottom right,
+// bottom left, top left), with v pointing down
+var tileCorners = [4][2]float64{{1, 0}, {1, 1}, {0, 1}, {0, 0}}
+
+func (t *Tilemap) appendTile(mesh *Mesh, bounds Rect, id int, flags TileFlags) {
+	tw, th := float64(t.tileset.width), float64(t.tileset.height)
+	tx, ty := float64(id%t.columns)*t.tileSize.X, float64(id/t.columns)*t.tileSize.Y
+
+	start := len(mesh.positions)
+	mesh.AppendQuadMesh(bounds, glm.R(0, 0, 1, 1), White)
+
+	for i, corner := range tileCorners {
+		// Undo the flips, then the rotation, to find the texel that is shown at this corner
+		u, v := corner[0], corner[1]
+		if flags&TileFlipX != 0 {
+			u = 1 - u
+		}
+		if flags&TileFlipY != 0 {
+			v = 1 - v
+		}
+		if flags&TileRotate90 != 0 {
+			u, v = v, 1-u
+		}
+		mesh.texCoords[start+i] = glVec2{
+			float32((tx + u*t.tileSize.X) / tw),
+			float32((ty + v*t.tileSize.Y) / th),
+		}
+	}
+}
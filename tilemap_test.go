@@ -0,0 +1,154 @@
+//go:build headless
+
+package glitch
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/unitoftime/flow/glm"
+)
+
+// Records the meshes that are added to it
+type recordTarget struct {
+	meshes []*Mesh
+}
+
+func (r *recordTarget) Add(g GeometryFiller, _ glMat4, _ RGBA, _ Material) {
+	r.meshes = append(r.meshes, g.mesh)
+}
+
+// Draws the tilemap and returns the indices of the chunks that were drawn
+func drawnChunks(tilemap *Tilemap, camera *CameraOrtho, matrix Mat4) []int {
+	var target recordTarget
+	tilemap.Draw(&target, camera, matrix)
+	var drawn []int
+	for i, c := range tilemap.chunks {
+		if c.mesh != nil && slices.Contains(target.meshes, c.mesh) {
+			drawn = append(drawn, i)
+		}
+	}
+	return drawn
+}
+
+// Returns the indices of the chunks that need to be rebuilt
+func dirtyChunks(tilemap *Tilemap) []int {
+	var dirty []int
+	for i, c := range tilemap.chunks {
+		if c.dirty {
+			dirty = append(dirty, i)
+		}
+	}
+	return dirty
+}
+
+func filledTilemap(t *testing.T, width, height int) *Tilemap {
+	t.Helper()
+	tilemap := NewEmptyTilemap(WhiteTexture(), Vec2{4, 4}, width, height)
+	for y := range height {
+		for x := range width {
+			tilemap.SetTile(x, y, Tile{})
+		}
+	}
+	t.Cleanup(tilemap.Delete)
+	return tilemap
+}
+
+func TestTilemapVisibleChunks(t *testing.T) {
+	// 8x8 tiles of 4 units, in 4x4 chunks that are each 8 units wide
+	tilemap := filledTilemap(t, 8, 8)
+	tilemap.SetChunkSize(2)
+
+	camera := NewCameraOrtho()
+	camera.SetOrtho2D(glm.R(0, 0, 12, 12))
+	camera.SetView2D(0, 0, 1, 1)
+
+	moved, scaled, offscreen := Mat4Ident, Mat4Ident, Mat4Ident
+	moved.Translate(-18, -2, 0)
+	scaled.Scale(3, 3, 1)
+	offscreen.Translate(100, 0, 0)
+
+	tests := []struct {
+		name   string
+		matrix Mat4
+		want   []int
+	}{
+		{"bottom left", Mat4Ident, []int{0, 1, 4, 5}},
+		{"moved", moved, []int{2, 3, 6, 7}},
+		{"scaled", scaled, []int{0}},
+		{"offscreen", offscreen, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := drawnChunks(tilemap, camera, test.matrix)
+			if !slices.Equal(got, test.want) {
+				t.Errorf("drew chunks %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	// Empty chunks aren't drawn
+	for y := range 2 {
+		for x := range 2 {
+			tilemap.SetTile(x, y, NoTile)
+		}
+	}
+	if got, want := drawnChunks(tilemap, camera, Mat4Ident), []int{1, 4, 5}; !slices.Equal(got, want) {
+		t.Errorf("drew chunks %v after emptying chunk 0, want %v", got, want)
+	}
+}
+
+func TestTilemapSetTileChunks(t *testing.T) {
+	// 5x3 tiles in 2x2 chunks, so the last column and row of chunks are partial
+	tilemap := NewEmptyTilemap(WhiteTexture(), Vec2{4, 4}, 5, 3)
+	t.Cleanup(tilemap.Delete)
+	tilemap.SetChunkSize(2)
+	if len(tilemap.chunks) != 6 || tilemap.chunks[5].bounds != glm.R(16, 8, 20, 12) {
+		t.Fatalf("got %d chunks with the last at %v", len(tilemap.chunks), tilemap.chunks[5].bounds)
+	}
+
+	camera := NewCameraOrtho()
+	camera.SetOrtho2D(glm.R(0, 0, 32, 32))
+	camera.SetView2D(0, 0, 1, 1)
+	if got := drawnChunks(tilemap, camera, Mat4Ident); got != nil {
+		t.Fatalf("drew chunks %v of an empty tilemap", got)
+	}
+
+	tests := []struct {
+		x, y  int
+		tile  Tile
+		dirty []int // The chunks that must be rebuilt
+		drawn []int // The chunks that are drawn after the change
+	}{
+		{1, 1, Tile{ID: 0}, []int{0}, []int{0}},
+		{2, 1, Tile{ID: 0}, []int{1}, []int{0, 1}},
+		{1, 2, Tile{ID: 0}, []int{3}, []int{0, 1, 3}},
+		{4, 2, Tile{ID: 0}, []int{5}, []int{0, 1, 3, 5}},
+		{4, 2, Tile{ID: 0}, nil, []int{0, 1, 3, 5}}, // Setting the same tile changes nothing
+		{4, 2, Tile{ID: 0, Flags: TileFlipX}, []int{5}, []int{0, 1, 3, 5}},
+		{2, 1, NoTile, []int{1}, []int{0, 3, 5}},
+		{1, 1, NoTile, []int{0}, []int{3, 5}},
+	}
+	for _, test := range tests {
+		tilemap.SetTile(test.x, test.y, test.tile)
+		if got := dirtyChunks(tilemap); !slices.Equal(got, test.dirty) {
+			t.Errorf("setting %d, %d to %v dirtied chunks %v, want %v", test.x, test.y, test.tile, got, test.dirty)
+		}
+		if got := drawnChunks(tilemap, camera, Mat4Ident); !slices.Equal(got, test.drawn) {
+			t.Errorf("after setting %d, %d to %v drew chunks %v, want %v", test.x, test.y, test.tile, got, test.drawn)
+		}
+		if got := tilemap.Tile(test.x, test.y); got != test.tile {
+			t.Errorf("Tile(%d, %d) = %v, want %v", test.x, test.y, got, test.tile)
+		}
+	}
+
+	if tilemap.Tile(5, 0) != NoTile || tilemap.Tile(0, -1) != NoTile {
+		t.Errorf("tiles outside of the tilemap aren't empty")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("setting a tile outside of the tilemap didn't panic")
+		}
+	}()
+	tilemap.SetTile(5, 0, Tile{})
+}
@@ -232,6 +232,16 @@ func Layer() int8 {
 	return global.sorter.Layer()
 }
 
+// PushClip clips every widget drawn until the matching PopClip to rect (eg the inside of a
+// scroll area)
+func PushClip(rect glitch.Rect) {
+	global.sorter.PushClip(rect)
+}
+
+func PopClip() {
+	global.sorter.PopClip()
+}
+
 func SetDragData(data any) {
 	global.dragData = data
 }
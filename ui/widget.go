@@ -717,6 +717,25 @@ func ScrollbarReverse(idx *int, total int, rect, hoverRect glitch.Rect) {
 	*idx = -int(math.Round(val))
 }
 
+// BeginScrollzone works like Scrollzone, then clips every widget drawn until the matching
+// EndScroll to hoverRect, so scrolled content doesn't draw outside of the scroll region
+func BeginScrollzone(idx *int, total int, hoverRect glitch.Rect) {
+	Scrollzone(idx, total, hoverRect)
+	PushClip(hoverRect)
+}
+
+// BeginScrollbar works like Scrollbar, then clips every widget drawn until the matching
+// EndScroll to hoverRect, so scrolled content doesn't draw outside of the scroll region
+func BeginScrollbar(idx *int, total int, rect, hoverRect glitch.Rect) {
+	Scrollbar(idx, total, rect, hoverRect)
+	PushClip(hoverRect)
+}
+
+// EndScroll removes the clip added by BeginScrollzone or BeginScrollbar
+func EndScroll() {
+	PopClip()
+}
+
 type SliderStyle struct {
 	Background Style
 	Handle     Style
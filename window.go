@@ -31,6 +31,8 @@ type Window struct {
 
 	width, height int
 
+	targetID TargetID // The id that the window's draw calls are counted under, see FrameStats
+
 	tmpInput, input struct {
 		justPressed  [KeyLast + 1]bool
 		justReleased [KeyLast + 1]bool
@@ -84,7 +86,8 @@ type repeatData struct {
 
 func NewWindow(width, height int, title string, inputConfig WindowConfig) (*Window, error) {
 	win := &Window{
-		config: inputConfig,
+		config:   inputConfig,
+		targetID: nextTargetID(),
 
 		scrollCallbacks:      make([]glfw.ScrollCallback, 0),
 		keyCallbacks:         make([]glfw.KeyCallback, 0),
@@ -120,6 +123,7 @@ func NewWindow(width, height int, title string, inputConfig WindowConfig) (*Wind
 		if win.config.Samples > 0 {
 			glfw.WindowHint(glfw.Samples, win.config.Samples)
 		}
+		glfw.WindowHint(glfw.StencilBits, 8) // For StencilMode
 		glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
 		glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True) // Compatibility - For Mac only?
 
@@ -300,6 +304,10 @@ func NewWindow(width, height int, title string, inputConfig WindowConfig) (*Wind
 		return nil, fmt.Errorf("failed to create window: %w", err)
 	}
 
+	// Cache the dialect now that the context exists, it's checked every time a shader, frame or
+	// texture is created
+	ShaderDialect()
+
 	win.mainthreadUpdate = func() {
 		// TODO - I think this is only useful for webgl because of how my RAF works I think in Firefox it sometimes swaps buffer before finishing the opengl stuff. Weird!
 		// TODO - using gl.Finish is bad? https://www.khronos.org/opengl/wiki/Swap_Interval
@@ -354,9 +362,13 @@ func (w *Window) Update() {
 	w.lastUpdateTime = nextLastUpdate
 
 	global.finish()
+	EndStatsFrame()
 
 	mainthread.Call(w.mainthreadUpdate)
 
+	updateReadbacks()
+	updateShaderFiles()
+
 	w.input = w.tmpInput
 	w.tmpInput.scroll.X = 0
 	w.tmpInput.scroll.Y = 0
@@ -518,16 +530,6 @@ func (w *Window) Bind() {
 	state.bindFramebuffer(gl.NoFramebuffer, w.Bounds())
 }
 
-// Reads a rectangle of the window's frame as a collection of bytes
-// func (w *Window) ReadFrame(rect Rect, dst []byte) {
-// 	mainthreadCall(func() {
-// 		gl.BindFramebuffer(gl.FRAMEBUFFER, gl.NoFramebuffer)
-// 		// TODO Note: https://docs.gl/es3/glReadPixels#:~:text=glReadPixels%20returns%20pixel%20data%20from,parameters%20are%20set%20with%20glPixelStorei.
-// 		// Format and Type Enums define the expected pixel format and type to return to the byte buffer. Right now I have that hardcoded to gl.RGBA and gl.UNSIGNED_BYTE, respectively
-// 		gl.ReadPixels(dst, int(rect.Min[0]), int(rect.Min[1]), int(rect.W()), int(rect.H()), gl.RGBA, gl.UNSIGNED_BYTE)
-// 	})
-// }
-
 func (w *Window) Pressed(key Key) bool {
 	if key == KeyUnknown {
 		return false